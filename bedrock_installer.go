@@ -0,0 +1,200 @@
+package installer
+
+import (
+	"net/url"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strings"
+)
+
+type (
+	// BedrockInstaller installs the linux Bedrock Dedicated Server, the versions are the bedrock versions such as 1.21.44.01
+	BedrockInstaller struct {
+		// LinksUrl is the official download links API, which only has the latest release and preview
+		LinksUrl string // Default is "https://net-secondary.web.minecraft-services.net/api/v1.0/download/links"
+		// VersionsUrl is the list of the released versions maintained by the community
+		VersionsUrl string // Default is "https://raw.githubusercontent.com/Bedrock-OSS/BDS-Versions/main/versions.json"
+		DownloadUrl string // Default is "https://www.minecraft.net/bedrockdedicatedserver"
+	}
+
+	bedrockLinks struct {
+		Result struct {
+			Links []struct {
+				DownloadType string `json:"downloadType"`
+				DownloadUrl  string `json:"downloadUrl"`
+			} `json:"links"`
+		} `json:"result"`
+	}
+
+	bedrockVersions struct {
+		Linux struct {
+			Stable          string   `json:"stable"`
+			Preview         string   `json:"preview"`
+			Versions        []string `json:"versions"`
+			PreviewVersions []string `json:"preview_versions"`
+		} `json:"linux"`
+	}
+)
+
+var DefaultBedrockInstaller = &BedrockInstaller{
+	LinksUrl:    "https://net-secondary.web.minecraft-services.net/api/v1.0/download/links",
+	VersionsUrl: "https://raw.githubusercontent.com/Bedrock-OSS/BDS-Versions/main/versions.json",
+	DownloadUrl: "https://www.minecraft.net/bedrockdedicatedserver",
+}
+var _ Installer = DefaultBedrockInstaller
+var _ Planner = DefaultBedrockInstaller
+var _ ResolvedPlanner = DefaultBedrockInstaller
+var _ VersionSource = DefaultBedrockInstaller
+
+func init() {
+	MustRegister(DefaultBedrockInstaller, InstallerInfo{
+		Name:         "bedrock",
+		DisplayName:  "Bedrock",
+		Description:  "Bedrock Dedicated Server for linux, the previews are listed as snapshots",
+		Platforms:    []string{"linux"},
+		RequiresJava: false,
+	})
+}
+
+// BedrockExecutable is the server binary in the bedrock archive
+const BedrockExecutable = "bedrock_server"
+
+// BedrockKeepFiles are the configs and the worlds that are kept when the server is upgraded
+var BedrockKeepFiles = []string{"server.properties", "permissions.json", "allowlist.json", "worlds"}
+
+func (r *BedrockInstaller) Install(path, name string, target string) (installed string, err error) {
+	plan, err := r.Plan(path, name, target)
+	if err != nil {
+		return
+	}
+	return plan.Execute()
+}
+
+// Plan downloads the server archive and unpacks it, the files in BedrockKeepFiles are kept if they exist.
+// The executable is a script named name+".sh" which starts the server with its libraries
+func (r *BedrockInstaller) Plan(path, name string, target string) (plan *InstallPlan, err error) {
+	var resolved ResolvedVersion
+	if resolved, err = resolveInstallTarget(r, target); err != nil {
+		return
+	}
+	return r.PlanResolved(path, name, resolved)
+}
+
+func (r *BedrockInstaller) PlanResolved(path, name string, resolved ResolvedVersion) (plan *InstallPlan, err error) {
+	if path, err = filepath.Abs(path); err != nil {
+		return
+	}
+	if runtime.GOOS != "linux" {
+		loger.Warnf("Bedrock Dedicated Server is installed for linux, it cannot run on %s", runtime.GOOS)
+	}
+	version := resolved.Game
+	var versions []McVersion
+	loger.Info("Getting bedrock versions...")
+	if versions, err = r.GameVersions(); err != nil {
+		return
+	}
+	var found *McVersion
+	for i, v := range versions {
+		if version == "" || version == QueryLatest {
+			if v.IsStable() {
+				found = &versions[i]
+				break
+			}
+		} else if version == QueryLatestSnapshot || v.Id == version {
+			found = &versions[i]
+			break
+		}
+	}
+	if found == nil {
+		return nil, &VersionNotFoundErr{"bedrock-" + resolved.String()}
+	}
+	link, err := r.archiveUrl(*found)
+	if err != nil {
+		return
+	}
+	loger.Infof("Planning bedrock %s at %q...", found.Id, link)
+	plan = newInstallPlan("bedrock", path)
+	plan.Game = found.Id
+	archive := PlanTempDir + "/" + bedrockArchive(found.Id)
+	plan.download(link, archive, 0, nil)
+	plan.add(&PlanStep{
+		Kind: StepUnpack,
+		From: archive,
+		Path: path,
+		Keep: BedrockKeepFiles,
+	})
+	plan.Installed = filepath.Join(path, name+".sh")
+	plan.write(plan.Installed, "#!/bin/sh\n"+
+		"cd \"$(dirname \"$0\")\"\n"+
+		"LD_LIBRARY_PATH=. exec ./"+BedrockExecutable+"\n", 0755).Executable = true
+	return
+}
+
+func bedrockArchive(version string) string {
+	return "bedrock-server-" + version + ".zip"
+}
+
+func (r *BedrockInstaller) archiveUrl(v McVersion) (string, error) {
+	dir := "bin-linux"
+	if !v.IsStable() {
+		dir = "bin-linux-preview"
+	}
+	return url.JoinPath(r.DownloadUrl, dir, bedrockArchive(v.Id))
+}
+
+func (r *BedrockInstaller) ListVersions(snapshot bool) (versions []string, err error) {
+	return gameVersionIds(r, snapshot)
+}
+
+// GameVersions returns the bedrock versions newest first, the previews are listed as snapshots.
+// The latest ones are got from the official API, since the community list may not be updated yet
+func (r *BedrockInstaller) GameVersions() (versions []McVersion, err error) {
+	var links bedrockLinks
+	if err = DefaultHTTPClient.GetJson(r.LinksUrl, &links); err != nil {
+		return
+	}
+	types := make(map[string]string)
+	for _, l := range links.Result.Links {
+		var typ string
+		switch l.DownloadType {
+		case "serverBedrockLinux":
+			typ = McRelease
+		case "serverBedrockPreviewLinux":
+			typ = McSnapshot
+		default:
+			continue
+		}
+		name := l.DownloadUrl[strings.LastIndexByte(l.DownloadUrl, '/')+1:]
+		id, ok := strings.CutPrefix(strings.TrimSuffix(name, ".zip"), "bedrock-server-")
+		if !ok {
+			continue
+		}
+		types[id] = typ
+	}
+	if r.VersionsUrl != "" {
+		var list bedrockVersions
+		if err := DefaultHTTPClient.GetJson(r.VersionsUrl, &list); err != nil {
+			loger.Warnf("Couldn't get the bedrock version list, only the latest versions are listed: %v", err)
+		} else {
+			for _, id := range list.Linux.PreviewVersions {
+				types[id] = McSnapshot
+			}
+			for _, id := range list.Linux.Versions {
+				types[id] = McRelease
+			}
+		}
+	}
+	versions = make([]McVersion, 0, len(types))
+	for id, typ := range types {
+		versions = append(versions, McVersion{Id: id, Type: typ})
+	}
+	sort.Slice(versions, func(i, j int) bool {
+		return compareVersionStrings(versions[i].Id, versions[j].Id) > 0
+	})
+	return
+}
+
+func (r *BedrockInstaller) LoaderVersions(gameVersion string) ([]LoaderVersion, error) {
+	return nil, nil
+}
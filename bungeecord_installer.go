@@ -0,0 +1,235 @@
+package installer
+
+import (
+	"net"
+	"net/url"
+	"path/filepath"
+	"strconv"
+
+	"gopkg.in/yaml.v3"
+)
+
+type (
+	// BungeeCordInstaller installs the bungeecord proxy built by jenkins, the versions are the build numbers
+	BungeeCordInstaller struct {
+		JobUrl string // Default is "https://ci.md-5.net/job/BungeeCord"
+	}
+
+	// WaterfallInstaller installs the waterfall proxy, the versions are the minecraft versions and the loaders are the builds
+	WaterfallInstaller struct {
+		PaperMCProject
+	}
+
+	jenkinsBuild struct {
+		Number int    `json:"number"`
+		Result string `json:"result"`
+	}
+)
+
+var DefaultBungeeCordInstaller = &BungeeCordInstaller{
+	JobUrl: "https://ci.md-5.net/job/BungeeCord",
+}
+var _ Installer = DefaultBungeeCordInstaller
+var _ Planner = DefaultBungeeCordInstaller
+var _ ResolvedPlanner = DefaultBungeeCordInstaller
+var _ ProxyPlanner = DefaultBungeeCordInstaller
+
+var DefaultWaterfallInstaller = &WaterfallInstaller{
+	PaperMCProject{
+		ApiUrl:  "https://fill.papermc.io/v3",
+		Project: "waterfall",
+	},
+}
+var _ Installer = DefaultWaterfallInstaller
+var _ Planner = DefaultWaterfallInstaller
+var _ ResolvedPlanner = DefaultWaterfallInstaller
+var _ ProxyPlanner = DefaultWaterfallInstaller
+var _ VersionSource = DefaultWaterfallInstaller
+
+func init() {
+	MustRegister(DefaultBungeeCordInstaller, InstallerInfo{
+		Name:         "bungeecord",
+		DisplayName:  "BungeeCord",
+		Description:  "BungeeCord proxy, the version is the jenkins build number",
+		RequiresJava: false,
+	})
+	MustRegister(DefaultWaterfallInstaller, InstallerInfo{
+		Name:         "waterfall",
+		DisplayName:  "Waterfall",
+		Description:  "Waterfall proxy, the fork of bungeecord by PaperMC, the loader is the build",
+		RequiresJava: false,
+	})
+}
+
+var bungeeForwardings = []string{ForwardingLegacy, ForwardingNone}
+
+func (r *BungeeCordInstaller) Install(path, name string, target string) (installed string, err error) {
+	plan, err := r.Plan(path, name, target)
+	if err != nil {
+		return
+	}
+	return plan.Execute()
+}
+
+// Plan plans the proxy with a starter config, see PlanProxy
+func (r *BungeeCordInstaller) Plan(path, name string, target string) (*InstallPlan, error) {
+	return r.PlanResolved(path, name, ResolvedVersion{Game: target})
+}
+
+func (r *BungeeCordInstaller) PlanResolved(path, name string, version ResolvedVersion) (*InstallPlan, error) {
+	return r.PlanProxy(path, name, version, new(ProxyNetwork))
+}
+
+// PlanProxy plans bungeecord with config.yml listing the backends, and sets up the local backends for the forwarding
+func (r *BungeeCordInstaller) PlanProxy(path, name string, version ResolvedVersion, network *ProxyNetwork) (plan *InstallPlan, err error) {
+	if path, err = filepath.Abs(path); err != nil {
+		return
+	}
+	if err = network.prepare("bungeecord", bungeeForwardings); err != nil {
+		return
+	}
+	build := version.Game
+	if build == "" || build == QueryLatest || build == QueryLatestSnapshot {
+		loger.Info("Getting bungeecord builds...")
+		var builds []string
+		if builds, err = r.ListVersions(false); err != nil {
+			return
+		}
+		if len(builds) == 0 {
+			return nil, &VersionNotFoundErr{"bungeecord-" + version.Game}
+		}
+		build = builds[0]
+	}
+	link, err := url.JoinPath(r.JobUrl, build, "artifact/bootstrap/target/BungeeCord.jar")
+	if err != nil {
+		return
+	}
+	loger.Infof("Planning bungeecord build %s at %q...", build, link)
+	plan = newInstallPlan("bungeecord", path)
+	plan.Game = build
+	plan.Installed = filepath.Join(path, name+".jar")
+	plan.download(link, plan.Installed, 0, nil).Executable = true
+	if err = network.planBungeeConfig(plan); err != nil {
+		return
+	}
+	return
+}
+
+// ListVersions returns the numbers of the successful builds, newest first
+func (r *BungeeCordInstaller) ListVersions(snapshot bool) (versions []string, err error) {
+	link, err := url.JoinPath(r.JobUrl, "api/json")
+	if err != nil {
+		return
+	}
+	var res struct {
+		Builds []jenkinsBuild `json:"builds"`
+	}
+	if err = DefaultHTTPClient.GetJson(link+"?tree=builds[number,result]", &res); err != nil {
+		return
+	}
+	for _, b := range res.Builds {
+		if b.Result == "SUCCESS" {
+			versions = append(versions, strconv.Itoa(b.Number))
+		}
+	}
+	return
+}
+
+func (r *WaterfallInstaller) Install(path, name string, target string) (installed string, err error) {
+	plan, err := r.Plan(path, name, target)
+	if err != nil {
+		return
+	}
+	return plan.Execute()
+}
+
+// Plan plans the proxy with a starter config, see PlanProxy
+func (r *WaterfallInstaller) Plan(path, name string, target string) (plan *InstallPlan, err error) {
+	var resolved ResolvedVersion
+	if resolved, err = resolveInstallTarget(r, target); err != nil {
+		return
+	}
+	return r.PlanResolved(path, name, resolved)
+}
+
+func (r *WaterfallInstaller) PlanResolved(path, name string, version ResolvedVersion) (*InstallPlan, error) {
+	return r.PlanProxy(path, name, version, new(ProxyNetwork))
+}
+
+// PlanProxy plans waterfall with config.yml listing the backends, and sets up the local backends for the forwarding
+func (r *WaterfallInstaller) PlanProxy(path, name string, version ResolvedVersion, network *ProxyNetwork) (plan *InstallPlan, err error) {
+	if path, err = filepath.Abs(path); err != nil {
+		return
+	}
+	if err = network.prepare("waterfall", bungeeForwardings); err != nil {
+		return
+	}
+	if plan, err = planPaperMCProject(&r.PaperMCProject, path, name, version); err != nil {
+		return
+	}
+	if err = network.planBungeeConfig(plan); err != nil {
+		return
+	}
+	return
+}
+
+func (r *WaterfallInstaller) ListVersions(snapshot bool) (versions []string, err error) {
+	return gameVersionIds(r, snapshot)
+}
+
+type (
+	bungeeListener struct {
+		Host               string   `yaml:"host"`
+		QueryPort          int      `yaml:"query_port"`
+		Motd               string   `yaml:"motd"`
+		MaxPlayers         int      `yaml:"max_players"`
+		Priorities         []string `yaml:"priorities"`
+		ForceDefaultServer bool     `yaml:"force_default_server"`
+		PingPassthrough    bool     `yaml:"ping_passthrough"`
+	}
+	bungeeServer struct {
+		Motd       string `yaml:"motd"`
+		Address    string `yaml:"address"`
+		Restricted bool   `yaml:"restricted"`
+	}
+	bungeeConfig struct {
+		Listeners  []bungeeListener        `yaml:"listeners"`
+		Servers    map[string]bungeeServer `yaml:"servers"`
+		IpForward  bool                    `yaml:"ip_forward"`
+		OnlineMode bool                    `yaml:"online_mode"`
+	}
+)
+
+// planBungeeConfig adds the starter config.yml of bungeecord and waterfall, they fill the missing settings with the defaults.
+// The existing config.yml is kept if the network doesn't have any backend
+func (n *ProxyNetwork) planBungeeConfig(plan *InstallPlan) (err error) {
+	cfg := bungeeConfig{
+		Servers:    make(map[string]bungeeServer, len(n.Backends)),
+		IpForward:  n.Forwarding == ForwardingLegacy,
+		OnlineMode: true,
+	}
+	listener := bungeeListener{
+		Host:       n.Bind,
+		QueryPort:  25577,
+		Motd:       "&1Another Bungee server",
+		MaxPlayers: 500,
+	}
+	if _, port, err := net.SplitHostPort(n.Bind); err == nil {
+		listener.QueryPort, _ = strconv.Atoi(port)
+	}
+	for _, b := range n.Backends {
+		listener.Priorities = append(listener.Priorities, b.Name)
+		cfg.Servers[b.Name] = bungeeServer{
+			Motd:    "&1Just another BungeeCord - Forced Host",
+			Address: b.Address,
+		}
+	}
+	cfg.Listeners = []bungeeListener{listener}
+	var buf []byte
+	if buf, err = yaml.Marshal(cfg); err != nil {
+		return
+	}
+	config := "# Generated by server-installer, see https://www.spigotmc.org/wiki/bungeecord-configuration-guide/\n" + string(buf)
+	n.planConfig(plan, filepath.Join(plan.Path, "config.yml"), config)
+	return n.planBackends(plan)
+}
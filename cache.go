@@ -0,0 +1,51 @@
+package installer
+
+import (
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"text/tabwriter"
+)
+
+// CacheInfo is the usage of a cache directory, such as the store and the mirror cache
+type CacheInfo struct {
+	Name  string `json:"name"`
+	Dir   string `json:"dir"`
+	Files int    `json:"files"`
+	Size  int64  `json:"size"`
+}
+
+// ReadCacheInfo counts the files in the cache directory, a missing directory is empty
+func ReadCacheInfo(name string, dir string) (info CacheInfo, err error) {
+	info = CacheInfo{Name: name, Dir: dir}
+	err = filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			if os.IsNotExist(err) && path == dir {
+				return nil
+			}
+			return err
+		}
+		if !d.Type().IsRegular() {
+			return nil
+		}
+		fi, err := d.Info()
+		if err != nil {
+			return err
+		}
+		info.Files++
+		info.Size += fi.Size()
+		return nil
+	})
+	return
+}
+
+func WriteCacheTable(w io.Writer, infos []CacheInfo) (err error) {
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "NAME\tFILES\tSIZE\tDIRECTORY")
+	for _, info := range infos {
+		fmt.Fprintf(tw, "%s\t%d\t%s\t%s\n", info.Name, info.Files, formatSize(info.Size, "%.2f"), info.Dir)
+	}
+	return tw.Flush()
+}
@@ -0,0 +1,360 @@
+package installer
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+const (
+	CheckError   = "error"
+	CheckWarning = "warning"
+)
+
+const (
+	CheckIssueMissing    = "missing"
+	CheckIssueVersion    = "version"
+	CheckIssueConflict   = "conflict"
+	CheckIssueDuplicate  = "duplicate"
+	CheckIssueLoader     = "loader"
+	CheckIssueUnreadable = "unreadable"
+)
+
+type (
+	CheckIssue struct {
+		Severity   string `json:"severity"`
+		Kind       string `json:"kind"`
+		File       string `json:"file"`
+		Mod        string `json:"mod,omitempty"`
+		Dependency string `json:"dependency,omitempty"`
+		Range      string `json:"range,omitempty"`
+		Found      string `json:"found,omitempty"`
+		Message    string `json:"message"`
+	}
+
+	// CheckTarget is the server that the mods will be loaded by
+	CheckTarget struct {
+		ServerType    string `json:"serverType"`
+		GameVersion   string `json:"gameVersion"`
+		LoaderVersion string `json:"loaderVersion,omitempty"`
+	}
+
+	CheckReport struct {
+		CheckTarget
+		Mods    []*ModMetadata `json:"mods"`
+		Plugins []*ModMetadata `json:"plugins"`
+		Issues  []CheckIssue   `json:"issues"`
+	}
+)
+
+// serverModLoaders are the mod loaders that the server type can load, ordered by preference
+var serverModLoaders = map[string][]string{
+	"fabric":   {"fabric"},
+	"quilt":    {"quilt", "fabric"},
+	"forge":    {"forge"},
+	"neoforge": {"neoforge", "forge"},
+	"spigot":   {"bukkit"},
+	"paper":    {"paper", "bukkit"},
+	// proxies
+	"velocity":   {"velocity"},
+	"bungeecord": {"bungeecord"},
+	"waterfall":  {"bungeecord"},
+}
+
+type modProvider struct {
+	version string // empty means unknown
+	file    string
+}
+
+type modChecker struct {
+	dir       string
+	target    CheckTarget
+	report    *CheckReport
+	providers map[string][]modProvider
+}
+
+// CheckServer reads the metadata of all jars in mods/ and plugins/ of the server directory,
+// and checks their dependencies against the server and each other
+func CheckServer(dir string, target CheckTarget) (report *CheckReport, err error) {
+	c := &modChecker{
+		dir:    dir,
+		target: target,
+		report: &CheckReport{
+			CheckTarget: target,
+			Mods:        []*ModMetadata{},
+			Plugins:     []*ModMetadata{},
+			Issues:      []CheckIssue{},
+		},
+		providers: make(map[string][]modProvider),
+	}
+	c.addBuiltins()
+	if c.report.Mods, err = c.load("mods"); err != nil {
+		return
+	}
+	if c.report.Plugins, err = c.load("plugins"); err != nil {
+		return
+	}
+	for _, m := range c.report.Mods {
+		c.checkDeps(m)
+	}
+	for _, m := range c.report.Plugins {
+		c.checkDeps(m)
+	}
+	sort.SliceStable(c.report.Issues, func(i, j int) bool {
+		a, b := c.report.Issues[i], c.report.Issues[j]
+		if a.Severity != b.Severity {
+			return a.Severity == CheckError
+		}
+		return a.File < b.File
+	})
+	return c.report, nil
+}
+
+func (c *modChecker) provide(id string, version string, file string) {
+	c.providers[id] = append(c.providers[id], modProvider{version, file})
+}
+
+func (c *modChecker) addBuiltins() {
+	t := c.target
+	switch t.ServerType {
+	case "spigot", "paper", "velocity", "bungeecord", "waterfall":
+		return
+	case "fabric":
+		c.provide("fabricloader", t.LoaderVersion, "")
+	case "quilt":
+		c.provide("quilt_loader", t.LoaderVersion, "")
+		c.provide("fabricloader", "", "")
+	case "forge":
+		c.provide("forge", t.LoaderVersion, "")
+		major, _, _ := strings.Cut(t.LoaderVersion, ".")
+		c.provide("javafml", major, "")
+	case "neoforge":
+		c.provide("neoforge", t.LoaderVersion, "")
+		c.provide("javafml", "", "")
+	}
+	c.provide("minecraft", t.GameVersion, "")
+	c.provide("java", "", "")
+}
+
+func (c *modChecker) addIssue(issue CheckIssue) {
+	c.report.Issues = append(c.report.Issues, issue)
+}
+
+func (c *modChecker) relPath(p string) string {
+	if rel, err := filepath.Rel(c.dir, p); err == nil {
+		return filepath.ToSlash(rel)
+	}
+	return p
+}
+
+// selectMetadata picks the metadata in a jar that will be used by the server
+func (c *modChecker) selectMetadata(metas []*ModMetadata) (selected []*ModMetadata) {
+	loaders, ok := serverModLoaders[c.target.ServerType]
+	if !ok {
+		return metas
+	}
+	for _, l := range loaders {
+		for _, m := range metas {
+			for _, ml := range m.Loaders {
+				if ml == l {
+					selected = append(selected, m)
+					break
+				}
+			}
+		}
+		if len(selected) > 0 {
+			return
+		}
+	}
+	return
+}
+
+func (c *modChecker) provideMod(m *ModMetadata, file string) {
+	c.provide(m.Id, m.Version, file)
+	for _, p := range m.Provides {
+		c.provide(p, m.Version, file)
+	}
+	for _, b := range m.Bundled {
+		c.provideMod(b, file)
+	}
+}
+
+func (c *modChecker) load(sub string) (mods []*ModMetadata, err error) {
+	mods = []*ModMetadata{}
+	var entries []os.DirEntry
+	if entries, err = os.ReadDir(filepath.Join(c.dir, sub)); err != nil {
+		if os.IsNotExist(err) {
+			err = nil
+		}
+		return
+	}
+	owners := make(map[string]string)
+	for _, e := range entries {
+		if e.IsDir() || !strings.EqualFold(filepath.Ext(e.Name()), ".jar") {
+			continue
+		}
+		file := filepath.Join(c.dir, sub, e.Name())
+		rel := c.relPath(file)
+		metas, err := ReadModMetadata(file)
+		if err != nil {
+			var nmErr *NoModMetadataErr
+			severity := CheckError
+			if errors.As(err, &nmErr) {
+				severity = CheckWarning
+			}
+			c.addIssue(CheckIssue{
+				Severity: severity,
+				Kind:     CheckIssueUnreadable,
+				File:     rel,
+				Message:  err.Error(),
+			})
+			continue
+		}
+		selected := c.selectMetadata(metas)
+		if len(selected) == 0 {
+			var loaders []string
+			for _, m := range metas {
+				loaders = append(loaders, m.Loaders...)
+			}
+			c.addIssue(CheckIssue{
+				Severity: CheckError,
+				Kind:     CheckIssueLoader,
+				File:     rel,
+				Mod:      metas[0].Id,
+				Message:  fmt.Sprintf("%s is made for %v, which cannot be loaded by %s server", metas[0].Id, loaders, c.target.ServerType),
+			})
+			continue
+		}
+		for _, m := range selected {
+			m.Path = rel
+			if other, ok := owners[m.Id]; ok {
+				c.addIssue(CheckIssue{
+					Severity: CheckError,
+					Kind:     CheckIssueDuplicate,
+					File:     rel,
+					Mod:      m.Id,
+					Found:    other,
+					Message:  fmt.Sprintf("%s is also provided by %s", m.Id, other),
+				})
+			}
+			owners[m.Id] = rel
+			c.provideMod(m, rel)
+			mods = append(mods, m)
+		}
+	}
+	return
+}
+
+func matchDependency(rg VersionRange, id string, version string) bool {
+	if id == "minecraft" {
+		return rg.MatchMc(version)
+	}
+	return rg.Match(version)
+}
+
+func (c *modChecker) checkDeps(m *ModMetadata) {
+	for _, d := range m.Dependencies {
+		if d.Side == ModEnvClient {
+			continue
+		}
+		issue := CheckIssue{
+			File:       m.Path,
+			Mod:        m.Id,
+			Dependency: d.Id,
+			Range:      d.Range,
+		}
+		rg, err := d.VersionRange()
+		if err != nil {
+			issue.Severity = CheckWarning
+			issue.Kind = CheckIssueUnreadable
+			issue.Message = fmt.Sprintf("%s declares an invalid version range for %s: %v", m.Id, d.Id, err)
+			c.addIssue(issue)
+			continue
+		}
+		providers := c.providers[d.Id]
+		if d.Kind == ModDepIncompatible {
+			for _, p := range providers {
+				if (p.version == "" && rg.IsAny()) || (p.version != "" && matchDependency(rg, d.Id, p.version)) {
+					issue.Severity = CheckError
+					issue.Kind = CheckIssueConflict
+					issue.Found = p.version
+					issue.Message = fmt.Sprintf("%s is incompatible with %s %s", m.Id, d.Id, p.version)
+					c.addIssue(issue)
+					break
+				}
+			}
+			continue
+		}
+		severity := CheckError
+		if d.Kind == ModDepOptional {
+			severity = CheckWarning
+		}
+		if len(providers) == 0 {
+			if d.Kind == ModDepRequired {
+				issue.Severity = severity
+				issue.Kind = CheckIssueMissing
+				issue.Message = fmt.Sprintf("%s requires %s %s, but it is not installed", m.Id, d.Id, rangeText(d.Range))
+				c.addIssue(issue)
+			}
+			continue
+		}
+		matched := false
+		found := make([]string, 0, len(providers))
+		for _, p := range providers {
+			if p.version == "" || matchDependency(rg, d.Id, p.version) {
+				matched = true
+				break
+			}
+			found = append(found, p.version)
+		}
+		if !matched {
+			issue.Severity = severity
+			issue.Kind = CheckIssueVersion
+			issue.Found = strings.Join(found, ", ")
+			issue.Message = fmt.Sprintf("%s requires %s %s, but found %s", m.Id, d.Id, rangeText(d.Range), issue.Found)
+			c.addIssue(issue)
+		}
+	}
+}
+
+func rangeText(r string) string {
+	if r == "" {
+		return "*"
+	}
+	return r
+}
+
+// ErrorCount returns the number of issues that will prevent the server from starting
+func (r *CheckReport) ErrorCount() (n int) {
+	for _, i := range r.Issues {
+		if i.Severity == CheckError {
+			n++
+		}
+	}
+	return
+}
+
+// WriteText writes the human readable report
+func (r *CheckReport) WriteText(w io.Writer) (err error) {
+	var b strings.Builder
+	fmt.Fprintf(&b, "Server: %s %s", r.ServerType, r.GameVersion)
+	if r.LoaderVersion != "" {
+		fmt.Fprintf(&b, " (loader %s)", r.LoaderVersion)
+	}
+	fmt.Fprintf(&b, "\nChecked %d mod(s) and %d plugin(s)\n", len(r.Mods), len(r.Plugins))
+	if len(r.Issues) == 0 {
+		b.WriteString("No problems found\n")
+	} else {
+		errs := r.ErrorCount()
+		fmt.Fprintf(&b, "Found %d error(s) and %d warning(s):\n", errs, len(r.Issues)-errs)
+		for _, i := range r.Issues {
+			fmt.Fprintf(&b, "  [%s] %s: %s\n", strings.ToUpper(i.Severity), i.File, i.Message)
+		}
+	}
+	_, err = io.WriteString(w, b.String())
+	return
+}
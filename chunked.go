@@ -0,0 +1,182 @@
+package installer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+)
+
+const (
+	DefaultChunkThreshold int64 = 16 * 1024 * 1024
+	DefaultDownloadChunks       = 4
+)
+
+// errRangeIgnored is returned when the server answers a range request with the whole content,
+// the download falls back to a single stream
+var errRangeIgnored = errors.New("range request is ignored")
+
+// rangeUnsupported reports whether the chunked download failed since the server doesn't serve the range requests properly
+func rangeUnsupported(err error) bool {
+	var statusErr *HttpStatusError
+	var rangeErr *ContentRangeErr
+	return errors.Is(err, errRangeIgnored) || errors.As(err, &statusErr) || errors.As(err, &rangeErr)
+}
+
+type ContentRangeErr struct {
+	ContentRange string
+	Expect       string
+}
+
+func (e *ContentRangeErr) Error() string {
+	return fmt.Sprintf("Unexpect content range %q, expect %q", e.ContentRange, e.Expect)
+}
+
+// chunks returns the number of range requests that the response should be split to, 1 means download in a single stream
+func (c *HTTPClient) chunks(res *http.Response) int {
+	threshold := c.ChunkThreshold
+	if threshold == 0 {
+		threshold = DefaultChunkThreshold
+	}
+	// the store serves the whole files
+	if threshold < 0 || c.Store != nil || res.ContentLength < threshold {
+		return 1
+	}
+	if !strings.EqualFold(res.Header.Get("Accept-Ranges"), "bytes") || res.Header.Get("Content-Encoding") != "" {
+		return 1
+	}
+	n := c.Chunks
+	if n == 0 {
+		n = DefaultDownloadChunks
+	}
+	if n < 1 {
+		n = 1
+	}
+	return n
+}
+
+// downloadChunks writes the content of res into fd with n parallel range requests.
+// The first chunk is read from res, so the request is not wasted
+func (c *HTTPClient) downloadChunks(ctx context.Context, url string, res *http.Response, fd *os.File, n int, cb DlCallback) (err error) {
+	size := res.ContentLength
+	chunkSize := (size + int64(n) - 1) / int64(n)
+	// If-Range makes sure the chunks are from the same content
+	validator := res.Header.Get("ETag")
+	if validator == "" || strings.HasPrefix(validator, "W/") {
+		validator = res.Header.Get("Last-Modified")
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	var (
+		wg       sync.WaitGroup
+		mux      sync.Mutex
+		firstErr error
+		read     int64
+	)
+	if cb != nil {
+		cb(0, size)
+	}
+	progress := func(n int64) {
+		if cb == nil {
+			return
+		}
+		mux.Lock()
+		defer mux.Unlock()
+		read += n
+		cb(read, size)
+	}
+	fail := func(err error) {
+		mux.Lock()
+		defer mux.Unlock()
+		if firstErr == nil {
+			firstErr = err
+			cancel()
+		}
+	}
+	for i := 0; i < n; i++ {
+		start := int64(i) * chunkSize
+		if start >= size {
+			break
+		}
+		end := start + chunkSize
+		if end > size {
+			end = size
+		}
+		wg.Add(1)
+		go func(i int, start, end int64) {
+			defer wg.Done()
+			var body io.ReadCloser
+			if i == 0 {
+				body = res.Body
+			} else {
+				var err error
+				if body, err = c.getRange(ctx, url, start, end, size, validator); err != nil {
+					fail(err)
+					return
+				}
+				defer body.Close()
+			}
+			w := &progressWriter{Writer: io.NewOffsetWriter(fd, start), cb: progress}
+			if _, err := io.CopyN(w, c.scheduler().Reader(ctx, body), end-start); err != nil {
+				if err == io.EOF {
+					err = io.ErrUnexpectedEOF
+				}
+				fail(err)
+			}
+		}(i, start, end)
+	}
+	wg.Wait()
+	return firstErr
+}
+
+// getRange requests the bytes [start, end) of the url
+func (c *HTTPClient) getRange(ctx context.Context, url string, start, end int64, size int64, validator string) (body io.ReadCloser, err error) {
+	var req *http.Request
+	if req, err = c.NewRequestWithContext(ctx, "GET", url, nil); err != nil {
+		return
+	}
+	req.Header.Set("Range", fmt.Sprintf("bytes=%d-%d", start, end-1))
+	if validator != "" {
+		req.Header.Set("If-Range", validator)
+	}
+	var res *http.Response
+	if res, err = c.Do(req); err != nil {
+		return
+	}
+	switch res.StatusCode {
+	case http.StatusPartialContent:
+	case http.StatusOK:
+		res.Body.Close()
+		return nil, errRangeIgnored
+	default:
+		res.Body.Close()
+		return nil, &HttpStatusError{
+			Code: res.StatusCode,
+		}
+	}
+	expect := fmt.Sprintf("bytes %d-%d/%d", start, end-1, size)
+	if cr := res.Header.Get("Content-Range"); cr != expect {
+		res.Body.Close()
+		return nil, &ContentRangeErr{ContentRange: cr, Expect: expect}
+	}
+	return res.Body, nil
+}
+
+type progressWriter struct {
+	Writer io.Writer
+	cb     func(n int64)
+}
+
+func (w *progressWriter) Write(buf []byte) (n int, err error) {
+	n, err = w.Writer.Write(buf)
+	if n > 0 {
+		w.cb((int64)(n))
+	}
+	return
+}
@@ -0,0 +1,101 @@
+package installer
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// DisabledModsDir is the directory that client-only mods are moved into
+const DisabledModsDir = "mods-disabled"
+
+// clientEntrypoints are the fabric/quilt entrypoints that only be invoked on the client
+var clientEntrypoints = map[string]bool{
+	"client":         true,
+	"client_init":    true,
+	"modmenu":        true,
+	"rei_client":     true,
+	"emi":            true,
+	"jei_mod_plugin": true,
+}
+
+type DisabledMod struct {
+	File   string `json:"file"` // path relative to the server directory before moved
+	Id     string `json:"id"`
+	Reason string `json:"reason"`
+}
+
+// ClientOnlyReason returns the reason why the mod is client-only, or an empty string if it's not
+func ClientOnlyReason(m *ModMetadata) string {
+	switch m.Format {
+	case "fabric.mod.json", "quilt.mod.json":
+		if m.Environment == ModEnvClient {
+			return fmt.Sprintf("%s declares environment %q", m.Format, m.Environment)
+		}
+		if len(m.Entrypoints) == 0 {
+			return ""
+		}
+		for _, e := range m.Entrypoints {
+			if !clientEntrypoints[e] {
+				return ""
+			}
+		}
+		return fmt.Sprintf("%s only declares client entrypoints %v", m.Format, m.Entrypoints)
+	case "mods.toml", "neoforge.mods.toml":
+		if m.ClientSideOnly {
+			return m.Format + " declares clientSideOnly"
+		}
+		if m.DisplayTest == "IGNORE_ALL_VERSION" {
+			return fmt.Sprintf("%s declares displayTest %q", m.Format, m.DisplayTest)
+		}
+	}
+	return ""
+}
+
+// DisableClientMods moves client-only mods in the server's mods directory into DisabledModsDir,
+// the jars already in DisabledModsDir are handled by DefaultOverwritePolicy
+func DisableClientMods(dir string) (disabled []DisabledMod, err error) {
+	modsDir := filepath.Join(dir, "mods")
+	var entries []os.DirEntry
+	if entries, err = os.ReadDir(modsDir); err != nil {
+		if os.IsNotExist(err) {
+			err = nil
+		}
+		return
+	}
+	for _, e := range entries {
+		if e.IsDir() || !strings.EqualFold(filepath.Ext(e.Name()), ".jar") {
+			continue
+		}
+		file := filepath.Join(modsDir, e.Name())
+		metas, er := ReadModMetadata(file)
+		if er != nil {
+			loger.Debugf("Skipped %q: %v", file, er)
+			continue
+		}
+		var reasons []string
+		for _, m := range metas {
+			reason := ClientOnlyReason(m)
+			if reason == "" {
+				reasons = nil
+				break
+			}
+			reasons = append(reasons, reason)
+		}
+		if len(reasons) == 0 {
+			continue
+		}
+		if err = placeFile(file, filepath.Join(dir, DisabledModsDir, e.Name()), 0644, DefaultOverwritePolicy); err != nil {
+			return
+		}
+		d := DisabledMod{
+			File:   filepath.ToSlash(filepath.Join("mods", e.Name())),
+			Id:     metas[0].Id,
+			Reason: strings.Join(reasons, "; "),
+		}
+		loger.Infof("Disabled client-only mod %s (%s): %s", d.Id, d.File, d.Reason)
+		disabled = append(disabled, d)
+	}
+	return
+}
@@ -0,0 +1,353 @@
+package installer
+
+import (
+	"archive/zip"
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+)
+
+// DetectedServer is the server found in a directory by DetectServer
+type DetectedServer struct {
+	Dir           string `json:"dir"`
+	Type          string `json:"type"`
+	GameVersion   string `json:"gameVersion,omitempty"`
+	LoaderVersion string `json:"loaderVersion,omitempty"`
+	// Executable is relative to Dir, slash separated
+	Executable string `json:"executable,omitempty"`
+	// Evidence are the files that the result is based on
+	Evidence []string `json:"evidence"`
+}
+
+type ServerNotDetectedErr struct {
+	Dir string
+}
+
+func (e *ServerNotDetectedErr) Error() string {
+	return fmt.Sprintf("No known server is found in %q", e.Dir)
+}
+
+// The Main-Class of the server jars
+var detectMainClasses = map[string]string{
+	"net.fabricmc.installer.ServerLauncher":                       "fabric",
+	"net.fabricmc.loader.launch.server.FabricServerLauncher":      "fabric",
+	"net.fabricmc.loader.impl.launch.server.FabricServerLauncher": "fabric",
+	"org.quiltmc.loader.impl.launch.server.QuiltServerLauncher":   "quilt",
+	"net.minecraftforge.fml.relauncher.ServerLaunchWrapper":       "forge",
+	"io.papermc.paperclip.Main":                                   "paper",
+	"io.papermc.paperclip.Paperclip":                              "paper",
+	"com.destroystokyo.paperclip.Paperclip":                       "paper",
+	"org.bukkit.craftbukkit.Main":                                 "spigot",
+	"org.bukkit.craftbukkit.bootstrap.Main":                       "spigot",
+	"net.minecraft.server.Main":                                   "vanilla",
+	"net.minecraft.server.MinecraftServer":                        "vanilla",
+	"net.minecraft.bundler.Main":                                  "vanilla",
+}
+
+// detectOrder is the order of the jar kinds, the loaders come first since they launch the vanilla jar in the same directory
+var detectOrder = []string{"quilt", "fabric", "forge", "paper", "spigot", "vanilla"}
+
+// detectLibraries are the loaders installed into libraries/ by their installers
+var detectLibraries = []struct {
+	Type string
+	Path string
+	// GameLoader reports whether the versions are "<game>-<loader>",
+	// otherwise they are neoforge versions, which may have a suffix such as "-beta"
+	GameLoader bool
+}{
+	{"neoforge", "net/neoforged/neoforge", false},
+	{"neoforge", "net/neoforged/forge", true},
+	{"forge", "net/minecraftforge/forge", true},
+}
+
+// detectJar is a jar in the root of the server directory
+type detectJar struct {
+	Name     string
+	Kind     string
+	Manifest StringMap
+	// Version is the id of version.json
+	Version string
+	// Bundled are the ids in META-INF/versions.list of the bundler jars
+	Bundled []string
+	// Install is install.properties of the fabric server launcher
+	Install StringMap
+}
+
+// DetectServer finds the server type and versions of a server directory that is not installed by this program,
+// by the libraries installed by forge and neoforge, and the launcher, bundler or vanilla jars in the directory
+func DetectServer(dir string) (s *DetectedServer, err error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return
+	}
+	var jars []*detectJar
+	for _, e := range entries {
+		if e.IsDir() || !strings.EqualFold(filepath.Ext(e.Name()), ".jar") {
+			continue
+		}
+		j, err := readDetectJar(filepath.Join(dir, e.Name()))
+		if err != nil {
+			loger.Debugf("Couldn't read %q: %v", e.Name(), err)
+			continue
+		}
+		jars = append(jars, j)
+	}
+	s = &DetectedServer{Dir: dir}
+	if s.detectLibraries(jars) {
+		return
+	}
+	for _, kind := range detectOrder {
+		for _, j := range jars {
+			if j.Kind == kind {
+				s.detectJar(j, jars)
+				return
+			}
+		}
+	}
+	return nil, &ServerNotDetectedErr{dir}
+}
+
+func readDetectJar(path string) (j *detectJar, err error) {
+	r, err := zip.OpenReader(path)
+	if err != nil {
+		return
+	}
+	defer r.Close()
+	j = &detectJar{
+		Name:     filepath.Base(path),
+		Manifest: readJarManifest(&r.Reader),
+	}
+	j.Kind = detectMainClasses[j.Manifest["Main-Class"]]
+	if data, err := readZipFile(&r.Reader, "version.json"); err == nil {
+		var version struct {
+			Id string `json:"id"`
+		}
+		if json.Unmarshal(data, &version) == nil {
+			j.Version = version.Id
+		}
+	}
+	if data, err := readZipFile(&r.Reader, "META-INF/versions.list"); err == nil {
+		// each line is "<sha256>\t<id>\t<path>"
+		for _, line := range strings.Split(string(data), "\n") {
+			if fields := strings.Split(strings.TrimSpace(line), "\t"); len(fields) == 3 {
+				j.Bundled = append(j.Bundled, fields[1])
+			}
+		}
+	}
+	if data, err := readZipFile(&r.Reader, "install.properties"); err == nil {
+		j.Install = readProperties(bytes.NewReader(data))
+		if j.Kind == "" && j.Install["fabric-loader-version"] != "" {
+			j.Kind = "fabric"
+		}
+	}
+	return
+}
+
+func readProperties(r io.Reader) (props StringMap) {
+	props = make(StringMap)
+	sc := bufio.NewScanner(r)
+	for sc.Scan() {
+		if key, value, ok := parsePropertyLine(strings.TrimRight(sc.Text(), "\r")); ok {
+			props[key] = value
+		}
+	}
+	return
+}
+
+// latestLibrary returns the latest version in libraries/<path>, the old versions may be left there after an update
+func (s *DetectedServer) latestLibrary(path string) (version string) {
+	entries, err := os.ReadDir(filepath.Join(s.Dir, "libraries", filepath.FromSlash(path)))
+	if err != nil {
+		return
+	}
+	for _, e := range entries {
+		if e.IsDir() && (version == "" || compareVersionStrings(e.Name(), version) > 0) {
+			version = e.Name()
+		}
+	}
+	if version != "" {
+		s.Evidence = append(s.Evidence, "libraries/"+path+"/"+version)
+	}
+	return
+}
+
+// detectLibraries detects forge and neoforge, which are launched by the scripts since 1.17
+func (s *DetectedServer) detectLibraries(jars []*detectJar) bool {
+	for _, lib := range detectLibraries {
+		version := s.latestLibrary(lib.Path)
+		if version == "" {
+			continue
+		}
+		s.Type = lib.Type
+		if game, loader, ok := strings.Cut(version, "-"); ok && lib.GameLoader {
+			s.GameVersion, s.LoaderVersion = game, loader
+		} else {
+			// neoforge versions are <minor>.<patch>.<build> of minecraft 1.<minor>.<patch>
+			s.LoaderVersion = version
+			if parts := strings.Split(version, "."); len(parts) >= 2 {
+				s.GameVersion = "1." + parts[0]
+				if parts[1] != "0" {
+					s.GameVersion += "." + parts[1]
+				}
+			}
+		}
+		s.Executable = s.findScript("libraries/" + lib.Path + "/" + version)
+		if s.Executable == "" {
+			for _, j := range jars {
+				if j.Kind == "forge" {
+					s.Executable = j.Name
+					break
+				}
+			}
+		}
+		return true
+	}
+	return false
+}
+
+// findScript returns the start script that refers to the library
+func (s *DetectedServer) findScript(library string) string {
+	exts := []string{".sh", ".bat"}
+	if runtime.GOOS == "windows" {
+		exts[0], exts[1] = exts[1], exts[0]
+	}
+	entries, err := os.ReadDir(s.Dir)
+	if err != nil {
+		return ""
+	}
+	for _, ext := range exts {
+		for _, e := range entries {
+			if e.IsDir() || !strings.EqualFold(filepath.Ext(e.Name()), ext) {
+				continue
+			}
+			data, err := os.ReadFile(filepath.Join(s.Dir, e.Name()))
+			if err == nil && bytes.Contains(data, []byte(library)) {
+				s.Evidence = append(s.Evidence, e.Name())
+				return e.Name()
+			}
+		}
+	}
+	return ""
+}
+
+func (s *DetectedServer) detectJar(j *detectJar, jars []*detectJar) {
+	s.Type = j.Kind
+	s.Executable = j.Name
+	s.Evidence = append(s.Evidence, j.Name+": Main-Class "+j.Manifest["Main-Class"])
+	switch j.Kind {
+	case "fabric", "quilt":
+		s.GameVersion = j.Install["game-version"]
+		s.LoaderVersion = j.Install["fabric-loader-version"]
+		if j.Install != nil {
+			s.Evidence = append(s.Evidence, j.Name+": install.properties")
+		}
+		if s.GameVersion == "" {
+			s.GameVersion = s.launcherGameVersion(j.Kind, jars)
+		}
+		if s.GameVersion == "" {
+			// the intermediary mappings have the same version as minecraft
+			s.GameVersion = s.latestLibrary("net/fabricmc/intermediary")
+		}
+		if s.LoaderVersion == "" {
+			if j.Kind == "fabric" {
+				s.LoaderVersion = s.latestLibrary("net/fabricmc/fabric-loader")
+			} else {
+				s.LoaderVersion = s.latestLibrary("org/quiltmc/quilt-loader")
+			}
+		}
+	case "forge":
+		// forge-<minecraft>-<forge>[-universal].jar, or the id <minecraft>-forge<minecraft>-<forge> of version.json
+		if name := strings.TrimSuffix(strings.TrimSuffix(j.Name, ".jar"), "-universal"); strings.HasPrefix(name, "forge-") {
+			s.GameVersion, s.LoaderVersion, _ = strings.Cut(strings.TrimPrefix(name, "forge-"), "-")
+		} else if game, loader, ok := strings.Cut(j.Version, "-forge"); ok {
+			s.GameVersion, s.LoaderVersion = game, strings.TrimPrefix(strings.TrimPrefix(loader, game), "-")
+		}
+	case "paper", "spigot":
+		for _, id := range j.Bundled {
+			// paper-1.20.1 or spigot-1.20.1-R0.1-SNAPSHOT
+			if game, ok := strings.CutPrefix(id, j.Kind+"-"); ok {
+				s.GameVersion, _, _ = strings.Cut(game, "-R")
+				break
+			}
+		}
+		// such as "git-Paper-196 (MC: 1.20.1)" and "3871-Spigot-d2eba2c-3f9263b (MC: 1.20.1)"
+		impl := j.Manifest["Implementation-Version"]
+		if s.GameVersion == "" {
+			if _, mc, ok := strings.Cut(impl, "(MC: "); ok {
+				s.GameVersion = strings.TrimSuffix(mc, ")")
+			}
+		}
+		if s.GameVersion == "" {
+			s.GameVersion = j.Version
+		}
+		if build, ok := strings.CutPrefix(impl, "git-Paper-"); ok && j.Kind == "paper" {
+			s.LoaderVersion, _, _ = strings.Cut(build, " ")
+		}
+	case "vanilla":
+		s.GameVersion = j.Version
+		if s.GameVersion == "" {
+			// the jars before 1.14 have no version.json
+			if v, ok := strings.CutPrefix(strings.TrimSuffix(j.Name, ".jar"), "minecraft_server."); ok {
+				s.GameVersion = v
+			}
+		}
+	}
+}
+
+// launcherGameVersion returns the version of the vanilla jar launched by the fabric or quilt launcher
+func (s *DetectedServer) launcherGameVersion(kind string, jars []*detectJar) string {
+	name := kind + "-server-launcher.properties"
+	if _, err := os.Stat(filepath.Join(s.Dir, name)); err != nil {
+		return ""
+	}
+	s.Evidence = append(s.Evidence, name)
+	serverJar := "server.jar"
+	if props, err := ReadServerProperties(filepath.Join(s.Dir, name)); err == nil {
+		if v, ok := props.Get("serverJar"); ok {
+			serverJar = v
+		}
+	}
+	for _, j := range jars {
+		if j.Name == serverJar && j.Version != "" {
+			s.Evidence = append(s.Evidence, j.Name+": version.json")
+			return j.Version
+		}
+	}
+	return ""
+}
+
+// Record writes the server into the install manifest, the recorded mods and plugins are kept
+func (s *DetectedServer) Record(m *InstallManifest) {
+	m.ServerType = s.Type
+	m.GameVersion = s.GameVersion
+	m.LoaderVersion = s.LoaderVersion
+	if s.Executable != "" {
+		m.Executable = s.Executable
+	}
+}
+
+func (s *DetectedServer) WriteText(w io.Writer) (err error) {
+	var b strings.Builder
+	fmt.Fprintf(&b, "Detected %s server in %q\n", s.Type, s.Dir)
+	if s.GameVersion != "" {
+		fmt.Fprintf(&b, "  minecraft: %s\n", s.GameVersion)
+	}
+	if s.LoaderVersion != "" {
+		fmt.Fprintf(&b, "  loader: %s\n", s.LoaderVersion)
+	}
+	if s.Executable != "" {
+		fmt.Fprintf(&b, "  executable: %s\n", s.Executable)
+	}
+	b.WriteString("  found by:\n")
+	for _, e := range s.Evidence {
+		fmt.Fprintf(&b, "    %s\n", e)
+	}
+	_, err = io.WriteString(w, b.String())
+	return
+}
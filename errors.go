@@ -47,3 +47,19 @@ type ContentLengthNotMatchErr struct {
 func (e *ContentLengthNotMatchErr) Error() string {
 	return fmt.Sprintf("Unexpect content length %d, expect %d", e.ContentLength, e.Expect)
 }
+
+type PluginNotInstalledErr struct {
+	Id string
+}
+
+func (e *PluginNotInstalledErr) Error() string {
+	return fmt.Sprintf("Plugin %q is not installed", e.Id)
+}
+
+type UnknownPluginSourceErr struct {
+	Source string
+}
+
+func (e *UnknownPluginSourceErr) Error() string {
+	return fmt.Sprintf("Unknown plugin source %q", e.Source)
+}
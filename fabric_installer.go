@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"net/url"
 	"path/filepath"
+	"strings"
 )
 
 type (
@@ -13,6 +14,20 @@ type (
 		Version string `json:"version"`
 		Stable  bool   `json:"stable"`
 	}
+	FabricGameVersion struct {
+		Version string `json:"version"`
+		Stable  bool   `json:"stable"`
+	}
+	FabricLoaderInfo struct {
+		Separator string `json:"separator"`
+		Build     int    `json:"build"`
+		Maven     string `json:"maven"`
+		Version   string `json:"version"`
+		Stable    bool   `json:"stable"`
+	}
+	FabricLoaderVersion struct {
+		Loader FabricLoaderInfo `json:"loader"`
+	}
 
 	FabricInstaller struct {
 		MetaUrl string // Default is "https://meta.fabricmc.net"
@@ -23,19 +38,52 @@ var DefaultFabricInstaller = &FabricInstaller{
 	MetaUrl: "https://meta.fabricmc.net",
 }
 var _ Installer = DefaultFabricInstaller
+var _ Planner = DefaultFabricInstaller
+var _ ResolvedPlanner = DefaultFabricInstaller
+var _ VersionSource = DefaultFabricInstaller
 
 func init() {
-	Installers["fabric"] = DefaultFabricInstaller
+	MustRegister(DefaultFabricInstaller, InstallerInfo{
+		Name:         "fabric",
+		DisplayName:  "Fabric",
+		Description:  "Fabric server launcher, the libraries are downloaded when the server starts",
+		RequiresJava: true,
+	})
 }
 
 const fabricServerLauncherProfile = "fabric-server-launcher.properties"
-const fabricServerLauncherLink = "https://meta.fabricmc.net/v2/versions/loader/%s/%s/stable/server/jar"
+const fabricServerLauncherPath = "/v2/versions/loader/%s/%s/stable/server/jar"
 
 func (r *FabricInstaller) Install(path, name string, target string) (installed string, err error) {
-	return r.InstallWithLoader(path, name, target, "")
+	plan, err := r.Plan(path, name, target)
+	if err != nil {
+		return
+	}
+	return plan.Execute()
+}
+
+func (r *FabricInstaller) Plan(path, name string, target string) (plan *InstallPlan, err error) {
+	var resolved ResolvedVersion
+	if resolved, err = resolveInstallTarget(r, target); err != nil {
+		return
+	}
+	return r.PlanResolved(path, name, resolved)
+}
+
+func (r *FabricInstaller) PlanResolved(path, name string, version ResolvedVersion) (*InstallPlan, error) {
+	return r.PlanWithLoader(path, name, version.Game, version.Loader)
 }
 
 func (r *FabricInstaller) InstallWithLoader(path, name string, target string, loader string) (installed string, err error) {
+	plan, err := r.PlanWithLoader(path, name, target, loader)
+	if err != nil {
+		return
+	}
+	return plan.Execute()
+}
+
+// PlanWithLoader plans the fabric server, loader is the fabric loader version, empty means latest stable
+func (r *FabricInstaller) PlanWithLoader(path, name string, target string, loader string) (plan *InstallPlan, err error) {
 	foundVersion := target
 	if target == "" || target == "latest" || target == "latest-snapshot" {
 		var versions VanillaVersions
@@ -55,16 +103,18 @@ func (r *FabricInstaller) InstallWithLoader(path, name string, target string, lo
 		loader = "stable"
 	}
 
-	serverLauncherUrl := fmt.Sprintf(fabricServerLauncherLink, target, loader)
-	loger.Infof("Getting fabric server launcher %s at %q...", foundVersion, serverLauncherUrl)
-	installed = filepath.Join(path, name+".jar")
-	if err = DefaultHTTPClient.Download(serverLauncherUrl, installed, 0644, nil, -1,
-		downloadingCallback(serverLauncherUrl)); err != nil {
-		return
-	}
-	return installed, nil
+	serverLauncherUrl := strings.TrimSuffix(r.MetaUrl, "/") + fmt.Sprintf(fabricServerLauncherPath, target, loader)
+	loger.Infof("Planning fabric server launcher %s at %q...", foundVersion, serverLauncherUrl)
+	plan = newInstallPlan("fabric", path)
+	plan.Game = target
+	plan.Loader = loader
+	plan.Installed = filepath.Join(path, name+".jar")
+	// the launcher is generated by fabric meta without checksums, and its size is filled by ProbeSizes
+	plan.download(serverLauncherUrl, plan.Installed, 0, nil).Executable = true
+	return plan, nil
 }
 
+// ListVersions returns the versions of the fabric installer, use ListVersionEntries for the minecraft and the loader versions
 func (r *FabricInstaller) ListVersions(snapshot bool) (versions []string, err error) {
 	vs, err := r.GetInstallers()
 	if err != nil {
@@ -88,3 +138,52 @@ func (r *FabricInstaller) GetInstallers() (res []FabricInstallerVersion, err err
 	}
 	return
 }
+
+func (r *FabricInstaller) GetGameVersions() (res []FabricGameVersion, err error) {
+	tg, err := url.JoinPath(r.MetaUrl, "v2", "versions", "game")
+	if err != nil {
+		return
+	}
+	if err = DefaultHTTPClient.GetJson(tg, &res); err != nil {
+		return
+	}
+	return
+}
+
+func (r *FabricInstaller) GetLoaderVersions(gameVersion string) (res []FabricLoaderVersion, err error) {
+	tg, err := url.JoinPath(r.MetaUrl, "v2", "versions", "loader", gameVersion)
+	if err != nil {
+		return
+	}
+	if err = DefaultHTTPClient.GetJson(tg, &res); err != nil {
+		return
+	}
+	return
+}
+
+func (r *FabricInstaller) GameVersions() (versions []McVersion, err error) {
+	vs, err := r.GetGameVersions()
+	if err != nil {
+		return
+	}
+	versions = make([]McVersion, len(vs))
+	for i, v := range vs {
+		versions[i] = mcVersionOf(v.Version, v.Stable)
+	}
+	return
+}
+
+func (r *FabricInstaller) LoaderVersions(gameVersion string) (versions []LoaderVersion, err error) {
+	vs, err := r.GetLoaderVersions(gameVersion)
+	if err != nil {
+		return
+	}
+	versions = make([]LoaderVersion, len(vs))
+	for i, v := range vs {
+		versions[i] = LoaderVersion{
+			Version: v.Loader.Version,
+			Stable:  v.Loader.Stable,
+		}
+	}
+	return
+}
@@ -1,41 +1,81 @@
 package installer
 
 import (
-	"context"
 	"net/url"
-	"os"
-	"os/exec"
 	"path/filepath"
 	"runtime"
+	"sort"
 	"strings"
 )
 
 type (
 	ForgeInstaller struct {
-		MavenUrl string // Default is "https://maven.minecraftforge.net"
+		MavenUrl      string // Default is "https://maven.minecraftforge.net"
+		PromotionsUrl string // Default is "https://files.minecraftforge.net/net/minecraftforge/forge/promotions_slim.json"
+	}
+
+	ForgePromotions struct {
+		Homepage string    `json:"homepage"`
+		Promos   StringMap `json:"promos"`
 	}
 )
 
 var DefaultForgeInstaller = &ForgeInstaller{
-	MavenUrl: "https://maven.minecraftforge.net",
+	MavenUrl:      "https://maven.minecraftforge.net",
+	PromotionsUrl: "https://files.minecraftforge.net/net/minecraftforge/forge/promotions_slim.json",
 }
 var _ Installer = DefaultForgeInstaller
+var _ Planner = DefaultForgeInstaller
+var _ ResolvedPlanner = DefaultForgeInstaller
+var _ VersionSource = DefaultForgeInstaller
 
 func init() {
-	Installers["forge"] = DefaultForgeInstaller
+	MustRegister(DefaultForgeInstaller, InstallerInfo{
+		Name:         "forge",
+		DisplayName:  "Forge",
+		Description:  "Minecraft Forge server, installed by the official forge installer",
+		RequiresJava: true,
+	})
 }
 
-var v1_17 = Version{
+var v1_17 = McVersion{
+	Id:    "1.17",
+	Type:  McRelease,
 	Major: 1,
 	Minor: 17,
 	Patch: 0,
 }
 
 func (r *ForgeInstaller) Install(path, name string, target string) (installed string, err error) {
-	return r.InstallWithLoader(path, name, target, "")
+	plan, err := r.Plan(path, name, target)
+	if err != nil {
+		return
+	}
+	return plan.Execute()
+}
+
+func (r *ForgeInstaller) Plan(path, name string, target string) (plan *InstallPlan, err error) {
+	var resolved ResolvedVersion
+	if resolved, err = resolveInstallTarget(r, target); err != nil {
+		return
+	}
+	return r.PlanResolved(path, name, resolved)
+}
+
+func (r *ForgeInstaller) PlanResolved(path, name string, version ResolvedVersion) (*InstallPlan, error) {
+	return r.PlanWithLoader(path, name, version.Game, version.Loader)
 }
 
 func (r *ForgeInstaller) InstallWithLoader(path, name string, target string, loader string) (installed string, err error) {
+	plan, err := r.PlanWithLoader(path, name, target, loader)
+	if err != nil {
+		return
+	}
+	return plan.Execute()
+}
+
+// PlanWithLoader plans the forge server, loader is the forge version, empty means latest
+func (r *ForgeInstaller) PlanWithLoader(path, name string, target string, loader string) (plan *InstallPlan, err error) {
 	foundVersion := target
 	if target == "" || target == "latest" || target == "latest-snapshot" {
 		if target == "latest-snapshot" {
@@ -52,10 +92,13 @@ func (r *ForgeInstaller) InstallWithLoader(path, name string, target string, loa
 
 	var lessV1_17 bool
 	{
-		var v Version
-		if v, err = VersionFromString(target); err != nil {
+		var v McVersion
+		if v, err = ParseMcVersion(target); err != nil {
 			return
 		}
+		if v.Type == McSnapshot || v.Type == McAprilFools {
+			return nil, &VersionNotFoundErr{"forge-" + target}
+		}
 		lessV1_17 = v.Less(v1_17)
 	}
 
@@ -72,51 +115,38 @@ func (r *ForgeInstaller) InstallWithLoader(path, name string, target string, loa
 	if err != nil {
 		return
 	}
-	loger.Infof("Getting forge server installer %s at %q...", foundVersion, forgeInstallerUrl)
-	var installerJar string
-	if installerJar, err = DefaultHTTPClient.DownloadTmp(forgeInstallerUrl, "forge-installer-*.jar", 0644, nil, -1,
-		downloadingCallback(forgeInstallerUrl)); err != nil {
-		return
-	}
-
-	ctx, cancel := context.WithCancel(context.Background())
-	defer cancel()
-	javapath, err := lookJavaPath()
-	if err != nil {
-		return
-	}
-	cmd := exec.CommandContext(ctx, javapath, "-jar", installerJar, "--installServer")
-	cmd.Dir = path
-	cmd.Stdout = os.Stdout
-	cmd.Stderr = os.Stdout
-	loger.Infof("Running %q...", cmd.String())
-	if err = cmd.Run(); err != nil {
+	plan = newInstallPlan("forge", path)
+	plan.Game = target
+	plan.Loader = strings.TrimPrefix(version, target+"-")
+	if err = plan.runOrRestore("forge/"+version, path, nil, func() error {
+		loger.Infof("Planning forge server installer %s at %q...", foundVersion, forgeInstallerUrl)
+		installerJar := filepath.Join(PlanTempDir, "forge-"+version+"-installer.jar")
+		plan.download(forgeInstallerUrl, installerJar, 0, nil)
+		plan.run(path, "java", "-jar", installerJar, "--installServer")
+		return nil
+	}); err != nil {
 		return
 	}
 
 	if lessV1_17 { // < 1.17 use forge-<minecraft_version>-<loader_version>.jar
-		installed = filepath.Join(path, name+".jar")
-		if err = renameIfNotExist(filepath.Join(path, "forge-"+version+".jar"), installed, 0644); err != nil {
-			return
-		}
+		plan.Installed = filepath.Join(path, name+".jar")
+		plan.move(filepath.Join(path, "forge-"+version+".jar"), plan.Installed, 0644).Executable = true
 		return
 	}
 	// >= 1.17 use run.sh or run.bat
 	installedSh := filepath.Join(path, name+".sh")
-	if err = renameIfNotExist(filepath.Join(path, "run.sh"), installedSh, 0744); err != nil {
-		return
-	}
+	plan.move(filepath.Join(path, "run.sh"), installedSh, 0744).Executable = true
 	installedBat := filepath.Join(path, name+".bat")
-	if err = renameIfNotExist(filepath.Join(path, "run.bat"), installedBat, 0744); err != nil {
-		return
-	}
-	installed = installedSh
+	plan.move(filepath.Join(path, "run.bat"), installedBat, 0744).Executable = true
+	plan.Installed = installedSh
 	if runtime.GOOS == "windows" {
-		installed = installedBat
+		plan.Installed = installedBat
 	}
 	return
 }
 
+// ListVersions returns the forge versions of all the minecraft versions,
+// use ListVersionEntries for the minecraft versions that each forge version is for
 func (r *ForgeInstaller) ListVersions(snapshot bool) (versions []string, err error) {
 	data, err := r.GetInstallerVersions()
 	if err != nil {
@@ -154,3 +184,56 @@ func (r *ForgeInstaller) GetLatestInstaller(target string) (version string, err
 	}
 	return
 }
+
+func (r *ForgeInstaller) GetPromotions() (res ForgePromotions, err error) {
+	if err = DefaultHTTPClient.GetJson(r.PromotionsUrl, &res); err != nil {
+		return
+	}
+	return
+}
+
+func (r *ForgeInstaller) GameVersions() (versions []McVersion, err error) {
+	data, err := r.GetInstallerVersions()
+	if err != nil {
+		return
+	}
+	seen := make(map[string]bool)
+	for _, v := range data.Versioning.Versions {
+		game, _, ok := strings.Cut(v, "-")
+		if !ok || seen[game] {
+			continue
+		}
+		seen[game] = true
+		versions = append(versions, mcVersionOf(game, true))
+	}
+	sort.SliceStable(versions, func(i, j int) bool { return versions[j].Less(versions[i]) })
+	return
+}
+
+func (r *ForgeInstaller) LoaderVersions(gameVersion string) (versions []LoaderVersion, err error) {
+	data, err := r.GetInstallerVersions()
+	if err != nil {
+		return
+	}
+	promos, err := r.GetPromotions()
+	if err != nil {
+		loger.Warnf("Couldn't get forge promotions: %v", err)
+		err = nil
+	}
+	recommended := promos.Promos[gameVersion+"-recommended"]
+	versions = make([]LoaderVersion, 0, 8)
+	prefix := gameVersion + "-"
+	for _, v := range data.Versioning.Versions {
+		if loader, ok := strings.CutPrefix(v, prefix); ok {
+			versions = append(versions, LoaderVersion{
+				Version:     loader,
+				Stable:      true,
+				Recommended: loader == recommended,
+			})
+		}
+	}
+	sort.SliceStable(versions, func(i, j int) bool {
+		return compareVersionStrings(versions[j].Version, versions[i].Version) < 0
+	})
+	return
+}
@@ -3,8 +3,10 @@ module github.com/kmcsr/server-installer
 go 1.20
 
 require (
+	github.com/BurntSushi/toml v1.5.0
 	github.com/kmcsr/go-logger v1.2.1
 	golang.org/x/sys v0.0.0-20220919091848-fb04ddd9f9c8
+	gopkg.in/yaml.v3 v3.0.1
 )
 
 require github.com/sirupsen/logrus v1.9.2 // indirect
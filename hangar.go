@@ -0,0 +1,209 @@
+package installer
+
+import (
+	"net/url"
+	"strings"
+)
+
+type (
+	HangarNamespace struct {
+		Owner string `json:"owner"`
+		Slug  string `json:"slug"`
+	}
+	HangarProject struct {
+		Name        string          `json:"name"`
+		Namespace   HangarNamespace `json:"namespace"`
+		Description string          `json:"description"`
+	}
+	HangarFileInfo struct {
+		Name       string `json:"name"`
+		SizeBytes  int64  `json:"sizeBytes"`
+		Sha256Hash string `json:"sha256Hash"`
+	}
+	HangarDownload struct {
+		FileInfo    *HangarFileInfo `json:"fileInfo"`
+		ExternalUrl string          `json:"externalUrl"`
+		DownloadUrl string          `json:"downloadUrl"`
+	}
+	HangarPluginDependency struct {
+		Name        string `json:"name"`
+		Required    bool   `json:"required"`
+		ExternalUrl string `json:"externalUrl"`
+	}
+	HangarChannel struct {
+		Name string `json:"name"`
+	}
+	HangarVersion struct {
+		Name                 string                              `json:"name"`
+		Channel              HangarChannel                       `json:"channel"`
+		Downloads            map[string]HangarDownload           `json:"downloads"`
+		PlatformDependencies map[string][]string                 `json:"platformDependencies"`
+		PluginDependencies   map[string][]HangarPluginDependency `json:"pluginDependencies"`
+	}
+	HangarPagination struct {
+		Limit  int `json:"limit"`
+		Offset int `json:"offset"`
+		Count  int `json:"count"`
+	}
+	hangarProjects struct {
+		Pagination HangarPagination `json:"pagination"`
+		Result     []HangarProject  `json:"result"`
+	}
+	hangarVersions struct {
+		Pagination HangarPagination `json:"pagination"`
+		Result     []HangarVersion  `json:"result"`
+	}
+
+	// HangarSource is a PluginSource that uses the Hangar API (or any compatible API)
+	HangarSource struct {
+		BaseUrl string // Default is "https://hangar.papermc.io/api/v1"
+	}
+)
+
+var DefaultHangarSource = &HangarSource{
+	BaseUrl: "https://hangar.papermc.io/api/v1",
+}
+var _ PluginSource = DefaultHangarSource
+
+func init() {
+	PluginSources["hangar"] = DefaultHangarSource
+}
+
+func (*HangarSource) Name() string {
+	return "hangar"
+}
+
+func hangarPlatform(platform string) string {
+	return strings.ToUpper(platform)
+}
+
+func (s *HangarSource) getJson(obj any, query url.Values, paths ...string) (err error) {
+	var link string
+	if link, err = url.JoinPath(s.BaseUrl, paths...); err != nil {
+		return
+	}
+	if len(query) > 0 {
+		link += "?" + query.Encode()
+	}
+	return DefaultHTTPClient.GetJson(link, obj)
+}
+
+func (s *HangarSource) Search(query string, platform string, gameVersion string) (plugins []PluginInfo, err error) {
+	q := url.Values{
+		"q":     {query},
+		"limit": {"25"},
+	}
+	if platform != "" {
+		q.Set("platform", hangarPlatform(platform))
+		if gameVersion != "" {
+			q.Set("version", gameVersion)
+		}
+	}
+	var res hangarProjects
+	if err = s.getJson(&res, q, "projects"); err != nil {
+		return
+	}
+	plugins = make([]PluginInfo, len(res.Result))
+	for i, p := range res.Result {
+		plugins[i] = PluginInfo{
+			Source:      s.Name(),
+			Id:          p.Namespace.Slug,
+			Name:        p.Name,
+			Author:      p.Namespace.Owner,
+			Description: p.Description,
+		}
+	}
+	return
+}
+
+func (s *HangarSource) GetVersions(slug string, platform string, gameVersion string) (versions []HangarVersion, err error) {
+	q := url.Values{
+		"limit":  {"25"},
+		"offset": {"0"},
+	}
+	if platform != "" {
+		q.Set("platform", hangarPlatform(platform))
+		if gameVersion != "" {
+			q.Set("platformVersion", gameVersion)
+		}
+	}
+	var res hangarVersions
+	if err = s.getJson(&res, q, "projects", slug, "versions"); err != nil {
+		return
+	}
+	return res.Result, nil
+}
+
+func (s *HangarSource) GetVersion(slug string, name string) (version *HangarVersion, err error) {
+	version = new(HangarVersion)
+	if err = s.getJson(version, nil, "projects", slug, "versions", name); err != nil {
+		return nil, err
+	}
+	return
+}
+
+func (s *HangarSource) Resolve(id string, version string, platform string, gameVersion string) (release *PluginRelease, err error) {
+	var v *HangarVersion
+	if version != "" {
+		if v, err = s.GetVersion(id, version); err != nil {
+			if _, ok := err.(*HttpStatusError); ok {
+				err = &VersionNotFoundErr{id + "@" + version}
+			}
+			return
+		}
+	} else {
+		var versions []HangarVersion
+		if versions, err = s.GetVersions(id, platform, gameVersion); err != nil {
+			return
+		}
+		for i, ver := range versions {
+			if _, ok := ver.Downloads[hangarPlatform(platform)]; !ok {
+				continue
+			}
+			if strings.EqualFold(ver.Channel.Name, "Release") {
+				v = &versions[i]
+				break
+			}
+			if v == nil {
+				v = &versions[i]
+			}
+		}
+		if v == nil {
+			return nil, &VersionNotFoundErr{id + " for " + platform + " " + gameVersion}
+		}
+	}
+	dl, ok := v.Downloads[hangarPlatform(platform)]
+	if !ok {
+		return nil, &AssetNotFoundErr{id + "@" + v.Name, platform + " download"}
+	}
+	release = &PluginRelease{
+		Source:   s.Name(),
+		PluginId: id,
+		Version:  v.Name,
+		Size:     -1,
+	}
+	if dl.FileInfo != nil {
+		release.FileName = dl.FileInfo.Name
+		release.Size = dl.FileInfo.SizeBytes
+		if dl.FileInfo.Sha256Hash != "" {
+			release.Hashes = StringMap{"sha256": dl.FileInfo.Sha256Hash}
+		}
+	}
+	if dl.DownloadUrl != "" {
+		release.Url = dl.DownloadUrl
+	} else if dl.ExternalUrl != "" {
+		release.Url = dl.ExternalUrl
+		loger.Warnf("Plugin %s(%s) is hosted externally at %q, hash cannot be verified", id, v.Name, dl.ExternalUrl)
+	} else {
+		return nil, &AssetNotFoundErr{id + "@" + v.Name, platform + " download"}
+	}
+	if release.FileName == "" {
+		release.FileName = id + "-" + v.Name + ".jar"
+	}
+	for _, d := range v.PluginDependencies[hangarPlatform(platform)] {
+		if d.Required && d.ExternalUrl == "" && d.Name != "" {
+			release.Dependencies = append(release.Dependencies, d.Name)
+		}
+	}
+	return
+}
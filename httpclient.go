@@ -1,6 +1,7 @@
 package installer
 
 import (
+	"context"
 	"encoding/json"
 	"encoding/xml"
 	"io"
@@ -16,6 +17,18 @@ type HTTPClient struct {
 	http.Client
 
 	UserAgent string
+	// Mirror is the url of a server started by `serve-mirror`, the links to its upstreams are rewritten to it
+	Mirror string
+	// Store is used to serve responses offline or record them, nil means always use the network
+	Store *ArtifactStore
+	// ChunkThreshold is the minimum size of the downloads that are split into parallel range requests
+	// if the server accepts ranges, zero means DefaultChunkThreshold and negative value disables it
+	ChunkThreshold int64
+	// Chunks is the number of the range requests of a download, zero means DefaultDownloadChunks.
+	// They are opened besides the connections limited by InstallPlan.MaxConnsPerHost
+	Chunks int
+	// Scheduler limits the bandwidth and the concurrent downloads, nil means DefaultScheduler
+	Scheduler *DownloadScheduler
 }
 
 var DefaultHTTPClient = &HTTPClient{
@@ -26,7 +39,11 @@ var DefaultHTTPClient = &HTTPClient{
 }
 
 func (c *HTTPClient) NewRequest(method string, url string, body io.Reader) (req *http.Request, err error) {
-	if req, err = http.NewRequest("GET", url, nil); err != nil {
+	return c.NewRequestWithContext(context.Background(), method, url, body)
+}
+
+func (c *HTTPClient) NewRequestWithContext(ctx context.Context, method string, url string, body io.Reader) (req *http.Request, err error) {
+	if req, err = http.NewRequestWithContext(ctx, method, url, body); err != nil {
 		return
 	}
 	req.Header.Set("User-Agent", c.UserAgent)
@@ -37,6 +54,15 @@ func (c *HTTPClient) Do(req *http.Request) (res *http.Response, err error) {
 	if ua := req.Header.Get("User-Agent"); ua == "" {
 		req.Header.Set("User-Agent", c.UserAgent)
 	}
+	if c.Mirror != "" {
+		if u, ok := MirrorUrl(c.Mirror, req.URL); ok {
+			loger.Debugf("Using mirror %q for %q", u, req.URL)
+			req.URL, req.Host = u, u.Host
+		}
+	}
+	if c.Store != nil {
+		return c.Store.do(c, req)
+	}
 	if res, err = c.Client.Do(req); err != nil {
 		return
 	}
@@ -116,28 +142,57 @@ func (r *progressReader) Read(buf []byte) (n int, err error) {
 }
 
 func (c *HTTPClient) DownloadTmp(url string, pattern string, mode os.FileMode, hashes StringMap, size int64, cb DlCallback) (path string, err error) {
-	var res *http.Response
-	if res, err = c.Get(url); err != nil {
+	return c.DownloadTmpContext(context.Background(), url, pattern, mode, hashes, size, cb)
+}
+
+func (c *HTTPClient) scheduler() *DownloadScheduler {
+	if c.Scheduler != nil {
+		return c.Scheduler
+	}
+	return DefaultScheduler
+}
+
+// getDownload sends the GET request of a download, and checks the status and the content length
+func (c *HTTPClient) getDownload(ctx context.Context, url string, size int64) (res *http.Response, err error) {
+	var req *http.Request
+	if req, err = c.NewRequestWithContext(ctx, "GET", url, nil); err != nil {
+		return
+	}
+	if res, err = c.Do(req); err != nil {
 		return
 	}
 	if res.StatusCode != http.StatusOK {
-		err = &HttpStatusError{
+		res.Body.Close()
+		return nil, &HttpStatusError{
 			Code: res.StatusCode,
 		}
-		return
 	}
-	if size < 0 {
-		size = res.ContentLength
-	} else if res.ContentLength < 0 && res.ContentLength != size {
-		err = &ContentLengthNotMatchErr{
+	if size >= 0 && res.ContentLength >= 0 && res.ContentLength != size {
+		res.Body.Close()
+		return nil, &ContentLengthNotMatchErr{
 			ContentLength: res.ContentLength,
 			Expect:        size,
 		}
+	}
+	return
+}
+
+// DownloadTmpContext downloads the url into a temporary file that matches the pattern.
+// Large downloads are split into parallel range requests if the server accepts them, see HTTPClient.ChunkThreshold.
+// The download waits for a slot of the scheduler with the priority of ctx, see WithDownloadPriority
+func (c *HTTPClient) DownloadTmpContext(ctx context.Context, url string, pattern string, mode os.FileMode, hashes StringMap, size int64, cb DlCallback) (path string, err error) {
+	var release func()
+	if release, err = c.scheduler().Acquire(ctx, DownloadPriority(ctx)); err != nil {
 		return
 	}
-	var r io.Reader = res.Body
-	if cb != nil {
-		r = newProgressReader(r, size, cb)
+	defer release()
+	var res *http.Response
+	if res, err = c.getDownload(ctx, url, size); err != nil {
+		return
+	}
+	defer func() { res.Body.Close() }()
+	if size < 0 {
+		size = res.ContentLength
 	}
 	dir, base := filepath.Split(pattern)
 	os.MkdirAll(dir, 0755)
@@ -151,9 +206,53 @@ func (c *HTTPClient) DownloadTmp(url string, pattern string, mode os.FileMode, h
 			os.Remove(fd.Name())
 		}
 	}(fd)
+	n := c.chunks(res)
+	if n > 1 {
+		// the chunk connections are counted against the host limit, the first one is already taken by the caller
+		extra, releaseConns := hostLimiterOf(ctx).tryAcquire(url, n-1)
+		defer releaseConns()
+		n = 1 + extra
+	}
+	if n > 1 {
+		loger.Debugf("Downloading %q in %d chunks", url, n)
+		if err = c.downloadChunks(ctx, url, res, fd, n, cb); err == nil {
+			// the hashes are checked on the reassembled file
+			if _, err = fd.Seek(0, io.SeekStart); err != nil {
+				return
+			}
+			if _, err = checkHashStream(fd, hashes, nil); err != nil {
+				return
+			}
+			return c.finishDownload(fd, mode)
+		}
+		if ctx.Err() != nil || !rangeUnsupported(err) {
+			return
+		}
+		loger.Debugf("Range requests of %q failed (%v), downloading it in a single stream", url, err)
+		res.Body.Close()
+		// the chunks are written by WriteAt, the offset of fd is still 0
+		if err = fd.Truncate(0); err != nil {
+			return
+		}
+		if res, err = c.getDownload(ctx, url, size); err != nil {
+			return
+		}
+	}
+	var r io.Reader = res.Body
+	if c.Store == nil {
+		// the store limits its own fetches, then serves the local files
+		r = c.scheduler().Reader(ctx, r)
+	}
+	if cb != nil {
+		r = newProgressReader(r, size, cb)
+	}
 	if _, err = checkHashStream(r, hashes, fd); err != nil {
 		return
 	}
+	return c.finishDownload(fd, mode)
+}
+
+func (c *HTTPClient) finishDownload(fd *os.File, mode os.FileMode) (path string, err error) {
 	if mode != 0 {
 		if err = fd.Chmod(mode); err != nil {
 			return
@@ -165,8 +264,11 @@ func (c *HTTPClient) DownloadTmp(url string, pattern string, mode os.FileMode, h
 
 func (c *HTTPClient) Download(url string, path string, mode os.FileMode, hashes StringMap, size int64, cb DlCallback) (err error) {
 	var tmppath string
-	tmppath, err = c.DownloadTmp(url, path+".*.downloading", mode, hashes, size, cb)
-	if err = renameIfNotExist(tmppath, path, 0644); err != nil {
+	if tmppath, err = c.DownloadTmp(url, path+".*.downloading", mode, hashes, size, cb); err != nil {
+		return
+	}
+	if err = placeFile(tmppath, path, 0644, DefaultOverwritePolicy); err != nil {
+		os.Remove(tmppath)
 		return
 	}
 	return
@@ -0,0 +1,226 @@
+package installer
+
+import (
+	"bufio"
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+)
+
+type (
+	// HTTPConfig is the network settings of HTTPClient, see HTTPClient.Configure
+	HTTPConfig struct {
+		// Proxy is the url of the proxy, the scheme could be [http https socks5].
+		// The environment variables HTTP_PROXY, HTTPS_PROXY and NO_PROXY are used if it's empty
+		Proxy string
+		// NoProxy are the host patterns that are connected directly when Proxy is set
+		NoProxy []string
+		// CAFiles are the PEM bundles of the root CAs that are trusted besides the system ones
+		CAFiles []string
+		// Hosts are the credentials and the headers of the hosts, the first matched one is used
+		Hosts []*HostConfig
+	}
+
+	// HostConfig is the credentials and the headers that are sent to a host
+	HostConfig struct {
+		// Host is the host name with an optional port, "*.example.com" matches the sub domains and "*" matches all hosts
+		Host     string `yaml:"host" json:"host"`
+		Username string `yaml:"username" json:"username,omitempty"`
+		Password string `yaml:"password" json:"-"`
+		// Token is sent as a bearer token instead of Username and Password
+		Token   string            `yaml:"token" json:"-"`
+		Headers map[string]string `yaml:"headers" json:"-"`
+	}
+)
+
+type UnsupportProxyErr struct {
+	Proxy string
+}
+
+func (e *UnsupportProxyErr) Error() string {
+	return fmt.Sprintf("Unsupport proxy %q, the scheme should be one of [http https socks5]", e.Proxy)
+}
+
+type NoCertificateErr struct {
+	Path string
+}
+
+func (e *NoCertificateErr) Error() string {
+	return fmt.Sprintf("No PEM certificate is found in %q", e.Path)
+}
+
+// Match reports whether the config applies to the url
+func (h *HostConfig) Match(u *url.URL) bool {
+	pattern := strings.ToLower(h.Host)
+	if pattern == "*" {
+		return true
+	}
+	if _, _, err := net.SplitHostPort(pattern); err == nil {
+		return pattern == strings.ToLower(u.Host)
+	}
+	name := strings.ToLower(u.Hostname())
+	if strings.HasPrefix(pattern, "*.") {
+		return strings.HasSuffix(name, pattern[1:])
+	}
+	return name == pattern
+}
+
+// apply sets the headers that are not set yet
+func (h *HostConfig) apply(req *http.Request) {
+	if req.Header.Get("Authorization") == "" {
+		if h.Token != "" {
+			req.Header.Set("Authorization", "Bearer "+h.Token)
+		} else if h.Username != "" {
+			req.SetBasicAuth(h.Username, h.Password)
+		}
+	}
+	for k, v := range h.Headers {
+		if req.Header.Get(k) == "" {
+			req.Header.Set(k, v)
+		}
+	}
+}
+
+func matchHost(hosts []*HostConfig, u *url.URL) *HostConfig {
+	for _, h := range hosts {
+		if h.Match(u) {
+			return h
+		}
+	}
+	return nil
+}
+
+// hostTransport applies the HostConfig to every request it sends.
+// The redirects are sent by it too, so the credentials of a host are never forwarded to another one
+type hostTransport struct {
+	base  http.RoundTripper
+	hosts []*HostConfig
+}
+
+func (t *hostTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	h := matchHost(t.hosts, req.URL)
+	if h == nil {
+		return t.base.RoundTrip(req)
+	}
+	loger.Debugf("Using the settings of host %q for %q", h.Host, req.URL)
+	// RoundTrip should not modify the request
+	req = req.Clone(req.Context())
+	h.apply(req)
+	return t.base.RoundTrip(req)
+}
+
+// Configure replaces the transport of the client with the proxy, the root CAs and the host settings
+func (c *HTTPClient) Configure(cfg HTTPConfig) (err error) {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	if cfg.Proxy != "" {
+		var proxy *url.URL
+		if proxy, err = url.Parse(cfg.Proxy); err != nil {
+			return
+		}
+		switch proxy.Scheme {
+		case "http", "https", "socks5":
+		default:
+			return &UnsupportProxyErr{cfg.Proxy}
+		}
+		noProxy := make([]*HostConfig, len(cfg.NoProxy))
+		for i, h := range cfg.NoProxy {
+			noProxy[i] = &HostConfig{Host: h}
+		}
+		transport.Proxy = func(req *http.Request) (*url.URL, error) {
+			if matchHost(noProxy, req.URL) != nil {
+				return nil, nil
+			}
+			return proxy, nil
+		}
+	}
+	if len(cfg.CAFiles) > 0 {
+		var pool *x509.CertPool
+		if pool, err = x509.SystemCertPool(); err != nil {
+			loger.Warnf("Couldn't load the system root CAs: %v", err)
+			pool = x509.NewCertPool()
+		}
+		for _, path := range cfg.CAFiles {
+			var data []byte
+			if data, err = os.ReadFile(path); err != nil {
+				return
+			}
+			if !pool.AppendCertsFromPEM(data) {
+				return &NoCertificateErr{path}
+			}
+		}
+		transport.TLSClientConfig = &tls.Config{RootCAs: pool}
+	}
+	var rt http.RoundTripper = transport
+	if len(cfg.Hosts) > 0 {
+		rt = &hostTransport{base: transport, hosts: cfg.Hosts}
+	}
+	c.Client.Transport = rt
+	return nil
+}
+
+// ReadNetrc reads the machines of a .netrc file, the default machine is matched last
+func ReadNetrc(path string) (hosts []*HostConfig, err error) {
+	fd, err := os.Open(path)
+	if err != nil {
+		return
+	}
+	defer fd.Close()
+
+	var (
+		cur, def *HostConfig
+		key      string
+		macdef   bool
+	)
+	sc := bufio.NewScanner(fd)
+	for sc.Scan() {
+		line := sc.Text()
+		if macdef {
+			// a macro ends with an empty line
+			macdef = strings.TrimSpace(line) != ""
+			continue
+		}
+		for _, tok := range strings.Fields(line) {
+			if key != "" {
+				switch key {
+				case "machine":
+					cur = &HostConfig{Host: tok}
+					hosts = append(hosts, cur)
+				case "login":
+					if cur != nil {
+						cur.Username = tok
+					}
+				case "password":
+					if cur != nil {
+						cur.Password = tok
+					}
+				}
+				key = ""
+				continue
+			}
+			switch tok {
+			case "default":
+				def = &HostConfig{Host: "*"}
+				cur = def
+			case "macdef":
+				macdef = true
+			default:
+				key = tok
+			}
+			if macdef {
+				break
+			}
+		}
+	}
+	if err = sc.Err(); err != nil {
+		return
+	}
+	if def != nil {
+		hosts = append(hosts, def)
+	}
+	return
+}
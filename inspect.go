@@ -0,0 +1,83 @@
+package installer
+
+import (
+	"archive/zip"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// JarInfo is what InspectJar found in a jar
+type JarInfo struct {
+	Path   string    `json:"path"`
+	Size   int64     `json:"size"`
+	Hashes StringMap `json:"hashes"`
+	// Manifest is the main attributes of META-INF/MANIFEST.MF
+	Manifest StringMap      `json:"manifest,omitempty"`
+	Mods     []*ModMetadata `json:"mods"`
+}
+
+// InspectJar reads the metadata of the mods or plugins in the jar without running it,
+// the sha1 and sha512 could be used to look up the jar on modrinth
+func InspectJar(filename string) (info *JarInfo, err error) {
+	f, err := mrpackFileOf(filename)
+	if err != nil {
+		return
+	}
+	var r *zip.ReadCloser
+	if r, err = zip.OpenReader(filename); err != nil {
+		return
+	}
+	defer r.Close()
+	info = &JarInfo{
+		Path:     filename,
+		Size:     f.Size,
+		Hashes:   f.Hashes,
+		Manifest: readJarManifest(&r.Reader),
+	}
+	if info.Mods, err = readZipModMetadata(&r.Reader, filename, 0); err != nil {
+		return nil, err
+	}
+	return
+}
+
+func (info *JarInfo) WriteText(w io.Writer) (err error) {
+	var b strings.Builder
+	fmt.Fprintf(&b, "%s (%s)\n", info.Path, formatSize(info.Size, "%.2f"))
+	fmt.Fprintf(&b, "  sha1: %s\n", info.Hashes["sha1"])
+	for _, m := range info.Mods {
+		writeModText(&b, m, "  ")
+	}
+	_, err = io.WriteString(w, b.String())
+	return
+}
+
+func writeModText(b *strings.Builder, m *ModMetadata, indent string) {
+	fmt.Fprintf(b, "%s%s %s", indent, m.Id, m.Version)
+	if m.Name != "" && m.Name != m.Id {
+		fmt.Fprintf(b, " (%s)", m.Name)
+	}
+	fmt.Fprintf(b, " from %s\n", m.Format)
+	indent += "  "
+	fmt.Fprintf(b, "%sloaders: %s\n", indent, strings.Join(m.Loaders, ", "))
+	if m.Environment != "" {
+		fmt.Fprintf(b, "%senvironment: %s\n", indent, m.Environment)
+	}
+	if m.Minecraft != "" {
+		fmt.Fprintf(b, "%sminecraft: %s\n", indent, m.Minecraft)
+	}
+	if len(m.Provides) > 0 {
+		fmt.Fprintf(b, "%sprovides: %s\n", indent, strings.Join(m.Provides, ", "))
+	}
+	for _, d := range m.Dependencies {
+		fmt.Fprintf(b, "%s%s %s %s", indent, d.Kind, d.Id, rangeText(d.Range))
+		if d.Side != "" && d.Side != ModEnvAny {
+			fmt.Fprintf(b, " (%s only)", d.Side)
+		}
+		b.WriteByte('\n')
+	}
+	for _, bundled := range m.Bundled {
+		fmt.Fprintf(b, "%sbundled:\n", indent)
+		writeModText(b, bundled, indent+"  ")
+	}
+}
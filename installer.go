@@ -1,22 +1,95 @@
 package installer
 
 import (
+	"fmt"
 	"sort"
 )
 
 type Installer interface {
 	// target == "" means latest
 	Install(path, name string, target string) (installed string, err error)
+	// ListVersions returns the versions that the installer knows, they are the minecraft versions for most installers,
+	// but the installer or the loader builds for fabric, forge and quilt.
+	// Use ListVersionEntries for the minecraft versions and the loader versions
 	ListVersions(snapshot bool) (versions []string, err error)
 }
 
+// InstallerInfo is the metadata of a registered installer
+type InstallerInfo struct {
+	Name        string `json:"name"`
+	DisplayName string `json:"displayName"`
+	Description string `json:"description,omitempty"`
+	// Platforms are the GOOS that the installer supports, empty means all platforms
+	Platforms []string `json:"platforms,omitempty"`
+	// RequiresJava reports whether java is needed to install the server
+	RequiresJava bool `json:"requiresJava"`
+	// Source is the file that the installer is loaded from, empty means it's built in
+	Source string `json:"source,omitempty"`
+}
+
+// Installers contains all registered installers, use Register to add an installer with its metadata
 var Installers = make(map[string]Installer, 10)
+var installerInfos = make(map[string]InstallerInfo, 10)
+
+type InstallerExistsErr struct {
+	Name string
+}
+
+func (e *InstallerExistsErr) Error() string {
+	return fmt.Sprintf("Installer %q is already registered", e.Name)
+}
+
+// Register adds the installer with the name of info
+func Register(ir Installer, info InstallerInfo) error {
+	if info.Name == "" {
+		return fmt.Errorf("Installer name cannot be empty")
+	}
+	if _, ok := Installers[info.Name]; ok {
+		return &InstallerExistsErr{info.Name}
+	}
+	if info.DisplayName == "" {
+		info.DisplayName = info.Name
+	}
+	Installers[info.Name] = ir
+	installerInfos[info.Name] = info
+	return nil
+}
+
+// MustRegister is same as Register, but panics if the installer cannot be registered
+func MustRegister(ir Installer, info InstallerInfo) {
+	if err := Register(ir, info); err != nil {
+		panic(err)
+	}
+}
 
 func Get(name string) (installer Installer, ok bool) {
 	installer, ok = Installers[name]
 	return
 }
 
+// GetInfo returns the metadata of the installer.
+// Installers that are added into Installers directly only have the name
+func GetInfo(name string) (info InstallerInfo, ok bool) {
+	if _, ok = Installers[name]; !ok {
+		return
+	}
+	if info, ok = installerInfos[name]; !ok {
+		info = InstallerInfo{Name: name, DisplayName: name}
+		ok = true
+	}
+	return
+}
+
+// GetInstallerInfos returns the metadata of all installers, sorted by name
+func GetInstallerInfos() (infos []InstallerInfo) {
+	names := GetInstallerNames()
+	infos = make([]InstallerInfo, len(names))
+	for i, name := range names {
+		infos[i], _ = GetInfo(name)
+	}
+	return
+}
+
 func GetInstallerNames() (installers []string) {
 	installers = make([]string, 0, len(Installers))
 	for name, _ := range Installers {
@@ -0,0 +1,96 @@
+package installer
+
+import (
+	"fmt"
+	"os/exec"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// JavaInfo is the java runtime found by FindJava
+type JavaInfo struct {
+	Path    string `json:"path"`
+	Version string `json:"version"`
+	Major   int    `json:"major"`
+}
+
+type JavaVersionErr struct {
+	Path   string
+	Output string
+}
+
+func (e *JavaVersionErr) Error() string {
+	return fmt.Sprintf("Couldn't read the version of java %q from output %q", e.Path, e.Output)
+}
+
+// JavaPath is the java executable used by the plans, it's searched in JAVA_HOME and PATH if empty
+var JavaPath string
+
+var javaVersionRe = regexp.MustCompile(`version "([^"]+)"`)
+
+// FindJava finds the java executable in JavaPath, JAVA_HOME or PATH, which is used by the plans, and reads its version
+func FindJava() (info *JavaInfo, err error) {
+	path, err := lookJavaPath()
+	if err != nil {
+		return
+	}
+	// java -version writes to stderr
+	out, err := exec.Command(path, "-version").CombinedOutput()
+	if err != nil {
+		return
+	}
+	matches := javaVersionRe.FindSubmatch(out)
+	if matches == nil {
+		return nil, &JavaVersionErr{Path: path, Output: strings.TrimSpace(string(out))}
+	}
+	version := (string)(matches[1])
+	major, ok := javaMajorVersion(version)
+	if !ok {
+		return nil, &JavaVersionErr{Path: path, Output: strings.TrimSpace(string(out))}
+	}
+	return &JavaInfo{
+		Path:    path,
+		Version: version,
+		Major:   major,
+	}, nil
+}
+
+// javaMajorVersion parses the major version from such as "17.0.8", "21-ea" or "1.8.0_382"
+func javaMajorVersion(version string) (major int, ok bool) {
+	version = strings.TrimPrefix(version, "1.")
+	end := strings.IndexFunc(version, func(r rune) bool { return r < '0' || r > '9' })
+	if end >= 0 {
+		version = version[:end]
+	}
+	major, err := strconv.Atoi(version)
+	return major, err == nil
+}
+
+// RequiredJava returns the minimum java major version that the minecraft version needs
+func (r *VanillaInstaller) RequiredJava(gameVersion string) (major int, err error) {
+	var res VanillaVersions
+	if res, err = r.GetVersions(); err != nil {
+		return
+	}
+	switch gameVersion {
+	case "", "latest":
+		gameVersion = res.Latest.Release
+	case "latest-snapshot":
+		gameVersion = res.Latest.Snapshot
+	}
+	for _, v := range res.Versions {
+		if v.Id == gameVersion {
+			var version VanillaVersion
+			if version, err = r.GetVersion(v.Url); err != nil {
+				return
+			}
+			// the old versions don't have javaVersion, they run on java 8
+			if major = version.JavaVersion.MajorVersion; major == 0 {
+				major = 8
+			}
+			return
+		}
+	}
+	return 0, &VersionNotFoundErr{gameVersion}
+}
@@ -0,0 +1,89 @@
+package installer
+
+import (
+	"context"
+	"net/url"
+	"sync"
+)
+
+// hostLimiter limits the number of connections opened to each host at the same time
+type hostLimiter struct {
+	limit int
+	mux   sync.Mutex
+	hosts map[string]chan struct{}
+}
+
+func newHostLimiter(limit int) *hostLimiter {
+	return &hostLimiter{
+		limit: limit,
+		hosts: make(map[string]chan struct{}),
+	}
+}
+
+func (l *hostLimiter) get(host string) chan struct{} {
+	l.mux.Lock()
+	defer l.mux.Unlock()
+	sem, ok := l.hosts[host]
+	if !ok {
+		sem = make(chan struct{}, l.limit)
+		l.hosts[host] = sem
+	}
+	return sem
+}
+
+// acquire blocks until a connection slot of the link's host is available or ctx is done
+func (l *hostLimiter) acquire(ctx context.Context, link string) (release func(), err error) {
+	if l == nil || l.limit <= 0 {
+		return func() {}, nil
+	}
+	var host string
+	if u, e := url.Parse(link); e == nil {
+		host = u.Host
+	}
+	sem := l.get(host)
+	select {
+	case sem <- struct{}{}:
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+	return func() { <-sem }, nil
+}
+
+// tryAcquire takes up to n connection slots of the link's host without blocking, it returns the number of the slots taken
+func (l *hostLimiter) tryAcquire(link string, n int) (got int, release func()) {
+	if l == nil || l.limit <= 0 {
+		return n, func() {}
+	}
+	var host string
+	if u, e := url.Parse(link); e == nil {
+		host = u.Host
+	}
+	sem := l.get(host)
+loop:
+	for got < n {
+		select {
+		case sem <- struct{}{}:
+			got++
+		default:
+			break loop
+		}
+	}
+	taken := got
+	return got, func() {
+		for i := 0; i < taken; i++ {
+			<-sem
+		}
+	}
+}
+
+type hostLimiterKey struct{}
+
+// withHostLimiter returns a context that the chunk connections of the downloads made with it are counted by the limiter
+func withHostLimiter(ctx context.Context, l *hostLimiter) context.Context {
+	return context.WithValue(ctx, hostLimiterKey{}, l)
+}
+
+func hostLimiterOf(ctx context.Context) *hostLimiter {
+	l, _ := ctx.Value(hostLimiterKey{}).(*hostLimiter)
+	return l
+}
@@ -0,0 +1,223 @@
+package installer
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// InstallManifestName is the file that records what have been installed into a server directory
+const InstallManifestName = "server-installer.json"
+
+type (
+	InstallManifest struct {
+		ServerType    string `json:"serverType,omitempty"`
+		GameVersion   string `json:"gameVersion,omitempty"`
+		LoaderVersion string `json:"loaderVersion,omitempty"`
+		Executable    string `json:"executable,omitempty"`
+		// Modpack is the modpack reference (path or url) that the server is installed from, and ModpackSha1 is its hash
+		Modpack     string `json:"modpack,omitempty"`
+		ModpackSha1 string `json:"modpackSha1,omitempty"`
+		// ModpackFiles are the files downloaded by the modpack, they are removed when the modpack is updated
+		ModpackFiles []MrpackFileMeta `json:"modpackFiles,omitempty"`
+		// DisabledMods are the client-only modpack files moved into DisabledModsDir,
+		// they are not verified, downloaded again or exported
+		DisabledMods []DisabledMod `json:"disabledMods,omitempty"`
+
+		Mods    []ManifestEntry `json:"mods,omitempty"`
+		Plugins []ManifestEntry `json:"plugins,omitempty"`
+	}
+	ManifestEntry struct {
+		Source    string    `json:"source"`
+		Id        string    `json:"id"`
+		Version   string    `json:"version"`
+		VersionId string    `json:"versionId,omitempty"`
+		Path      string    `json:"path"` // relative to the server directory, slash separated
+		Hashes    StringMap `json:"hashes,omitempty"`
+		// Ref is the id or slug that the entry is requested with, it's empty for the dependencies
+		Ref string `json:"ref,omitempty"`
+		// Pinned entries are installed with an explicit version and will not be updated
+		Pinned bool `json:"pinned,omitempty"`
+		// RequiredBy are the refs of the entries that the dependency is installed for
+		RequiredBy []string `json:"requiredBy,omitempty"`
+	}
+)
+
+// ReadInstallManifest reads the manifest in the server directory.
+// An empty manifest will be returned if the manifest does not exist
+func ReadInstallManifest(dir string) (m *InstallManifest, err error) {
+	m = new(InstallManifest)
+	var buf []byte
+	if buf, err = os.ReadFile(filepath.Join(dir, InstallManifestName)); err != nil {
+		if os.IsNotExist(err) {
+			err = nil
+		}
+		return
+	}
+	if err = json.Unmarshal(buf, m); err != nil {
+		return nil, err
+	}
+	return
+}
+
+func (m *InstallManifest) Save(dir string) (err error) {
+	var buf []byte
+	if buf, err = json.MarshalIndent(m, "", "  "); err != nil {
+		return
+	}
+	if err = os.MkdirAll(dir, 0755); err != nil {
+		return
+	}
+	return os.WriteFile(filepath.Join(dir, InstallManifestName), buf, 0644)
+}
+
+// setManifestEntry adds or replaces the entry,
+// the ref and the dependents of the replaced entry are kept, since the entry may be installed as a dependency again
+func setManifestEntry(entries []ManifestEntry, entry ManifestEntry) []ManifestEntry {
+	for i, e := range entries {
+		if e.Source == entry.Source && e.Id == entry.Id {
+			if entry.Ref == "" {
+				entry.Ref = e.Ref
+			}
+			for _, r := range e.RequiredBy {
+				entry.RequiredBy = appendRef(entry.RequiredBy, r)
+			}
+			entries[i] = entry
+			return entries
+		}
+	}
+	return append(entries, entry)
+}
+
+func appendRef(refs []string, ref string) []string {
+	for _, r := range refs {
+		if strings.EqualFold(r, ref) {
+			return refs
+		}
+	}
+	return append(refs, ref)
+}
+
+// isOrphan reports whether the entry is a dependency that none of the desired refs requires.
+// The dependencies without the recorded dependents are never orphans
+func (e ManifestEntry) isOrphan(desired map[string]bool) bool {
+	if e.Ref != "" || len(e.RequiredBy) == 0 {
+		return false
+	}
+	for _, r := range e.RequiredBy {
+		if desired[strings.ToLower(r)] {
+			return false
+		}
+	}
+	return true
+}
+
+func findManifestEntry(entries []ManifestEntry, id string) int {
+	for i, e := range entries {
+		if e.Id == id {
+			return i
+		}
+	}
+	return -1
+}
+
+// SetModpack records the modpack that the server is installed from,
+// the disabled mods that the modpack doesn't have anymore are removed
+func (m *InstallManifest) SetModpack(ref string, sha1 string, files []MrpackFileMeta) {
+	m.Modpack = ref
+	m.ModpackSha1 = sha1
+	m.ModpackFiles = files
+	paths := make(map[string]bool, len(files))
+	for _, f := range files {
+		paths[f.Path] = true
+	}
+	disabled := m.DisabledMods[:0]
+	for _, d := range m.DisabledMods {
+		if paths[d.File] {
+			disabled = append(disabled, d)
+		}
+	}
+	m.DisabledMods = disabled
+}
+
+// AddDisabledMods records the mods disabled by DisableClientMods
+func (m *InstallManifest) AddDisabledMods(disabled []DisabledMod) {
+	for _, d := range disabled {
+		if !m.IsDisabled(d.File) {
+			m.DisabledMods = append(m.DisabledMods, d)
+		}
+	}
+}
+
+// IsDisabled reports whether the file is recorded as a disabled mod, path is slash separated
+func (m *InstallManifest) IsDisabled(path string) bool {
+	for _, d := range m.DisabledMods {
+		if d.File == path {
+			return true
+		}
+	}
+	return false
+}
+
+// SetMod adds or replaces the mod entry that has the same source and id
+func (m *InstallManifest) SetMod(entry ManifestEntry) {
+	m.Mods = setManifestEntry(m.Mods, entry)
+}
+
+// SetPlugin adds or replaces the plugin entry that has the same source and id
+func (m *InstallManifest) SetPlugin(entry ManifestEntry) {
+	m.Plugins = setManifestEntry(m.Plugins, entry)
+}
+
+func (m *InstallManifest) GetPlugin(id string) (entry ManifestEntry, ok bool) {
+	if i := findManifestEntry(m.Plugins, id); i >= 0 {
+		return m.Plugins[i], true
+	}
+	return
+}
+
+func (m *InstallManifest) RemovePlugin(id string) (entry ManifestEntry, ok bool) {
+	i := findManifestEntry(m.Plugins, id)
+	if i < 0 {
+		return
+	}
+	entry = m.Plugins[i]
+	m.Plugins = append(m.Plugins[:i], m.Plugins[i+1:]...)
+	return entry, true
+}
+
+func findManifestRef(entries []ManifestEntry, source string, ref string) int {
+	for i, e := range entries {
+		if e.Source == source && (strings.EqualFold(e.Ref, ref) || strings.EqualFold(e.Id, ref)) {
+			return i
+		}
+	}
+	return -1
+}
+
+// FindMod returns the mod entry that is requested with the ref, or has the id
+func (m *InstallManifest) FindMod(source string, ref string) (entry ManifestEntry, ok bool) {
+	if i := findManifestRef(m.Mods, source, ref); i >= 0 {
+		return m.Mods[i], true
+	}
+	return
+}
+
+// FindPlugin returns the plugin entry that is requested with the ref, or has the id
+func (m *InstallManifest) FindPlugin(source string, ref string) (entry ManifestEntry, ok bool) {
+	if i := findManifestRef(m.Plugins, source, ref); i >= 0 {
+		return m.Plugins[i], true
+	}
+	return
+}
+
+func (m *InstallManifest) RemoveMod(id string) (entry ManifestEntry, ok bool) {
+	i := findManifestEntry(m.Mods, id)
+	if i < 0 {
+		return
+	}
+	entry = m.Mods[i]
+	m.Mods = append(m.Mods[:i], m.Mods[i+1:]...)
+	return entry, true
+}
@@ -0,0 +1,41 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+
+	installer "github.com/kmcsr/server-installer"
+)
+
+func runApply(args []string) {
+	file := installer.ServerDefinitionName
+	if len(args) > 0 {
+		file = args[0]
+	}
+	def, err := installer.LoadServerDefinition(file)
+	if err != nil {
+		loger.Fatalf("Couldn't load server definition: %v", err)
+	}
+	plan, err := installer.PlanServer(InstallPath, def)
+	if err != nil {
+		loger.Fatalf("Couldn't plan server: %v", err)
+	}
+	defer plan.Close()
+	if JsonOutput {
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		if err = encoder.Encode(plan); err != nil {
+			loger.Fatalf("Couldn't encode plan: %v", err)
+		}
+	} else {
+		plan.WriteText(os.Stdout)
+	}
+	if len(plan.Actions) == 0 || DryRun {
+		return
+	}
+	if err = plan.Apply(); err != nil {
+		plan.Close()
+		loger.Fatalf("Couldn't apply server definition: %v", err)
+	}
+	loger.Infof("Applied %d change(s) to %q", len(plan.Actions), InstallPath)
+}
@@ -0,0 +1,60 @@
+package main
+
+import (
+	"os"
+
+	installer "github.com/kmcsr/server-installer"
+)
+
+// caches returns the cache directories by name
+func caches() [][2]string {
+	return [][2]string{
+		{"store", StorePath},
+		{"mirror", MirrorDir},
+	}
+}
+
+func runCache(args []string) {
+	action := "list"
+	if len(args) > 0 {
+		action, args = args[0], args[1:]
+	}
+	switch action {
+	case "list":
+		infos := make([]installer.CacheInfo, 0, 2)
+		for _, c := range caches() {
+			info, err := installer.ReadCacheInfo(c[0], c[1])
+			if err != nil {
+				exitWithErr(err, "Couldn't read cache %q", c[1])
+			}
+			infos = append(infos, info)
+		}
+		if JsonOutput {
+			printJson(infos)
+			return
+		}
+		installer.WriteCacheTable(os.Stdout, infos)
+	case "clean":
+		names := make(map[string]bool, len(args))
+		for _, name := range args {
+			if name != "store" && name != "mirror" {
+				exitWithUsage("Unknown cache %q, could be [store mirror]", name)
+			}
+			names[name] = true
+		}
+		cleaned := 0
+		for _, c := range caches() {
+			if len(names) > 0 && !names[c[0]] {
+				continue
+			}
+			loger.Infof("Removing cache %s %q", c[0], c[1])
+			if err := os.RemoveAll(c[1]); err != nil {
+				exitWithErr(err, "Couldn't remove cache %q", c[1])
+			}
+			cleaned++
+		}
+		loger.Infof("Cleaned %d cache(s)", cleaned)
+	default:
+		exitWithUsage("Unknown cache command %q, could be [list clean]", action)
+	}
+}
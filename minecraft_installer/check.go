@@ -0,0 +1,45 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+
+	installer "github.com/kmcsr/server-installer"
+)
+
+func runCheck(args []string) {
+	manifest := loadManifest()
+	target := installer.CheckTarget{
+		ServerType:    LoaderType,
+		GameVersion:   TargetVersion,
+		LoaderVersion: LoaderVersion,
+	}
+	if target.ServerType == "" {
+		target.ServerType = manifest.ServerType
+	}
+	if target.GameVersion == "" || target.GameVersion == "latest" {
+		target.GameVersion = manifest.GameVersion
+	}
+	if target.LoaderVersion == "" {
+		target.LoaderVersion = manifest.LoaderVersion
+	}
+	if target.ServerType == "" || target.GameVersion == "" {
+		loger.Warn("Server type or minecraft version is unknown, please specify them with flags -loader and -version")
+	}
+	report, err := installer.CheckServer(InstallPath, target)
+	if err != nil {
+		loger.Fatalf("Couldn't check server: %v", err)
+	}
+	if JsonOutput {
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		if err = encoder.Encode(report); err != nil {
+			loger.Fatalf("Couldn't encode report: %v", err)
+		}
+	} else {
+		report.WriteText(os.Stdout)
+	}
+	if report.ErrorCount() > 0 {
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,559 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	installer "github.com/kmcsr/server-installer"
+)
+
+// Command is a node of the command tree, each command has its own flags and help page
+type Command struct {
+	Name string
+	// Args is the synopsis of the arguments
+	Args    string
+	Summary string
+	// Help is the detail of the help page, it's indented by 2 spaces
+	Help string
+	// Flags registers the flags of the command, the flags are bound to the same variables as the global ones
+	Flags func(fs *flag.FlagSet)
+	Run   func(args []string)
+	// Bare commands run without setup, such as help
+	Bare bool
+
+	Commands []*Command
+	// Default is the sub command that runs when the first argument is not a sub command,
+	// so the old invocations such as `modpack <modpack_file>` keep working
+	Default string
+
+	parent *Command
+}
+
+func (c *Command) Path() string {
+	if c.parent == nil {
+		return c.Name
+	}
+	return c.parent.Path() + " " + c.Name
+}
+
+func (c *Command) Lookup(name string) *Command {
+	for _, sub := range c.Commands {
+		if sub.Name == name {
+			return sub
+		}
+	}
+	return nil
+}
+
+func (c *Command) link() *Command {
+	for _, sub := range c.Commands {
+		sub.parent = c
+		sub.link()
+	}
+	return c
+}
+
+func (c *Command) flagSet() *flag.FlagSet {
+	fs := flag.NewFlagSet(c.Path(), flag.ExitOnError)
+	if c.Flags != nil {
+		c.Flags(fs)
+	}
+	fs.Usage = func() { c.PrintHelp(fs) }
+	return fs
+}
+
+// PrintHelp prints the help page of the command with its flags
+func (c *Command) PrintHelp(fs *flag.FlagSet) {
+	out := fs.Output()
+	fmt.Fprintf(out, "Usage: %s", c.Path())
+	if c.Flags != nil {
+		fmt.Fprint(out, " [...flags]")
+	}
+	if len(c.Commands) > 0 {
+		fmt.Fprint(out, " <command>")
+	}
+	if c.Args != "" {
+		fmt.Fprint(out, " ", c.Args)
+	}
+	fmt.Fprintf(out, "\n\n  %s\n", c.Summary)
+	if c.Help != "" {
+		fmt.Fprintf(out, "%s\n", strings.TrimRight(c.Help, "\n"))
+	}
+	if len(c.Commands) > 0 {
+		fmt.Fprintln(out, "\nCommands:")
+		c.printCommands(out)
+	}
+	if c.Flags != nil {
+		fmt.Fprintln(out, "\nFlags:")
+		fs.PrintDefaults()
+	}
+}
+
+func (c *Command) printCommands(out io.Writer) {
+	for _, sub := range c.Commands {
+		synopsis := sub.Name
+		if len(sub.Commands) > 0 {
+			synopsis += " <command>"
+		}
+		if sub.Args != "" {
+			synopsis += " " + sub.Args
+		}
+		fmt.Fprintf(out, "  %-42s %s\n", synopsis, sub.Summary)
+	}
+}
+
+// Execute parses the flags of the command and runs it or its sub command
+func (c *Command) Execute(args []string) {
+	fs := c.flagSet()
+	if len(c.Commands) > 0 {
+		// the flags after the sub command belong to it
+		fs.Parse(args)
+		fs.Visit(markFlagSource)
+		args = fs.Args()
+		if len(args) > 0 {
+			if sub := c.Lookup(args[0]); sub != nil {
+				sub.Execute(args[1:])
+				return
+			}
+		}
+		if c.Default != "" && len(args) > 0 {
+			c.Lookup(c.Default).Execute(args)
+			return
+		}
+		if c.Run == nil {
+			fs.Usage()
+			if len(args) > 0 {
+				exitWithUsage("Unknown command %q", strings.Join(append(strings.Fields(c.Path())[1:], args[0]), " "))
+			}
+			exitWithUsage("Missing command")
+		}
+		c.run(args)
+		return
+	}
+	args = parseInterspersed(fs, args)
+	fs.Visit(markFlagSource)
+	c.run(args)
+}
+
+func (c *Command) run(args []string) {
+	if !c.Bare {
+		// the flags are all parsed now
+		setup()
+	}
+	c.Run(args)
+}
+
+// parseInterspersed parses the flags that are mixed with the arguments, such as `install fabric -version 1.20.1`.
+// The arguments after "--" are never parsed as flags
+func parseInterspersed(fs *flag.FlagSet, args []string) (positional []string) {
+	var rest []string
+	for i, a := range args {
+		if a == "--" {
+			args, rest = args[:i], args[i+1:]
+			break
+		}
+	}
+	for {
+		fs.Parse(args)
+		if args = fs.Args(); len(args) == 0 {
+			break
+		}
+		positional = append(positional, args[0])
+		args = args[1:]
+	}
+	return append(positional, rest...)
+}
+
+// rootCommand is the command tree, the flags given before the command are parsed by the global flag set
+var rootCommand = (&Command{
+	Name:    "minecraft_installer",
+	Summary: "Install and manage minecraft servers",
+	Default: "install",
+	Commands: []*Command{
+		{
+			Name:    "install",
+			Args:    "<server_type>",
+			Summary: "Install a server",
+			Help: `
+  The server type could be one of the installers listed by 'installers'.
+  Examples:
+    install -version 1.20.1 -output server fabric
+    install -version recommended@1.20.1 -name forge_server forge
+    install -dry-run -json -version '>=1.19 <1.21' vanilla
+    install -overwrite backup -output proxy -backend lobby=127.0.0.1:25566,lobby -backend survival=127.0.0.1:25567,survival velocity`,
+			Flags: func(fs *flag.FlagSet) {
+				fs.StringVar(&TargetVersion, "version", TargetVersion,
+					"the version of the server, could be [latest snapshot latest-snapshot],\n"+
+						"a range such as [1.20.x '>=1.19 <1.21' ~1.20], or '<loader>@<game>' such as [recommended@1.20.1 latest@1.20 latest-stable-loader]")
+				installFlags(fs)
+				proxyFlags(fs)
+				networkFlags(fs)
+				recipesFlag(fs)
+			},
+			Run: runInstall,
+		},
+		{
+			Name:    "modpack",
+			Summary: "Install, update or export a modrinth modpack",
+			Default: "install",
+			Commands: []*Command{
+				{
+					Name:    "install",
+					Args:    "<modpack_file>",
+					Summary: "Install the modpack and its server",
+					Help: `
+  The modpack could be a local path or an URL, an URL must start with [https:// http://].
+  The modpack is recorded in server-installer.json, so it can be updated later.`,
+					Flags: func(fs *flag.FlagSet) {
+						installFlags(fs)
+						modpackFlags(fs)
+						networkFlags(fs)
+					},
+					Run: runModpackInstall,
+				},
+				{
+					Name:    "update",
+					Args:    "[<modpack_file>]",
+					Summary: "Update the installed modpack to a new version",
+					Help: `
+  The files of the old version that the new one doesn't have are removed,
+  the server is reinstalled if the new version depends on another minecraft or loader version.
+  The modpack recorded by 'modpack install' is used again if <modpack_file> is not given.`,
+					Flags: func(fs *flag.FlagSet) {
+						// the changed files of the modpack are expected, keep the old ones by default
+						if !isConfigured("overwrite") {
+							Overwrite = string(installer.OverwriteBackup)
+						}
+						installFlags(fs)
+						modpackFlags(fs)
+						networkFlags(fs)
+					},
+					Run: runModpackUpdate,
+				},
+				{
+					Name:    "export",
+					Args:    "[<output.mrpack>]",
+					Summary: "Export the server as a modrinth modpack",
+					Help: `
+  The modpack files and the modrinth mods recorded in server-installer.json are exported as downloads,
+  the other files in mods/ and the paths given by -overrides are copied into the modpack.`,
+					Flags: func(fs *flag.FlagSet) {
+						outputFlag(fs)
+						fs.StringVar(&PackName, "pack-name", PackName,
+							"the modpack name, default is the name of the server directory")
+						fs.StringVar(&PackVersion, "pack-version", PackVersion,
+							"the modpack version")
+						fs.StringVar(&PackSummary, "summary", PackSummary,
+							"the modpack summary")
+						fs.StringVar(&PackOverrides, "overrides", PackOverrides,
+							"the comma separated files or directories copied into the modpack")
+					},
+					Run: runModpackExport,
+				},
+			},
+		},
+		{
+			Name:    "versions",
+			Args:    "[<server_type>]",
+			Summary: "List the versions of a server type",
+			Help: `
+  The default server type is vanilla.
+  Examples:
+    versions -snapshot
+    versions -game 1.20.x -loaders -limit 10 fabric
+    versions -game 1.20.1 -recommended -json forge`,
+			Flags: func(fs *flag.FlagSet) {
+				fs.StringVar(&TargetVersion, "game", TargetVersion,
+					"only list the minecraft versions matching a version, a prefix such as 1.20.x or a range such as '>=1.19 <1.21'")
+				fs.StringVar(&TargetVersion, "version", TargetVersion,
+					"the same as -game, 'snapshot' is the same as -snapshot")
+				fs.BoolVar(&ListSnapshots, "snapshot", ListSnapshots,
+					"list the snapshots too")
+				fs.BoolVar(&ListLoaders, "loaders", ListLoaders,
+					"list the loader versions, only the newest minecraft version is listed if -game is not given")
+				fs.BoolVar(&StableLoaders, "stable-loaders", StableLoaders,
+					"only list the stable loader versions")
+				fs.BoolVar(&Recommended, "recommended", Recommended,
+					"only list the recommended loader versions")
+				fs.IntVar(&ListLimit, "limit", ListLimit,
+					"the max count of the listed versions, 0 means no limit")
+				jsonFlag(fs)
+				networkFlags(fs)
+				recipesFlag(fs)
+			},
+			Run: runVersions,
+		},
+		{
+			Name:    "mod",
+			Args:    "add <slug|id>[@version]...",
+			Summary: "Install mods from modrinth with their dependencies",
+			Flags: func(fs *flag.FlagSet) {
+				outputFlag(fs)
+				targetFlags(fs)
+			},
+			Run: runMod,
+		},
+		{
+			Name:    "plugin",
+			Args:    "search|add|update|remove ...",
+			Summary: "Search, install, update or remove plugins",
+			Help: `
+  plugin search <query>
+  plugin add <id>[@version]...
+  plugin update [<id>...]
+  plugin remove <id>...`,
+			Flags: func(fs *flag.FlagSet) {
+				outputFlag(fs)
+				targetFlags(fs)
+				fs.StringVar(&PluginSource, "source", PluginSource,
+					"the repository that plugins are installed from, could be "+fmt.Sprint(installer.GetPluginSourceNames()))
+			},
+			Run: runPlugin,
+		},
+		{
+			Name:    "check",
+			Summary: "Check the dependencies and incompatibilities of the mods and plugins",
+			Flags: func(fs *flag.FlagSet) {
+				outputFlag(fs)
+				targetFlags(fs)
+				fs.StringVar(&LoaderVersion, "loader-version", LoaderVersion,
+					"the loader version, default is the version recorded in the install manifest")
+				jsonFlag(fs)
+			},
+			Run: runCheck,
+		},
+		{
+			Name:    "inspect",
+			Args:    "<jar|directory>...",
+			Summary: "Show the metadata of mod or plugin jars without running them",
+			Help: `
+  Reads fabric.mod.json, quilt.mod.json, mods.toml, neoforge.mods.toml, mcmod.info,
+  plugin.yml, paper-plugin.yml, bungee.yml and velocity-plugin.json, include the bundled jars.
+  All the jars in a directory are inspected.
+  Exits with 1 if any jar couldn't be read.`,
+			Flags: func(fs *flag.FlagSet) {
+				jsonFlag(fs)
+			},
+			Run: runInspect,
+		},
+		{
+			Name:    "detect",
+			Args:    "[<dir>]",
+			Summary: "Detect the server type and versions of a server directory, and record them into the install manifest",
+			Help: `
+  For the servers that are not installed by this program, default directory is -output.
+  Reads libraries/ of forge and neoforge, and the launcher, bundler or vanilla jars in the directory.
+  Then the other commands such as 'mod add', 'check' and 'java' could use the recorded versions.`,
+			Flags: func(fs *flag.FlagSet) {
+				outputFlag(fs)
+				fs.BoolVar(&DryRun, "dry-run", DryRun,
+					"print the result only, the install manifest is not written")
+				jsonFlag(fs)
+			},
+			Run: runDetect,
+		},
+		{
+			Name:    "verify",
+			Summary: "Verify the installed files against the hashes recorded in the install manifest",
+			Help: `
+  Exits with 1 if any file is missing or changed.`,
+			Flags: func(fs *flag.FlagSet) {
+				outputFlag(fs)
+				jsonFlag(fs)
+			},
+			Run: runVerify,
+		},
+		{
+			Name:    "java",
+			Summary: "Show the java used to install and run the server, and check its version",
+			Help: `
+  The required java version is read from the minecraft version manifest.
+  Exits with 1 if java is not found or it's too old for the minecraft version.`,
+			Flags: func(fs *flag.FlagSet) {
+				outputFlag(fs)
+				fs.StringVar(&TargetVersion, "version", TargetVersion,
+					"the minecraft version, default is the version recorded in the install manifest")
+				jsonFlag(fs)
+				networkFlags(fs)
+			},
+			Run: runJava,
+		},
+		{
+			Name:    "cache",
+			Args:    "[list | clean [store|mirror]...]",
+			Summary: "Show or clean the download caches",
+			Flags: func(fs *flag.FlagSet) {
+				fs.StringVar(&StorePath, "store", StorePath,
+					"the directory of the store used by 'prefetch' and -offline")
+				fs.StringVar(&MirrorDir, "mirror-dir", MirrorDir,
+					"the cache directory of 'serve-mirror'")
+				jsonFlag(fs)
+			},
+			Run: runCache,
+		},
+		{
+			Name:    "prefetch",
+			Args:    "<server_type>[:<version>]...",
+			Summary: "Fetch the files of the servers into the store, so they can be installed with -offline",
+			Flags: func(fs *flag.FlagSet) {
+				fs.StringVar(&TargetVersion, "version", TargetVersion,
+					"the version of the servers that don't give one")
+				fs.StringVar(&StorePath, "store", StorePath,
+					"the directory of the store")
+				fs.StringVar(&MirrorUrl, "mirror", MirrorUrl,
+					"the url of a server started by 'serve-mirror'")
+				recipesFlag(fs)
+			},
+			Run: runPrefetch,
+		},
+		{
+			Name:    "serve-mirror",
+			Summary: "Serve a caching mirror of mojang, fabric, forge, quilt and modrinth",
+			Flags: func(fs *flag.FlagSet) {
+				fs.StringVar(&ListenAddr, "listen", ListenAddr,
+					"the address to listen on")
+				fs.StringVar(&MirrorDir, "mirror-dir", MirrorDir,
+					"the cache directory")
+			},
+			Run: func(args []string) { runServeMirror() },
+		},
+		{
+			Name:    "installers",
+			Summary: "List the installers, include the ones loaded from the recipes",
+			Flags: func(fs *flag.FlagSet) {
+				recipesFlag(fs)
+				jsonFlag(fs)
+			},
+			Run: func(args []string) { runInstallers() },
+		},
+		{
+			Name:    "apply",
+			Args:    "[<server.yaml>]",
+			Summary: "Install or update the server to the state described by server.yaml",
+			Flags: func(fs *flag.FlagSet) {
+				outputFlag(fs)
+				fs.BoolVar(&DryRun, "dry-run", DryRun,
+					"print the plan only")
+				jsonFlag(fs)
+				networkFlags(fs)
+				recipesFlag(fs)
+			},
+			Run: runApply,
+		},
+		{
+			Name:    "config",
+			Summary: "Show the settings loaded from the config files, the environment variables and the flags",
+			Help: `
+  The settings are applied in order, the later ones win:
+    the system file /etc/server-installer/config.yaml (%ProgramData%\server-installer\config.yaml on windows),
+    the user file server-installer/config.yaml in the user config directory,
+    the project file .server-installer.yaml in the working directory,
+    the environment variables MCINSTALLER_<KEY>, such as MCINSTALLER_HOST_CONNS for host-conns,
+    and the flags.
+  The config files are YAML maps from the keys to the values, the keys are the names of the global flags:
+    ` + strings.Join(configKeys, " ") + `
+  The key "hosts" is the list of the credentials and the headers sent to the hosts, the first matched one is used:
+    hosts:
+      - {host: maven.example.com, username: ci, password: secret}
+      - {host: "*.example.com", token: abc, headers: {X-Api-Key: abc}}
+  The credentials in the netrc file are used for the hosts that are not listed.`,
+			Commands: []*Command{
+				{
+					Name:    "show",
+					Summary: "Print the effective settings and where each came from",
+					Flags: func(fs *flag.FlagSet) {
+						jsonFlag(fs)
+					},
+					Run: runConfigShow,
+				},
+			},
+		},
+		{
+			Name:    "help",
+			Args:    "[<command>...]",
+			Summary: "Show the help page of a command",
+			Bare:    true,
+		},
+	},
+}).link()
+
+func init() {
+	// runHelp refers to rootCommand
+	rootCommand.Lookup("help").Run = runHelp
+}
+
+func runHelp(args []string) {
+	c := rootCommand
+	for _, name := range args {
+		sub := c.Lookup(name)
+		if sub == nil {
+			exitWithUsage("Unknown command %q", strings.Join(args, " "))
+		}
+		c = sub
+	}
+	if c == rootCommand {
+		flag.Usage()
+		return
+	}
+	fs := c.flagSet()
+	fs.SetOutput(os.Stdout)
+	c.PrintHelp(fs)
+}
+
+func outputFlag(fs *flag.FlagSet) {
+	fs.StringVar(&InstallPath, "output", InstallPath,
+		"the server directory")
+}
+
+func jsonFlag(fs *flag.FlagSet) {
+	fs.BoolVar(&JsonOutput, "json", JsonOutput,
+		"print the result as JSON, the logs are written to stderr instead")
+}
+
+func recipesFlag(fs *flag.FlagSet) {
+	fs.StringVar(&RecipesDir, "recipes", RecipesDir,
+		"the directory of the installer recipes (*.json *.yaml *.yml), see 'installers'")
+}
+
+// installFlags are the flags of the commands that install a server
+func installFlags(fs *flag.FlagSet) {
+	outputFlag(fs)
+	fs.StringVar(&ExecutableName, "name", ExecutableName,
+		"the executable name, without suffix such as '.sh' or '.jar'")
+	fs.BoolVar(&DryRun, "dry-run", DryRun,
+		"print what the install will do without touching the server directory")
+	fs.StringVar(&Overwrite, "overwrite", Overwrite,
+		"what to do when a file to install already exists, could be "+fmt.Sprint(installer.OverwritePolicies)+",\n"+
+			"'backup' renames the existing file to '<name>.<time>.bak', the decisions are written into the log")
+	jsonFlag(fs)
+}
+
+func modpackFlags(fs *flag.FlagSet) {
+	fs.BoolVar(&StripClient, "strip-client", StripClient,
+		"move client-only mods into mods-disabled/ after installed")
+	fs.IntVar(&Parallelism, "parallel", Parallelism,
+		"the maximum number of modpack files to download at the same time")
+	fs.IntVar(&MaxConnsPerHost, "host-conns", MaxConnsPerHost,
+		"the maximum number of connections to a single host, negative value means no limit")
+}
+
+// networkFlags are the flags of the commands that download files
+func networkFlags(fs *flag.FlagSet) {
+	fs.BoolVar(&Offline, "offline", Offline,
+		"refuse network access, and serve all the downloads from the store made by 'prefetch'")
+	fs.StringVar(&StorePath, "store", StorePath,
+		"the directory of the store used by -offline")
+	fs.StringVar(&MirrorUrl, "mirror", MirrorUrl,
+		"the url of a server started by 'serve-mirror', such as http://192.168.1.2:8080")
+}
+
+// targetFlags are the flags that select the mods or plugins
+func targetFlags(fs *flag.FlagSet) {
+	fs.StringVar(&LoaderType, "loader", LoaderType,
+		"the server type used to select mods or plugins, default is the type recorded in the install manifest")
+	fs.StringVar(&TargetVersion, "version", TargetVersion,
+		"the minecraft version used to select mods or plugins, default is the version recorded in the install manifest")
+}
@@ -0,0 +1,282 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io/fs"
+	"net/url"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"text/tabwriter"
+
+	"gopkg.in/yaml.v3"
+
+	installer "github.com/kmcsr/server-installer"
+)
+
+// ConfigEnvPrefix is the prefix of the environment variables, such as MCINSTALLER_HOST_CONNS for host-conns
+const ConfigEnvPrefix = "MCINSTALLER_"
+
+// ProjectConfigName is the config file that is read from the working directory
+const ProjectConfigName = ".server-installer.yaml"
+
+// configKeys are the global flags that could be set by the config files and the environment variables
+var configKeys = []string{
+	"output",
+	"name",
+	"overwrite",
+	"parallel",
+	"host-conns",
+	"source",
+	"timeout",
+	"user-agent",
+	"chunk-threshold",
+	"chunks",
+	"bandwidth",
+	"max-downloads",
+	"proxy",
+	"no-proxy",
+	"ca-file",
+	"netrc",
+	"java",
+	"mirror",
+	"cache-dir",
+	"store",
+	"mirror-dir",
+	"listen",
+	"recipes",
+	"manifest-url",
+	"fabric-meta-url",
+	"forge-maven-url",
+	"forge-promotions-url",
+	"quilt-maven-url",
+	"quilt-meta-url",
+	"papermc-api-url",
+	"bungeecord-job-url",
+	"bedrock-links-url",
+	"bedrock-versions-url",
+	"bedrock-download-url",
+}
+
+// configSources records where the settings came from, the settings that are not in it are the defaults
+var configSources = make(map[string]string)
+
+// ConfigHosts are the credentials and the headers of the hosts, they could only be given by the config files
+var ConfigHosts []*installer.HostConfig
+
+type ConfigFile struct {
+	// Layer is one of [system user project]
+	Layer string
+	Path  string
+}
+
+type ConfigValue struct {
+	Key    string `json:"key"`
+	Value  string `json:"value"`
+	Source string `json:"source"`
+	Env    string `json:"env,omitempty"`
+}
+
+func configEnvName(key string) string {
+	return ConfigEnvPrefix + strings.ToUpper(strings.ReplaceAll(key, "-", "_"))
+}
+
+// configFiles returns the config files in the order they are applied
+func configFiles() (files []ConfigFile) {
+	system := "/etc/server-installer/config.yaml"
+	if runtime.GOOS == "windows" {
+		system = filepath.Join(os.Getenv("ProgramData"), "server-installer", "config.yaml")
+	}
+	return []ConfigFile{
+		{"system", system},
+		{"user", defaultConfigPath("config.yaml")},
+		{"project", ProjectConfigName},
+	}
+}
+
+func isConfigKey(key string) bool {
+	for _, k := range configKeys {
+		if k == key {
+			return true
+		}
+	}
+	return false
+}
+
+// setConfig sets the global flag without marking it as given in the command line
+func setConfig(key string, value string, source string) error {
+	f := flag.Lookup(key)
+	if f == nil || !isConfigKey(key) {
+		return fmt.Errorf("unknown key %q", key)
+	}
+	if err := f.Value.Set(value); err != nil {
+		return fmt.Errorf("invalid value %q for %s: %w", value, key, err)
+	}
+	configSources[key] = source
+	return nil
+}
+
+// readConfigFile reads the values as strings, a list is joined by commas.
+// The key "hosts" is the list of installer.HostConfig
+func readConfigFile(path string) (values map[string]string, hosts []*installer.HostConfig, err error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return
+	}
+	var nodes map[string]yaml.Node
+	if err = yaml.Unmarshal(data, &nodes); err != nil {
+		return
+	}
+	values = make(map[string]string, len(nodes))
+	for key, node := range nodes {
+		if key == "hosts" {
+			if err = node.Decode(&hosts); err != nil {
+				return
+			}
+			continue
+		}
+		var list []string
+		switch node.Kind {
+		case yaml.ScalarNode:
+			values[key] = node.Value
+		case yaml.SequenceNode:
+			if err = node.Decode(&list); err != nil {
+				return
+			}
+			values[key] = strings.Join(list, ",")
+		default:
+			return nil, nil, fmt.Errorf("line %d: value of %q should be a string or a list", node.Line, key)
+		}
+	}
+	return
+}
+
+func splitList(s string) (list []string) {
+	for _, v := range strings.Split(s, ",") {
+		if v = strings.TrimSpace(v); v != "" {
+			list = append(list, v)
+		}
+	}
+	return
+}
+
+// loadConfig applies the config files and then the environment variables,
+// it's called after the global flags are registered and before they are parsed, so the flags always win
+func loadConfig() {
+	for _, cf := range configFiles() {
+		values, hosts, err := readConfigFile(cf.Path)
+		if err != nil {
+			if errors.Is(err, fs.ErrNotExist) {
+				continue
+			}
+			exitWithUsage("Couldn't read %s config %q: %v", cf.Layer, cf.Path, err)
+		}
+		if hosts != nil {
+			ConfigHosts = hosts
+			configSources["hosts"] = cf.Layer + " " + cf.Path
+		}
+		for key, value := range values {
+			if err := setConfig(key, value, cf.Layer+" "+cf.Path); err != nil {
+				exitWithUsage("Invalid %s config %q: %v", cf.Layer, cf.Path, err)
+			}
+		}
+	}
+	for _, key := range configKeys {
+		env := configEnvName(key)
+		if value, ok := os.LookupEnv(env); ok {
+			if err := setConfig(key, value, "env "+env); err != nil {
+				exitWithUsage("Invalid environment variable %s: %v", env, err)
+			}
+		}
+	}
+	// show the configured values as the defaults in the help page
+	for _, key := range configKeys {
+		f := flag.Lookup(key)
+		f.DefValue = f.Value.String()
+	}
+}
+
+// markFlagSource records the settings that are given in the command line
+func markFlagSource(f *flag.Flag) {
+	if isConfigKey(f.Name) {
+		configSources[f.Name] = "flag -" + f.Name
+	}
+}
+
+// isConfigured reports whether the setting is given by a config file, an environment variable or a flag
+func isConfigured(key string) bool {
+	_, ok := configSources[key]
+	return ok
+}
+
+// resolveCacheDirs places the caches that are not configured into -cache-dir
+func resolveCacheDirs() {
+	if !isConfigured("store") {
+		StorePath = filepath.Join(CacheDir, "store")
+	}
+	if !isConfigured("mirror-dir") {
+		MirrorDir = filepath.Join(CacheDir, "mirror")
+	}
+}
+
+func configValues() (values []ConfigValue) {
+	values = make([]ConfigValue, 0, len(configKeys))
+	for _, key := range configKeys {
+		source, ok := configSources[key]
+		if !ok {
+			source = "default"
+		}
+		value := flag.Lookup(key).Value.String()
+		if key == "proxy" {
+			if u, err := url.Parse(value); err == nil {
+				value = u.Redacted()
+			}
+		}
+		values = append(values, ConfigValue{
+			Key:    key,
+			Value:  value,
+			Source: source,
+			Env:    configEnvName(key),
+		})
+	}
+	// the credentials are not shown
+	names := make([]string, len(ConfigHosts))
+	for i, h := range ConfigHosts {
+		names[i] = h.Host
+	}
+	source, ok := configSources["hosts"]
+	if !ok {
+		source = "default"
+	}
+	values = append(values, ConfigValue{
+		Key:    "hosts",
+		Value:  strings.Join(names, ","),
+		Source: source,
+	})
+	return
+}
+
+func runConfigShow(args []string) {
+	values := configValues()
+	if JsonOutput {
+		printJson(values)
+		return
+	}
+	tw := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "KEY\tVALUE\tSOURCE")
+	for _, v := range values {
+		fmt.Fprintf(tw, "%s\t%q\t%s\n", v.Key, v.Value, v.Source)
+	}
+	tw.Flush()
+	fmt.Println("\nConfig files:")
+	for _, cf := range configFiles() {
+		state := "not found"
+		if _, err := os.Stat(cf.Path); err == nil {
+			state = "loaded"
+		}
+		fmt.Printf("  %-8s %s (%s)\n", cf.Layer, cf.Path, state)
+	}
+}
@@ -0,0 +1,35 @@
+package main
+
+import (
+	"os"
+
+	installer "github.com/kmcsr/server-installer"
+)
+
+func runDetect(args []string) {
+	if len(args) > 1 {
+		exitWithUsage("Too many arguments, expect one server directory")
+	}
+	if len(args) == 1 {
+		InstallPath = args[0]
+	}
+	server, err := installer.DetectServer(InstallPath)
+	if err != nil {
+		exitWithErr(err, "Couldn't detect server")
+	}
+	if JsonOutput {
+		printJson(server)
+	} else {
+		server.WriteText(os.Stdout)
+	}
+	if DryRun {
+		return
+	}
+	manifest := loadManifest()
+	if manifest.ServerType != "" && (manifest.ServerType != server.Type || manifest.GameVersion != server.GameVersion) {
+		loger.Warnf("Replacing the recorded %s %s server with the detected one", manifest.ServerType, manifest.GameVersion)
+	}
+	server.Record(manifest)
+	saveManifest(manifest)
+	loger.Infof("Recorded the server into %q", installer.InstallManifestName)
+}
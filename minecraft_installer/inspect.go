@@ -0,0 +1,57 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+
+	installer "github.com/kmcsr/server-installer"
+)
+
+// inspectJars returns the jars to inspect, the jars in a directory are all inspected
+func inspectJars(args []string) (jars []string) {
+	for _, a := range args {
+		stat, err := os.Stat(a)
+		if err != nil || !stat.IsDir() {
+			jars = append(jars, a)
+			continue
+		}
+		entries, err := os.ReadDir(a)
+		if err != nil {
+			exitWithErr(err, "Couldn't read directory %q", a)
+		}
+		for _, e := range entries {
+			if !e.IsDir() && strings.EqualFold(filepath.Ext(e.Name()), ".jar") {
+				jars = append(jars, filepath.Join(a, e.Name()))
+			}
+		}
+	}
+	return
+}
+
+func runInspect(args []string) {
+	if len(args) == 0 {
+		exitWithUsage("Missing argument <jar|directory>")
+	}
+	infos := make([]*installer.JarInfo, 0, len(args))
+	failed := 0
+	for _, jar := range inspectJars(args) {
+		info, err := installer.InspectJar(jar)
+		if err != nil {
+			loger.Errorf("Couldn't inspect %q: %v", jar, err)
+			failed++
+			continue
+		}
+		infos = append(infos, info)
+	}
+	if JsonOutput {
+		printJson(infos)
+	} else {
+		for _, info := range infos {
+			info.WriteText(os.Stdout)
+		}
+	}
+	if failed > 0 {
+		os.Exit(ExitError)
+	}
+}
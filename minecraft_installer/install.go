@@ -0,0 +1,56 @@
+package main
+
+import (
+	"fmt"
+
+	installer "github.com/kmcsr/server-installer"
+)
+
+func runInstall(args []string) {
+	if len(args) == 0 {
+		exitWithUsage("Missing argument <server_type>")
+	}
+	ServerType = args[0]
+	loger.Infof("Getting version %q for %s server", TargetVersion, ServerType)
+	loger.Infof("Install into %q with name %q", InstallPath, ExecutableName)
+	if !JsonOutput {
+		fmt.Println()
+	}
+
+	ir, ok := installer.Get(ServerType)
+	if !ok {
+		exitWithUsage("Could not found installer for server %q", ServerType)
+	}
+	resolved, err := installer.ResolveInstallTarget(ir, TargetVersion)
+	if err != nil {
+		exitWithErr(err, "Couldn't resolve version %q", TargetVersion)
+	}
+	var plan *installer.InstallPlan
+	proxy, isProxy := ir.(installer.ProxyPlanner)
+	if isProxy {
+		plan, err = proxy.PlanProxy(InstallPath, ExecutableName, resolved, proxyNetwork())
+	} else {
+		plan, err = installer.PlanInstall(ir, ServerType, InstallPath, ExecutableName, resolved)
+	}
+	if err != nil {
+		exitWithErr(err, "Couldn't plan install")
+	}
+	if DryRun {
+		printInstallPlan(plan)
+		return
+	}
+	installed, err := executePlan(plan)
+	if err != nil {
+		exitWithErr(err, "Install error")
+	}
+	gameVersion, loader := resolved.Game, resolved.Loader
+	if gameVersion == "latest" || gameVersion == "latest-snapshot" {
+		gameVersion = ""
+	}
+	if isProxy || ServerType == "bedrock" {
+		// the proxies and bedrock record the version that is installed, since they are not minecraft versions
+		gameVersion, loader = plan.Game, plan.Loader
+	}
+	recordServer(ServerType, gameVersion, loader, installed)
+	printInstalled(plan, installed)
+}
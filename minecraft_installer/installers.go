@@ -0,0 +1,41 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
+
+	installer "github.com/kmcsr/server-installer"
+)
+
+func runInstallers() {
+	infos := installer.GetInstallerInfos()
+	if JsonOutput {
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		if err := encoder.Encode(infos); err != nil {
+			loger.Fatalf("Couldn't encode installers: %v", err)
+		}
+		return
+	}
+	tw := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "NAME\tDISPLAY NAME\tJAVA\tPLATFORMS\tSOURCE\tDESCRIPTION")
+	for _, info := range infos {
+		java := "no"
+		if info.RequiresJava {
+			java = "yes"
+		}
+		platforms := "all"
+		if len(info.Platforms) > 0 {
+			platforms = strings.Join(info.Platforms, ",")
+		}
+		source := "builtin"
+		if info.Source != "" {
+			source = info.Source
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\t%s\n", info.Name, info.DisplayName, java, platforms, source, info.Description)
+	}
+	tw.Flush()
+}
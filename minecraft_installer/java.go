@@ -0,0 +1,59 @@
+package main
+
+import (
+	"fmt"
+	"os"
+
+	installer "github.com/kmcsr/server-installer"
+)
+
+// JavaOutput is printed by `java` with flag -json
+type JavaOutput struct {
+	Java        *installer.JavaInfo `json:"java,omitempty"`
+	GameVersion string              `json:"gameVersion,omitempty"`
+	Required    int                 `json:"required,omitempty"`
+	Ok          bool                `json:"ok"`
+	Error       string              `json:"error,omitempty"`
+}
+
+func runJava(args []string) {
+	var res JavaOutput
+	res.GameVersion = TargetVersion
+	if res.GameVersion == "" || res.GameVersion == "latest" {
+		if manifest, err := installer.ReadInstallManifest(InstallPath); err == nil && manifest.GameVersion != "" {
+			res.GameVersion = manifest.GameVersion
+		}
+	}
+	java, err := installer.FindJava()
+	if err != nil {
+		res.Error = fmt.Sprintf("Couldn't find java: %v", err)
+	} else {
+		res.Java = java
+		res.Ok = true
+	}
+	if required, err := installer.VanillaIns.RequiredJava(res.GameVersion); err != nil {
+		loger.Warnf("Couldn't get the java version required by minecraft %s: %v", res.GameVersion, err)
+	} else {
+		res.Required = required
+		if java != nil && java.Major < required {
+			res.Ok = false
+			res.Error = fmt.Sprintf("Minecraft %s requires java %d or newer, but java %s is found", res.GameVersion, required, java.Version)
+		}
+	}
+	if JsonOutput {
+		printJson(res)
+	} else {
+		if java != nil {
+			fmt.Printf("Java: %s (%s)\n", java.Path, java.Version)
+		}
+		if res.Required > 0 {
+			fmt.Printf("Required by minecraft %s: java %d\n", res.GameVersion, res.Required)
+		}
+		if res.Error != "" {
+			loger.Error(res.Error)
+		}
+	}
+	if !res.Ok {
+		os.Exit(ExitError)
+	}
+}
@@ -0,0 +1,18 @@
+package main
+
+import (
+	"net/http"
+
+	installer "github.com/kmcsr/server-installer"
+)
+
+func runServeMirror() {
+	server := installer.NewMirrorServer(MirrorDir)
+	for _, up := range server.Upstreams {
+		loger.Infof("Mirror %s -> %s", up.Prefix, up.Url)
+	}
+	loger.Infof("Serving mirror at %q with cache directory %q", ListenAddr, MirrorDir)
+	if err := http.ListenAndServe(ListenAddr, server); err != nil {
+		loger.Fatalf("Mirror server stopped: %v", err)
+	}
+}
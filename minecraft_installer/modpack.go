@@ -0,0 +1,242 @@
+package main
+
+import (
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+
+	installer "github.com/kmcsr/server-installer"
+)
+
+func allModpackFiles(f installer.MrpackFileMeta) bool { return true }
+
+// isUrl reports whether the modpack reference is an URL, absolute paths are valid request URIs so the scheme is checked
+func isUrl(ref string) bool {
+	u, err := url.ParseRequestURI(ref)
+	return err == nil && u.Scheme != "" && u.Host != ""
+}
+
+// openModpack opens the modpack, it's downloaded first if it's an URL.
+// cleanup removes the downloaded modpack, it should be called before exit since os.Exit doesn't run the deferred functions
+func openModpack(ref string) (pack *installer.Mrpack, cleanup func(), err error) {
+	cleanup = func() {}
+	path := ref
+	if isUrl(ref) {
+		loger.Infof("Downloading modpack %q ...", ref)
+		if path, err = installer.DefaultHTTPClient.DownloadTmp(ref, "server-*.mrpack", 0, nil, -1, nil); err != nil {
+			return
+		}
+		cleanup = func() { os.Remove(path) }
+	}
+	loger.Infof("Loading modpack %q ...", path)
+	if pack, err = installer.OpenMrpack(path); err != nil {
+		cleanup()
+		return
+	}
+	pack.Parallelism = Parallelism
+	pack.MaxConnsPerHost = MaxConnsPerHost
+	closeFn := cleanup
+	cleanup = func() {
+		pack.Close()
+		closeFn()
+	}
+	return
+}
+
+// modpackRef returns the reference of the modpack that is recorded in the install manifest
+func modpackRef(ref string) string {
+	if isUrl(ref) {
+		return ref
+	}
+	if abs, err := filepath.Abs(ref); err == nil {
+		return abs
+	}
+	return ref
+}
+
+// recordModpack records the installed modpack into the install manifest
+func recordModpack(ref string, pack *installer.Mrpack, disabled []installer.DisabledMod) {
+	manifest, err := installer.ReadInstallManifest(InstallPath)
+	if err != nil {
+		loger.Warnf("Couldn't read install manifest: %v", err)
+		return
+	}
+	sha1, err := pack.Sha1()
+	if err != nil {
+		loger.Warnf("Couldn't hash modpack: %v", err)
+		return
+	}
+	files, err := pack.ServerFiles(allModpackFiles)
+	if err != nil {
+		loger.Warnf("Couldn't list modpack files: %v", err)
+		return
+	}
+	manifest.AddDisabledMods(disabled)
+	manifest.SetModpack(modpackRef(ref), sha1, files)
+	if err := manifest.Save(InstallPath); err != nil {
+		loger.Warnf("Couldn't save install manifest: %v", err)
+	}
+}
+
+func stripClientMods(fail func(err error, format string, args ...any)) (disabled []installer.DisabledMod) {
+	if !StripClient {
+		return
+	}
+	disabled, err := installer.DisableClientMods(InstallPath)
+	if err != nil {
+		fail(err, "Couldn't disable client-only mods")
+	}
+	loger.Infof("Disabled %d client-only mod(s)", len(disabled))
+	return
+}
+
+func runModpackInstall(args []string) {
+	if len(args) == 0 {
+		exitWithUsage("Missing argument <modpack_file>")
+	}
+	ref := args[0]
+	pack, cleanup, err := openModpack(ref)
+	if err != nil {
+		exitWithErr(err, "Couldn't load modpack %q", ref)
+	}
+	defer cleanup()
+	fail := func(err error, format string, args ...any) {
+		cleanup()
+		exitWithErr(err, format, args...)
+	}
+	plan, err := pack.PlanServer(InstallPath, allModpackFiles)
+	if err != nil {
+		fail(err, "Couldn't plan modpack")
+	}
+	loaderPlan, err := pack.PlanLoader(InstallPath, ExecutableName)
+	if err == installer.ModpackNoDependencyErr {
+		loger.Warnf("Modpack didn't contain any dependencies")
+	} else if err != nil {
+		fail(err, "Couldn't plan server")
+	} else {
+		plan.Merge(loaderPlan)
+	}
+	if DryRun {
+		printInstallPlan(plan)
+		return
+	}
+	installed, err := executePlan(plan)
+	if err != nil {
+		fail(err, "Install modpack error")
+	}
+	disabled := stripClientMods(fail)
+	if plan.Server != "" {
+		recordServer(plan.Server, plan.Game, plan.Loader, installed)
+	}
+	recordModpack(ref, pack, disabled)
+	printInstalled(plan, installed)
+}
+
+func runModpackUpdate(args []string) {
+	manifest := loadManifest()
+	ref := manifest.Modpack
+	if len(args) > 0 {
+		ref = args[0]
+	}
+	if ref == "" {
+		exitWithUsage("No modpack is recorded in %q, please give <modpack_file>", filepath.Join(InstallPath, installer.InstallManifestName))
+	}
+	pack, cleanup, err := openModpack(ref)
+	if err != nil {
+		exitWithErr(err, "Couldn't load modpack %q", ref)
+	}
+	defer cleanup()
+	fail := func(err error, format string, args ...any) {
+		cleanup()
+		exitWithErr(err, format, args...)
+	}
+	sha1, err := pack.Sha1()
+	if err != nil {
+		fail(err, "Couldn't hash modpack")
+	}
+	installed := ""
+	if manifest.Executable != "" {
+		installed = filepath.Join(InstallPath, filepath.FromSlash(manifest.Executable))
+	}
+	plan, err := pack.PlanServerUpdate(InstallPath, manifest.ModpackFiles, manifest.DisabledMods, allModpackFiles)
+	if err != nil {
+		fail(err, "Couldn't plan modpack")
+	}
+	plan.Server, plan.Game, plan.Loader = manifest.ServerType, manifest.GameVersion, manifest.LoaderVersion
+	if sha1 == manifest.ModpackSha1 {
+		loger.Infof("Modpack %s %s is already installed", pack.Name, pack.VersionId)
+		if !DryRun {
+			printInstalled(&installer.InstallPlan{Modpack: plan.Modpack, Path: InstallPath}, installed)
+			return
+		}
+	}
+	serverType, gameVersion, loader, err := pack.ServerTarget()
+	if err == installer.ModpackNoDependencyErr {
+		loger.Warnf("Modpack didn't contain any dependencies")
+	} else if err != nil {
+		fail(err, "Couldn't plan server")
+	} else if serverType != manifest.ServerType || gameVersion != manifest.GameVersion || loader != manifest.LoaderVersion {
+		loger.Infof("Updating server %s %s %s to %s %s %s",
+			manifest.ServerType, manifest.GameVersion, manifest.LoaderVersion, serverType, gameVersion, loader)
+		loaderPlan, err := pack.PlanLoader(InstallPath, ExecutableName)
+		if err != nil {
+			fail(err, "Couldn't plan server")
+		}
+		plan.Merge(loaderPlan)
+	}
+	if DryRun {
+		printInstallPlan(plan)
+		return
+	}
+	if newInstalled, err := executePlan(plan); err != nil {
+		fail(err, "Update modpack error")
+	} else if newInstalled != "" {
+		installed = newInstalled
+		recordServer(plan.Server, plan.Game, plan.Loader, installed)
+	}
+	disabled := stripClientMods(fail)
+	recordModpack(ref, pack, disabled)
+	printInstalled(plan, installed)
+}
+
+func runModpackExport(args []string) {
+	opts := installer.MrpackExportOptions{
+		Name:      PackName,
+		VersionId: PackVersion,
+		Summary:   PackSummary,
+		Overrides: []string{},
+	}
+	for _, o := range strings.Split(PackOverrides, ",") {
+		if o = strings.TrimSpace(o); o != "" {
+			opts.Overrides = append(opts.Overrides, filepath.FromSlash(o))
+		}
+	}
+	output := ""
+	if len(args) > 0 {
+		output = args[0]
+	} else {
+		name := PackName
+		if name == "" {
+			abs, err := filepath.Abs(InstallPath)
+			if err != nil {
+				exitWithErr(err, "Couldn't resolve %q", InstallPath)
+			}
+			name = filepath.Base(abs)
+		}
+		output = name + "-" + PackVersion + ".mrpack"
+	}
+	fd, err := os.Create(output)
+	if err != nil {
+		exitWithErr(err, "Couldn't create %q", output)
+	}
+	meta, err := installer.ExportMrpack(InstallPath, fd, opts)
+	if er := fd.Close(); err == nil {
+		err = er
+	}
+	if err != nil {
+		os.Remove(output)
+		exitWithErr(err, "Couldn't export modpack")
+	}
+	loger.Infof("Exported modpack %s %s with %d download(s) to %q", meta.Name, meta.VersionId, len(meta.Files), output)
+}
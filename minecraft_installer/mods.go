@@ -0,0 +1,189 @@
+package main
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	installer "github.com/kmcsr/server-installer"
+)
+
+func loadManifest() *installer.InstallManifest {
+	manifest, err := installer.ReadInstallManifest(InstallPath)
+	if err != nil {
+		loger.Fatalf("Couldn't read install manifest: %v", err)
+	}
+	return manifest
+}
+
+func saveManifest(manifest *installer.InstallManifest) {
+	if err := manifest.Save(InstallPath); err != nil {
+		loger.Fatalf("Couldn't save install manifest: %v", err)
+	}
+}
+
+// recordServer records the installed server into the install manifest
+func recordServer(serverType string, gameVersion string, loader string, installed string) {
+	manifest, err := installer.ReadInstallManifest(InstallPath)
+	if err != nil {
+		loger.Warnf("Couldn't read install manifest: %v", err)
+		return
+	}
+	manifest.ServerType = serverType
+	manifest.GameVersion = gameVersion
+	manifest.LoaderVersion = loader
+	if rel, err := filepath.Rel(InstallPath, installed); err == nil {
+		manifest.Executable = filepath.ToSlash(rel)
+	}
+	if err := manifest.Save(InstallPath); err != nil {
+		loger.Warnf("Couldn't save install manifest: %v", err)
+	}
+}
+
+// serverTarget returns the server type and the minecraft version that mods or plugins should be installed for
+func serverTarget(manifest *installer.InstallManifest) (serverType string, gameVersion string) {
+	serverType = LoaderType
+	if serverType == "" {
+		serverType = manifest.ServerType
+	}
+	if serverType == "" {
+		loger.Fatal("Server type is unknown, please specify it with flag -loader")
+	}
+	gameVersion = TargetVersion
+	if gameVersion == "" || gameVersion == "latest" {
+		if manifest.GameVersion != "" {
+			gameVersion = manifest.GameVersion
+		} else {
+			versions, err := installer.VanillaIns.GetVersions()
+			if err != nil {
+				loger.Fatalf("Couldn't get minecraft version manifest: %v", err)
+			}
+			gameVersion = versions.Latest.Release
+		}
+	}
+	return
+}
+
+func runMod(args []string) {
+	if len(args) < 2 || args[0] != "add" {
+		exitWithUsage("Usage: mod add <slug|id>[@version]...")
+	}
+	manifest := loadManifest()
+	serverType, gameVersion := serverTarget(manifest)
+	for _, arg := range args[1:] {
+		project, version, _ := strings.Cut(arg, "@")
+		installed, err := installer.DefaultModrinthClient.InstallMod(InstallPath, project, version, serverType, gameVersion)
+		if err != nil {
+			loger.Fatalf("Couldn't install %q: %v", arg, err)
+		}
+		fmt.Println("\nInstalled files:")
+		for i, f := range installed {
+			entry := installer.ManifestEntry{
+				Source:    "modrinth",
+				Id:        f.ProjectId,
+				Version:   f.VersionNumber,
+				VersionId: f.VersionId,
+				Path:      f.Path,
+				Hashes:    f.Hashes,
+				Pinned:    i == 0 && version != "",
+			}
+			if i == 0 {
+				entry.Ref = project
+			} else {
+				entry.RequiredBy = []string{project}
+			}
+			if strings.HasPrefix(f.Path, "plugins/") {
+				manifest.SetPlugin(entry)
+			} else {
+				manifest.SetMod(entry)
+			}
+			fmt.Printf("%s (%s %s)\n", f.Path, f.ProjectId, f.VersionNumber)
+		}
+		saveManifest(manifest)
+	}
+}
+
+func runPlugin(args []string) {
+	if len(args) < 1 {
+		exitWithUsage("Usage: plugin search|add|update|remove ...")
+	}
+	source, ok := installer.GetPluginSource(PluginSource)
+	if !ok {
+		loger.Fatalf("Unknown plugin source %q, could be %v", PluginSource, installer.GetPluginSourceNames())
+	}
+	switch args[0] {
+	case "search":
+		if len(args) < 2 {
+			exitWithUsage("Usage: plugin search <query>")
+		}
+		platform := ""
+		if LoaderType != "" {
+			var err error
+			if platform, err = installer.PluginPlatformFor(LoaderType); err != nil {
+				exitWithErr(err, "Couldn't search plugins")
+			}
+		}
+		version := TargetVersion
+		if version == "latest" {
+			version = ""
+		}
+		plugins, err := source.Search(strings.Join(args[1:], " "), platform, version)
+		if err != nil {
+			loger.Fatalf("Couldn't search plugins: %v", err)
+		}
+		for _, p := range plugins {
+			fmt.Printf("%s\t%s by %s\n\t%s\n", p.Id, p.Name, p.Author, p.Description)
+		}
+	case "add":
+		if len(args) < 2 {
+			exitWithUsage("Usage: plugin add <id>[@version]...")
+		}
+		manifest := loadManifest()
+		serverType, gameVersion := serverTarget(manifest)
+		for _, arg := range args[1:] {
+			id, version, _ := strings.Cut(arg, "@")
+			installed, err := installer.InstallPlugin(source, manifest, InstallPath, id, version, serverType, gameVersion)
+			saveManifest(manifest)
+			if err != nil {
+				loger.Fatalf("Couldn't install plugin %q: %v", arg, err)
+			}
+			for _, e := range installed {
+				fmt.Printf("Installed %s (%s %s)\n", e.Path, e.Id, e.Version)
+			}
+		}
+	case "update":
+		manifest := loadManifest()
+		serverType, gameVersion := serverTarget(manifest)
+		ids := args[1:]
+		if len(ids) == 0 {
+			for _, e := range manifest.Plugins {
+				ids = append(ids, e.Id)
+			}
+		}
+		for _, id := range ids {
+			updated, err := installer.UpdatePlugin(manifest, InstallPath, id, serverType, gameVersion)
+			saveManifest(manifest)
+			if err != nil {
+				loger.Fatalf("Couldn't update plugin %q: %v", id, err)
+			}
+			if updated {
+				e, _ := manifest.GetPlugin(id)
+				fmt.Printf("Updated %s to %s\n", id, e.Version)
+			}
+		}
+	case "remove":
+		if len(args) < 2 {
+			exitWithUsage("Usage: plugin remove <id>...")
+		}
+		manifest := loadManifest()
+		for _, id := range args[1:] {
+			if err := installer.RemovePlugin(manifest, InstallPath, id); err != nil {
+				loger.Fatalf("Couldn't remove plugin %q: %v", id, err)
+			}
+			fmt.Printf("Removed %s\n", id)
+		}
+		saveManifest(manifest)
+	default:
+		exitWithUsage("Unknown plugin command %q, could be [search add update remove]", args[0])
+	}
+}
@@ -0,0 +1,134 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	installer "github.com/kmcsr/server-installer"
+)
+
+// Exit codes of install, modpack and versions, see UsageText
+const (
+	ExitOk              = 0
+	ExitError           = 1
+	ExitUsage           = 2
+	ExitVersionNotFound = 3
+	ExitAssetNotFound   = 4
+	ExitHashMismatch    = 5
+	ExitHttpStatus      = 6
+	ExitUnsupportGame   = 7
+	ExitMrpackVersion   = 8
+	ExitTargetExists    = 9
+)
+
+type (
+	ErrorOutput struct {
+		Code    int    `json:"code"`
+		Type    string `json:"type"`
+		Message string `json:"message"`
+	}
+
+	// InstallOutput is printed by install and modpack with flag -json
+	InstallOutput struct {
+		Ok        bool     `json:"ok"`
+		Server    string   `json:"server,omitempty"`
+		Game      string   `json:"game,omitempty"`
+		Loader    string   `json:"loader,omitempty"`
+		Modpack   string   `json:"modpack,omitempty"`
+		Path      string   `json:"path,omitempty"`
+		Installed string   `json:"installed,omitempty"`
+		Launch    []string `json:"launch,omitempty"`
+		// Files are the files placed into the install directory, relative to it
+		Files []string     `json:"files,omitempty"`
+		Error *ErrorOutput `json:"error,omitempty"`
+	}
+)
+
+// exitCodeOf maps the typed errors to the exit codes, the wrapped errors are checked too
+func exitCodeOf(err error) (code int, typ string) {
+	var (
+		versionErr *installer.VersionNotFoundErr
+		assetErr   *installer.AssetNotFoundErr
+		hashErr    *installer.HashErr
+		statusErr  *installer.HttpStatusError
+		gameErr    *installer.UnsupportGameErr
+		mrpackErr  *installer.MrpackVerisonErr
+	)
+	switch {
+	case errors.As(err, &versionErr):
+		return ExitVersionNotFound, "VersionNotFoundErr"
+	case errors.As(err, &assetErr):
+		return ExitAssetNotFound, "AssetNotFoundErr"
+	case errors.As(err, &hashErr):
+		return ExitHashMismatch, "HashErr"
+	case errors.As(err, &statusErr):
+		return ExitHttpStatus, "HttpStatusError"
+	case errors.As(err, &gameErr):
+		return ExitUnsupportGame, "UnsupportGameErr"
+	case errors.As(err, &mrpackErr):
+		return ExitMrpackVersion, "MrpackVerisonErr"
+	case errors.Is(err, installer.TargetAlreadyExistErr):
+		return ExitTargetExists, "TargetAlreadyExistErr"
+	}
+	return ExitError, strings.TrimPrefix(fmt.Sprintf("%T", err), "*")
+}
+
+func printJson(v any) {
+	encoder := json.NewEncoder(os.Stdout)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(v); err != nil {
+		loger.Fatalf("Couldn't encode output: %v", err)
+	}
+}
+
+// exitWithUsage reports the wrong usage and exits with ExitUsage
+func exitWithUsage(format string, args ...any) {
+	msg := fmt.Sprintf(format, args...)
+	if JsonOutput {
+		printJson(InstallOutput{Error: &ErrorOutput{Code: ExitUsage, Type: "UsageErr", Message: msg}})
+	}
+	loger.Error(msg)
+	os.Exit(ExitUsage)
+}
+
+// exitWithErr reports the error and exits with the code mapped by exitCodeOf.
+// With flag -json the error is printed as InstallOutput
+func exitWithErr(err error, format string, args ...any) {
+	code, typ := exitCodeOf(err)
+	msg := fmt.Sprintf(format, args...)
+	if JsonOutput {
+		printJson(InstallOutput{Error: &ErrorOutput{Code: code, Type: typ, Message: msg + ": " + err.Error()}})
+	}
+	loger.Errorf("%s: %v", msg, err)
+	os.Exit(code)
+}
+
+// printInstalled prints the result of the executed plan
+func printInstalled(plan *installer.InstallPlan, installed string) {
+	if JsonOutput {
+		path, _ := filepath.Abs(plan.Path)
+		printJson(InstallOutput{
+			Ok:        true,
+			Server:    plan.Server,
+			Game:      plan.Game,
+			Loader:    plan.Loader,
+			Modpack:   plan.Modpack,
+			Path:      path,
+			Installed: installed,
+			Launch:    installer.LaunchCommand(installed),
+			Files:     plan.Files(),
+		})
+		return
+	}
+	if installed == "" {
+		installed = "NULL"
+	} else {
+		loger.Infof("installed: %s", installed)
+	}
+	fmt.Println("\nServer executable file installed to:")
+	fmt.Println(installed)
+}
@@ -0,0 +1,27 @@
+package main
+
+import (
+	"context"
+	"os"
+	"os/signal"
+	"syscall"
+
+	installer "github.com/kmcsr/server-installer"
+)
+
+// printInstallPlan prints the plan of -dry-run, the sizes of downloads are probed if they are unknown
+func printInstallPlan(plan *installer.InstallPlan) {
+	plan.ProbeSizes()
+	if JsonOutput {
+		printJson(plan)
+		return
+	}
+	plan.WriteText(os.Stdout)
+}
+
+// executePlan executes the plan, and rolls it back if the program is interrupted
+func executePlan(plan *installer.InstallPlan) (installed string, err error) {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	return plan.ExecuteContext(ctx)
+}
@@ -0,0 +1,28 @@
+package main
+
+import (
+	"strings"
+
+	installer "github.com/kmcsr/server-installer"
+)
+
+func runPrefetch(args []string) {
+	if len(args) == 0 {
+		exitWithUsage("Missing argument <server_type>[:<version>]")
+	}
+	if Offline {
+		loger.Fatal("Cannot prefetch in offline mode")
+	}
+	store := installer.NewArtifactStore(StorePath)
+	for _, arg := range args {
+		serverType, version, ok := strings.Cut(arg, ":")
+		if !ok {
+			version = TargetVersion
+		}
+		loger.Infof("Prefetching %s %s into %q", serverType, version, StorePath)
+		if err := installer.Prefetch(store, serverType, version); err != nil {
+			loger.Fatalf("Couldn't prefetch %s %s: %v", serverType, version, err)
+		}
+	}
+	loger.Infof("Prefetched %d server(s)", len(args))
+}
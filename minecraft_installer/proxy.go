@@ -0,0 +1,58 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"strings"
+
+	installer "github.com/kmcsr/server-installer"
+)
+
+// backendsFlag is the repeatable flag -backend
+type backendsFlag []*installer.ProxyBackend
+
+func (f *backendsFlag) String() string {
+	if f == nil {
+		return ""
+	}
+	list := make([]string, len(*f))
+	for i, b := range *f {
+		list[i] = b.String()
+	}
+	return strings.Join(list, " ")
+}
+
+func (f *backendsFlag) Set(value string) error {
+	b, err := installer.ParseProxyBackend(value)
+	if err != nil {
+		return err
+	}
+	*f = append(*f, b)
+	return nil
+}
+
+var (
+	ProxyBackends    backendsFlag
+	Forwarding       string = ""
+	ForwardingSecret string = ""
+)
+
+// proxyFlags are the flags of the proxy installers, such as velocity and bungeecord
+func proxyFlags(fs *flag.FlagSet) {
+	fs.Var(&ProxyBackends, "backend",
+		"the backend server behind the proxy, in the format '<name>=<host:port>[,<dir>]', could be given multiple times.\n"+
+			"The first one is the default server, the server in <dir> is set up for the forwarding")
+	fs.StringVar(&Forwarding, "forwarding", Forwarding,
+		"how the proxy forwards the player info, could be "+fmt.Sprint([]string{installer.ForwardingModern, installer.ForwardingLegacy, installer.ForwardingNone})+",\n"+
+			"default is modern for velocity and legacy for bungeecord and waterfall")
+	fs.StringVar(&ForwardingSecret, "forwarding-secret", ForwardingSecret,
+		"the secret of the modern forwarding, default is the existing one or a random one")
+}
+
+func proxyNetwork() *installer.ProxyNetwork {
+	return &installer.ProxyNetwork{
+		Backends:   ProxyBackends,
+		Forwarding: Forwarding,
+		Secret:     ForwardingSecret,
+	}
+}
@@ -1,31 +1,161 @@
 package main
 
 const UsageText = `
-minecraft_installer [...flags] <server_type>
-minecraft_installer [...flags] modpack <modpack_file>
-minecraft_installer [...flags] versions [<server_type>]
+Run 'minecraft_installer help <command>' or 'minecraft_installer <command> -h' to show the flags of a command.
+The old invocations still work: the flags could be given before the command, 'minecraft_installer [...flags] <server_type>'
+is the same as 'install <server_type>', 'modpack <modpack_file>' is the same as 'modpack install <modpack_file>',
+and '-version snapshot versions' is the same as 'versions -snapshot'.
 
 Example:
   Install servers:
-    minecraft_installer -name minecraft_server -version 1.7.10 vanilla
+    minecraft_installer install -name minecraft_server -version 1.7.10 vanilla
         Install minecraft 1.7.10 vanilla server into minecraft_server.jar
-    minecraft_installer -name minecraft_server -version 1.19.2 forge
+    minecraft_installer install -name minecraft_server -version 1.19.2 forge
         Install minecraft 1.19.2 forge server into current directory and the executable is minecraft_server.sh
         Hint: forge installer will make run scripts for the minecraft version that higher or equal than 1.17
               for version that less than 1.17, you still need to use 'java -jar' to run the server
-    minecraft_installer -name minecraft_server -version 1.19.2 -output server fabric
+    minecraft_installer install -name minecraft_server -version 1.19.2 -output server fabric
         Install minecraft 1.19.2 fabric server into server/minecraft_server.jar
+    minecraft_installer install -overwrite backup -output proxy -backend lobby=127.0.0.1:25566,lobby -backend survival=127.0.0.1:25567,survival velocity
+        Install the newest velocity proxy into proxy/ in front of the servers in lobby/ and survival/
+        Hint: the backend servers are switched to offline mode and their ports are set, paper and spigot are set up for the forwarding,
+              the default forwarding is modern for velocity and legacy for bungeecord and waterfall, change it with -forwarding
+              the backend configs are changed after the proxy is installed, '-overwrite backup' keeps the old ones as '<name>.<time>.bak'
+              without -backend, the existing proxy config is kept when the proxy is upgraded
+    minecraft_installer install -name minecraft_server -output bedrock bedrock
+        Install the newest Bedrock Dedicated Server for linux into bedrock/, the executable is bedrock/minecraft_server.sh
+        Hint: run it again to upgrade, server.properties, permissions.json, allowlist.json and worlds/ are kept,
+              the previews are listed by 'versions -snapshot bedrock'
+    minecraft_installer install -version latest@1.20 forge
+        Install the newest forge for the newest minecraft 1.20.x release
+    minecraft_installer install -version recommended@1.20.1 forge
+        Install the recommended forge for minecraft 1.20.1
+    minecraft_installer install -version '>=1.19 <1.21' fabric
+        Install the newest minecraft release in the range with the newest fabric loader
+        Hint: loader selectors are [latest latest-stable-loader recommended <exact version>],
+              only forge marks recommended versions, the newest stable loader is used for the others
+  Preview an install:
+    minecraft_installer install -dry-run -version 1.20.1 forge
+        Print the resolved versions, the files to download with their sizes and hashes,
+        the files to create or overwrite, and the commands to run, without installing anything
+    minecraft_installer modpack install -dry-run -json /path/to/modrinth-modpack.mrpack
+        Print the plan of the modpack and its server as JSON
+    minecraft_installer install -overwrite backup -version 1.20.2 vanilla
+        Install the server over an existing one, the replaced files are kept as '<name>.<time>.bak'
+        Hint: the default policy 'skip-identical' only keeps the existing files that are the same,
+              the install is staged and nothing is changed if any file conflicts
+  Use in scripts:
+    minecraft_installer install -json -version 1.20.1 fabric
+        Print the result as JSON to stdout, the logs are written to stderr:
+        {"ok": true, "server", "game", "loader", "path", "installed", "launch": [...], "files": [...]}
+        or {"ok": false, "error": {"code", "type", "message"}} if failed, -json works with modpack and versions too
+        Hint: exit codes are
+              0 ok, 1 other errors, 2 wrong usage, 3 VersionNotFoundErr, 4 AssetNotFoundErr,
+              5 HashErr, 6 HttpStatusError, 7 UnsupportGameErr, 8 MrpackVerisonErr,
+              9 the target file exists (see -overwrite)
   Install modpacks:
-    minecraft_installer -name modpack_server modpack /path/to/modrinth-modpack.mrpack
+    minecraft_installer modpack install -name modpack_server /path/to/modrinth-modpack.mrpack
         Install the modpack from local to the current directory
         Hint: Only support modrinth modpack for now, curseforge is in progress
-    minecraft_installer -name modpack_server modpack 'https://cdn-raw.modrinth.com/data/sl6XzkCP/versions/i4agaPF2/Automation%20v3.3.mrpack'
+    minecraft_installer modpack install -name modpack_server 'https://cdn-raw.modrinth.com/data/sl6XzkCP/versions/i4agaPF2/Automation%20v3.3.mrpack'
         Install the modpack from internet to the current directory
         Hint: if you want to install modpack from the internet,
               you must add the prefixs [https://, http://]
+    minecraft_installer modpack update -output server /path/to/new-version.mrpack
+        Update the modpack installed in server/, the files removed from the new version are deleted,
+        the replaced files are kept as '<name>.<time>.bak' unless -overwrite is given
+    minecraft_installer modpack export -output server -pack-version 1.1.0 -overrides config,kubejs
+        Export server/ as a modrinth modpack, the modrinth mods are exported as downloads
+    minecraft_installer modpack install -strip-client /path/to/modrinth-modpack.mrpack
+        Install the modpack, and move the client-only mods into mods-disabled/
+  Install mods or plugins from modrinth:
+    minecraft_installer mod add -loader fabric -version 1.20.1 fabric-api lithium@mc1.20.1-0.11.2
+        Install fabric-api and lithium (and their required dependencies) into mods/
+        Hint: plugins for [spigot paper] will be installed into plugins/
+  Manage plugins for spigot/paper servers:
+    minecraft_installer plugin add -loader spigot -version 1.20.1 ViaVersion
+        Install ViaVersion from hangar into plugins/ and record it into server-installer.json
+    minecraft_installer plugin update
+        Update all the plugins recorded in server-installer.json, except the ones installed with a version
+    minecraft_installer plugin search -source modrinth worldedit
+        Search plugins from modrinth
+  Check mods and plugins:
+    minecraft_installer check -output server
+        Check the dependencies and incompatibilities of mods and plugins in server/mods and server/plugins
+        Hint: the server type and versions are read from server-installer.json,
+              use flags [-loader -version -loader-version] if the server is not installed by this program
+    minecraft_installer check -json
+        Print the report as JSON
+  Check the installed server:
+    minecraft_installer inspect server/mods
+        Show the id, version, loaders, minecraft versions and dependencies of the jars in server/mods
+    minecraft_installer detect server
+        Detect the server type and versions of a server not installed by this program,
+        and record them into server/server-installer.json, so 'mod add' and 'check' could use them
+    minecraft_installer verify -output server
+        Check the files recorded in server/server-installer.json are not missing or changed
+    minecraft_installer java -version 1.20.5
+        Show the found java, and check it can run minecraft 1.20.5
+  Install without internet access:
+    minecraft_installer prefetch -store /mnt/store vanilla:1.20.1 forge:recommended@1.20.1 fabric:1.20.1
+        Fetch the manifests, installers and libraries of the servers into /mnt/store
+    minecraft_installer install -store /mnt/store -offline -version recommended@1.20.1 forge
+        Install the server from /mnt/store, without any network access
+        Hint: version queries are resolved with the prefetched manifests,
+              fabric server still downloads the libraries when it runs for the first time
+  Configure:
+    minecraft_installer config show
+        Print the effective settings and where each came from
+        Hint: the settings are read from /etc/server-installer/config.yaml, the user config directory,
+              .server-installer.yaml in the working directory, the environment variables MCINSTALLER_<KEY>
+              and the flags, the later ones win. A config file looks like:
+              timeout: 30s
+              parallel: 8
+              mirror: http://192.168.1.2:8080
+              proxy: socks5://proxy.example.com:1080
+              bandwidth: 4M
+              hosts: [{host: maven.example.com, username: ci, password: secret}]
+  Share downloads in LAN:
+    minecraft_installer serve-mirror -listen :8080 -mirror-dir /var/cache/mc-mirror
+        Serve a caching mirror of mojang, fabric, forge, quilt and modrinth
+    minecraft_installer install -mirror http://192.168.1.2:8080 -version 1.20.1 fabric
+        Install the server with the files downloaded through the mirror
+        Hint: the external installers (forge, quilt and spigot BuildTools) still download their libraries directly
+    minecraft_installer cache
+        Show the size of the store and the mirror cache, use 'cache clean [store|mirror]' to remove them
+  Describe the server in a file:
+    minecraft_installer apply -output server server.yaml
+        Print the plan, then install or update the server in server/ to the state described by server.yaml,
+        use -dry-run to print the plan only
+        Hint: server.yaml looks like:
+              server: {type: fabric, version: 1.20.1, name: minecraft}
+              java: {maxMemory: 4G, args: [-XX:+UseG1GC]}
+              mods: [{id: fabric-api}, {id: lithium, version: mc1.20.1-0.11.2}]
+              properties: {motd: Hello, max-players: 20}
+              eula: true
+              use 'modpack: <path or url>' instead of 'server' to install a modpack,
+              applying the same file again changes nothing
+  Add server types by recipes:
+    minecraft_installer installers
+        List all the installers, include the ones loaded from the recipes directory (see -recipes)
+        Hint: a recipe is a JSON or YAML file, for example purpur.yaml:
+              name: purpur
+              displayName: Purpur
+              versions: {url: "https://api.purpurmc.org/v2/purpur", path: versions, order: oldest-first}
+              download:
+                url: "https://api.purpurmc.org/v2/purpur/{{.Version}}/latest/download"
+                file: "{{.Name}}.jar"
+                hash: {type: md5, url: "https://api.purpurmc.org/v2/purpur/{{.Version}}/latest", path: md5}
+              steps: [{run: [java, -jar, "{{.File}}", --help]}, {rename: {from: a.txt, to: b.txt}}, {delete: tmp}]
   List Versions:
     minecraft_installer versions
         List all vanilla versions but without snapshots
-    minecraft_installer -version snapshot versions
+    minecraft_installer versions -snapshot
         List all vanilla versions include snapshots
+    minecraft_installer versions -game 1.20.x -loaders -limit 10 fabric
+        List the newest 10 fabric loader versions for minecraft 1.20.x, include pre-releases
+    minecraft_installer versions -game 1.20.1 -recommended -json forge
+        Print the recommended forge version for minecraft 1.20.1 as JSON
+        Hint: filters are [-game -snapshot -loaders -stable-loaders -recommended -limit],
+              when -game is a range or a prefix, the matched snapshots are listed too
 `
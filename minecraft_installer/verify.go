@@ -0,0 +1,25 @@
+package main
+
+import (
+	"os"
+
+	installer "github.com/kmcsr/server-installer"
+)
+
+func runVerify(args []string) {
+	report, err := installer.VerifyServer(InstallPath)
+	if err != nil {
+		exitWithErr(err, "Couldn't verify server")
+	}
+	if len(report.Files) == 0 {
+		loger.Warnf("No files are recorded in %q, only the files installed by this program can be verified", installer.InstallManifestName)
+	}
+	if JsonOutput {
+		printJson(report)
+	} else {
+		report.WriteText(os.Stdout)
+	}
+	if report.FailedCount() > 0 {
+		os.Exit(ExitError)
+	}
+}
@@ -0,0 +1,45 @@
+package main
+
+import (
+	"fmt"
+	"os"
+
+	installer "github.com/kmcsr/server-installer"
+)
+
+func runVersions(args []string) {
+	ServerType = "vanilla"
+	if len(args) > 0 {
+		ServerType = args[0]
+	}
+	filter := installer.VersionFilter{
+		Loaders:       ListLoaders || StableLoaders || Recommended,
+		StableLoaders: StableLoaders,
+		Recommended:   Recommended,
+		Limit:         ListLimit,
+		Snapshot:      ListSnapshots,
+	}
+	switch TargetVersion {
+	case "", "latest":
+	case "snapshot", "latest-snapshot":
+		filter.Snapshot = true
+	default:
+		filter.Game = TargetVersion
+		filter.Snapshot = true
+	}
+	loger.Infof("Getting version list for %s server", ServerType)
+	ir, ok := installer.Get(ServerType)
+	if !ok {
+		exitWithUsage("Could not found installer for server %q", ServerType)
+	}
+	entries, err := installer.ListVersionEntries(ir, filter)
+	if err != nil {
+		exitWithErr(err, "Couldn't get versions")
+	}
+	if JsonOutput {
+		printJson(entries)
+		return
+	}
+	fmt.Println("Total versions count:", len(entries))
+	installer.WriteVersionTable(os.Stdout, entries)
+}
@@ -0,0 +1,439 @@
+package installer
+
+import (
+	"crypto/sha1"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// MirrorUpstream is a remote server that is proxied by the mirror under Prefix
+type MirrorUpstream struct {
+	// Prefix is the path prefix on the mirror, such as "/mojang/piston-data"
+	Prefix string
+	// Url is the upstream base url, such as "https://piston-data.mojang.com"
+	Url string
+	// Immutable reports whether the content of the path never changes,
+	// immutable contents are pinned by their hash once they are cached
+	Immutable func(p string) bool
+}
+
+func isMavenArtifact(p string) bool {
+	base := path.Base(p)
+	return !strings.HasPrefix(base, "maven-metadata.xml") && strings.Contains(base, ".")
+}
+
+func alwaysImmutable(string) bool { return true }
+func neverImmutable(string) bool  { return false }
+
+// MirrorUpstreams are the upstreams that the mirror serves, the prefixes are stable
+// so the clients can always rewrite the links to the mirror
+var MirrorUpstreams = []MirrorUpstream{
+	{"/mojang/launchermeta", "https://launchermeta.mojang.com", func(p string) bool { return strings.HasPrefix(p, "/v1/packages/") }},
+	{"/mojang/piston-meta", "https://piston-meta.mojang.com", func(p string) bool { return strings.HasPrefix(p, "/v1/packages/") }},
+	{"/mojang/piston-data", "https://piston-data.mojang.com", alwaysImmutable},
+	{"/mojang/launcher", "https://launcher.mojang.com", alwaysImmutable},
+	{"/mojang/libraries", "https://libraries.minecraft.net", isMavenArtifact},
+	{"/fabric/meta", "https://meta.fabricmc.net", neverImmutable},
+	{"/fabric/maven", "https://maven.fabricmc.net", isMavenArtifact},
+	{"/forge/maven", "https://maven.minecraftforge.net", isMavenArtifact},
+	{"/forge/files", "https://files.minecraftforge.net", neverImmutable},
+	{"/quilt/meta", "https://meta.quiltmc.org", neverImmutable},
+	{"/quilt/maven", "https://maven.quiltmc.org", isMavenArtifact},
+	{"/modrinth/api", "https://api.modrinth.com", neverImmutable},
+	{"/modrinth/cdn", "https://cdn.modrinth.com", alwaysImmutable},
+}
+
+// MirrorUrl rewrites the link to the mirror if its host is one of the upstreams
+func MirrorUrl(mirror string, link *url.URL) (*url.URL, bool) {
+	for _, up := range MirrorUpstreams {
+		u, err := url.Parse(up.Url)
+		if err != nil || !strings.EqualFold(u.Host, link.Host) || u.Scheme != link.Scheme {
+			continue
+		}
+		target, err := url.Parse(strings.TrimSuffix(mirror, "/") + up.Prefix + link.EscapedPath())
+		if err != nil {
+			return link, false
+		}
+		target.RawQuery = link.RawQuery
+		return target, true
+	}
+	return link, false
+}
+
+// mirrorEntry is the metadata of a cached response, the body is saved in blobs/ by its sha256
+type mirrorEntry struct {
+	Url          string    `json:"url"`
+	ContentType  string    `json:"contentType,omitempty"`
+	ETag         string    `json:"etag,omitempty"`
+	LastModified string    `json:"lastModified,omitempty"`
+	Size         int64     `json:"size"`
+	Sha1         string    `json:"sha1"`
+	Sha256       string    `json:"sha256"`
+	Fetched      time.Time `json:"fetched"`
+	Expires      time.Time `json:"expires"`
+	Immutable    bool      `json:"immutable,omitempty"`
+}
+
+// MirrorServer is a caching proxy of the MirrorUpstreams
+type MirrorServer struct {
+	Dir       string
+	Client    *HTTPClient
+	Upstreams []MirrorUpstream
+
+	mux sync.Mutex
+	// locks are the locks of the entries being resolved, they are removed when no request holds them
+	locks map[string]*mirrorLock
+}
+
+type mirrorLock struct {
+	sync.Mutex
+	refs int
+}
+
+var _ http.Handler = (*MirrorServer)(nil)
+
+func NewMirrorServer(dir string) *MirrorServer {
+	return &MirrorServer{
+		Dir: dir,
+		Client: &HTTPClient{
+			// the proxy and the credentials are shared with the default client
+			Client:    http.Client{Transport: DefaultHTTPClient.Transport},
+			UserAgent: DefaultHTTPClient.UserAgent,
+		},
+		Upstreams: MirrorUpstreams,
+		locks:     make(map[string]*mirrorLock),
+	}
+}
+
+type MirrorHashErr struct {
+	Url    string
+	Sha1   string
+	Expect string
+}
+
+func (e *MirrorHashErr) Error() string {
+	return fmt.Sprintf("Upstream content of %q has sha1 %s, but the path pins %s", e.Url, e.Sha1, e.Expect)
+}
+
+// mojangObjectRe matches the mojang paths that contain the sha1 of the content
+var mojangObjectRe = regexp.MustCompile(`^/v1/(?:objects|packages)/([0-9a-f]{40})/`)
+
+func (s *MirrorServer) lock(key string) func() {
+	s.mux.Lock()
+	l, ok := s.locks[key]
+	if !ok {
+		l = new(mirrorLock)
+		s.locks[key] = l
+	}
+	l.refs++
+	s.mux.Unlock()
+	l.Lock()
+	return func() {
+		l.Unlock()
+		s.mux.Lock()
+		if l.refs--; l.refs == 0 {
+			delete(s.locks, key)
+		}
+		s.mux.Unlock()
+	}
+}
+
+// entryPath returns the file of the entry, or an empty string if the path is not local to the entries directory
+func (s *MirrorServer) entryPath(prefix string, u *url.URL) string {
+	p := strings.TrimPrefix(u.EscapedPath(), "/")
+	if p == "" || strings.HasSuffix(p, "/") {
+		p += "index"
+	}
+	if u.RawQuery != "" {
+		p += "@" + url.PathEscape(u.RawQuery)
+	}
+	rel := filepath.Join(filepath.FromSlash(strings.TrimPrefix(prefix, "/")), filepath.FromSlash(p)+".json")
+	if !filepath.IsLocal(rel) {
+		return ""
+	}
+	return filepath.Join(s.Dir, "entries", rel)
+}
+
+// cleanMirrorPath cleans the request path and keeps the trailing slash, it reports false if the path has ".." segments
+func cleanMirrorPath(p string) (string, bool) {
+	for _, seg := range strings.Split(p, "/") {
+		if seg == ".." {
+			return "", false
+		}
+	}
+	cleaned := path.Clean("/" + p)
+	if strings.HasSuffix(p, "/") && cleaned != "/" {
+		cleaned += "/"
+	}
+	return cleaned, true
+}
+
+func (s *MirrorServer) blobPath(sha256 string) string {
+	return filepath.Join(s.Dir, "blobs", sha256[:2], sha256)
+}
+
+func (s *MirrorServer) readEntry(p string) (e *mirrorEntry) {
+	data, err := os.ReadFile(p)
+	if err != nil {
+		return nil
+	}
+	e = new(mirrorEntry)
+	if err = json.Unmarshal(data, e); err != nil {
+		return nil
+	}
+	if _, err = os.Stat(s.blobPath(e.Sha256)); err != nil {
+		return nil
+	}
+	return
+}
+
+func (s *MirrorServer) writeEntry(p string, e *mirrorEntry) (err error) {
+	data, err := json.Marshal(e)
+	if err != nil {
+		return
+	}
+	if err = os.MkdirAll(filepath.Dir(p), 0755); err != nil {
+		return
+	}
+	tmp := p + ".tmp"
+	if err = os.WriteFile(tmp, data, 0644); err != nil {
+		return
+	}
+	return os.Rename(tmp, p)
+}
+
+func (s *MirrorServer) ServeHTTP(rw http.ResponseWriter, req *http.Request) {
+	if req.Method != http.MethodGet && req.Method != http.MethodHead {
+		http.Error(rw, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	reqPath, ok := cleanMirrorPath(req.URL.Path)
+	if !ok {
+		http.Error(rw, "Bad path", http.StatusBadRequest)
+		return
+	}
+	var up *MirrorUpstream
+	var rest string
+	for i := range s.Upstreams {
+		u := &s.Upstreams[i]
+		if p, ok := strings.CutPrefix(reqPath, u.Prefix); ok && (p == "" || p[0] == '/') {
+			up, rest = u, p
+			break
+		}
+	}
+	if up == nil {
+		http.NotFound(rw, req)
+		return
+	}
+	if rest == "" {
+		rest = "/"
+	}
+	link, err := url.Parse(up.Url + rest)
+	if err != nil {
+		http.Error(rw, err.Error(), http.StatusBadRequest)
+		return
+	}
+	link.RawQuery = req.URL.RawQuery
+	entryPath := s.entryPath(up.Prefix, &url.URL{Path: rest, RawQuery: link.RawQuery})
+	if entryPath == "" {
+		http.Error(rw, "Bad path", http.StatusBadRequest)
+		return
+	}
+
+	unlock := s.lock(entryPath)
+	entry, status, err := s.resolve(req, up, link, rest, entryPath)
+	unlock()
+	if err != nil {
+		loger.Warnf("Mirror: couldn't fetch %q: %v", link, err)
+		code := http.StatusBadGateway
+		if se, ok := err.(*HttpStatusError); ok {
+			code = se.Code
+		}
+		http.Error(rw, err.Error(), code)
+		return
+	}
+	fd, err := os.Open(s.blobPath(entry.Sha256))
+	if err != nil {
+		http.Error(rw, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	defer fd.Close()
+	h := rw.Header()
+	if entry.ContentType != "" {
+		h.Set("Content-Type", entry.ContentType)
+	}
+	h.Set("ETag", `"`+entry.Sha256+`"`)
+	h.Set("X-Checksum-Sha1", entry.Sha1)
+	h.Set("X-Checksum-Sha256", entry.Sha256)
+	h.Set("X-Cache", status)
+	if entry.Immutable {
+		h.Set("Cache-Control", "public, max-age=31536000, immutable")
+	} else if ttl := time.Until(entry.Expires); ttl > 0 {
+		h.Set("Cache-Control", "public, max-age="+strconv.Itoa(int(ttl.Seconds())))
+	} else {
+		h.Set("Cache-Control", "no-cache")
+	}
+	modTime, _ := http.ParseTime(entry.LastModified)
+	http.ServeContent(rw, req, path.Base(rest), modTime, fd)
+}
+
+// resolve returns the cached entry, and fetches it from upstream if it's missing or stale
+func (s *MirrorServer) resolve(req *http.Request, up *MirrorUpstream, link *url.URL, rest string, entryPath string) (entry *mirrorEntry, status string, err error) {
+	now := time.Now()
+	entry = s.readEntry(entryPath)
+	if entry != nil && (entry.Immutable || now.Before(entry.Expires)) {
+		return entry, "HIT", nil
+	}
+	var fresh *mirrorEntry
+	if fresh, status, err = s.fetch(req, up, link, rest, entry); err != nil {
+		if entry != nil {
+			loger.Warnf("Mirror: serving stale %q: %v", link, err)
+			return entry, "STALE", nil
+		}
+		return
+	}
+	if !fresh.Expires.IsZero() || fresh.Immutable { // responses with no-store are not cached
+		if err = s.writeEntry(entryPath, fresh); err != nil {
+			return
+		}
+	}
+	return fresh, status, nil
+}
+
+func (s *MirrorServer) fetch(req *http.Request, up *MirrorUpstream, link *url.URL, rest string, old *mirrorEntry) (entry *mirrorEntry, status string, err error) {
+	var ureq *http.Request
+	if ureq, err = s.Client.NewRequestWithContext(req.Context(), http.MethodGet, link.String(), nil); err != nil {
+		return
+	}
+	if accept := req.Header.Get("Accept"); accept != "" {
+		ureq.Header.Set("Accept", accept)
+	}
+	if old != nil {
+		if old.ETag != "" {
+			ureq.Header.Set("If-None-Match", old.ETag)
+		}
+		if old.LastModified != "" {
+			ureq.Header.Set("If-Modified-Since", old.LastModified)
+		}
+	}
+	var res *http.Response
+	if res, err = s.Client.Do(ureq); err != nil {
+		return
+	}
+	defer res.Body.Close()
+	now := time.Now()
+	expires, noStore := cacheExpires(res.Header, now)
+	if res.StatusCode == http.StatusNotModified && old != nil {
+		entry = new(mirrorEntry)
+		*entry = *old
+		entry.Fetched = now
+		entry.Expires = expires
+		return entry, "REVALIDATED", nil
+	}
+	if res.StatusCode != http.StatusOK {
+		return nil, "", &HttpStatusError{Code: res.StatusCode}
+	}
+	entry = &mirrorEntry{
+		Url:          link.String(),
+		ContentType:  res.Header.Get("Content-Type"),
+		ETag:         res.Header.Get("ETag"),
+		LastModified: res.Header.Get("Last-Modified"),
+		Fetched:      now,
+		Expires:      expires,
+		Immutable:    !noStore && up.Immutable(rest),
+	}
+	if noStore {
+		entry.Expires = time.Time{}
+	}
+	if err = s.saveBlob(res.Body, entry); err != nil {
+		return nil, "", err
+	}
+	if strings.HasPrefix(up.Prefix, "/mojang/") {
+		if m := mojangObjectRe.FindStringSubmatch(rest); m != nil && m[1] != entry.Sha1 {
+			return nil, "", &MirrorHashErr{Url: entry.Url, Sha1: entry.Sha1, Expect: m[1]}
+		}
+	}
+	if old != nil && old.Immutable && old.Sha256 != entry.Sha256 {
+		// pinned contents are never replaced
+		loger.Warnf("Mirror: upstream content of %q changed, keep the pinned sha256 %s", entry.Url, old.Sha256)
+		return old, "HIT", nil
+	}
+	return entry, "MISS", nil
+}
+
+func (s *MirrorServer) saveBlob(r io.Reader, entry *mirrorEntry) (err error) {
+	dir := filepath.Join(s.Dir, "blobs")
+	if err = os.MkdirAll(dir, 0755); err != nil {
+		return
+	}
+	var fd *os.File
+	if fd, err = os.CreateTemp(dir, "*.downloading"); err != nil {
+		return
+	}
+	defer func() {
+		if err != nil {
+			os.Remove(fd.Name())
+		}
+	}()
+	h1, h256 := sha1.New(), sha256.New()
+	entry.Size, err = io.Copy(io.MultiWriter(fd, h1, h256), r)
+	if er := fd.Close(); err == nil {
+		err = er
+	}
+	if err != nil {
+		return
+	}
+	entry.Sha1 = hex.EncodeToString(h1.Sum(nil))
+	entry.Sha256 = hex.EncodeToString(h256.Sum(nil))
+	blob := s.blobPath(entry.Sha256)
+	if err = os.MkdirAll(filepath.Dir(blob), 0755); err != nil {
+		return
+	}
+	return os.Rename(fd.Name(), blob)
+}
+
+// cacheExpires returns when the response will be stale according to the Cache-Control and Expires headers
+func cacheExpires(h http.Header, now time.Time) (expires time.Time, noStore bool) {
+	maxAge, sMaxAge := -1, -1
+	for _, d := range strings.Split(h.Get("Cache-Control"), ",") {
+		name, value, _ := strings.Cut(strings.TrimSpace(d), "=")
+		switch strings.ToLower(name) {
+		case "no-store", "private":
+			noStore = true
+		case "no-cache":
+			maxAge = 0
+		case "max-age":
+			if n, err := strconv.Atoi(strings.Trim(value, `"`)); err == nil && maxAge != 0 {
+				maxAge = n
+			}
+		case "s-maxage":
+			if n, err := strconv.Atoi(strings.Trim(value, `"`)); err == nil {
+				sMaxAge = n
+			}
+		}
+	}
+	if sMaxAge >= 0 && maxAge != 0 {
+		maxAge = sMaxAge
+	}
+	if maxAge >= 0 {
+		age, _ := strconv.Atoi(h.Get("Age"))
+		return now.Add(time.Duration(maxAge-age) * time.Second), noStore
+	}
+	if exp, err := http.ParseTime(h.Get("Expires")); err == nil {
+		return exp, noStore
+	}
+	return now, noStore
+}
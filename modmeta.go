@@ -0,0 +1,741 @@
+package installer
+
+import (
+	"archive/zip"
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"path"
+	"sort"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	ModDepRequired     = "required"
+	ModDepOptional     = "optional"
+	ModDepIncompatible = "incompatible"
+)
+
+const (
+	ModEnvAny    = "*"
+	ModEnvClient = "client"
+	ModEnvServer = "server"
+)
+
+type (
+	ModDependency struct {
+		Id    string `json:"id"`
+		Range string `json:"range"`
+		Kind  string `json:"kind"`           // one of ModDepRequired, ModDepOptional and ModDepIncompatible
+		Side  string `json:"side,omitempty"` // empty or one of ModEnvAny, ModEnvClient and ModEnvServer
+		// Maven reports whether Range is a maven version range, otherwise it's a semver style range
+		Maven bool `json:"-"`
+	}
+
+	// ModMetadata is the normalised metadata of a mod or plugin
+	ModMetadata struct {
+		Path         string          `json:"path"`   // path of the jar file
+		Format       string          `json:"format"` // the metadata file name, e.g. "fabric.mod.json"
+		Id           string          `json:"id"`
+		Name         string          `json:"name,omitempty"`
+		Version      string          `json:"version"`
+		Loaders      []string        `json:"loaders"`
+		Environment  string          `json:"environment,omitempty"`
+		Minecraft    string          `json:"minecraft,omitempty"` // range of the supported minecraft versions, the api-version of plugins is a minimum
+		Dependencies []ModDependency `json:"dependencies,omitempty"`
+		Provides     []string        `json:"provides,omitempty"`
+		Bundled      []*ModMetadata  `json:"bundled,omitempty"`
+		// Entrypoints are the kinds of entrypoints declared by fabric and quilt mods
+		Entrypoints []string `json:"entrypoints,omitempty"`
+		// DisplayTest is the forge displayTest of the mod
+		DisplayTest string `json:"displayTest,omitempty"`
+		// ClientSideOnly is the neoforge clientSideOnly flag
+		ClientSideOnly bool `json:"clientSideOnly,omitempty"`
+	}
+)
+
+type NoModMetadataErr struct {
+	Path string
+}
+
+func (e *NoModMetadataErr) Error() string {
+	return fmt.Sprintf("%q does not contain any known mod or plugin metadata", e.Path)
+}
+
+// VersionRange parses the dependency's version range
+func (d ModDependency) VersionRange() (VersionRange, error) {
+	if d.Maven {
+		return ParseMavenRange(d.Range)
+	}
+	return ParseSemverRange(d.Range)
+}
+
+// ReadModMetadata opens the jar and returns the metadata of mods or plugins inside it.
+// A forge jar may contain multiple mods
+func ReadModMetadata(filename string) (metas []*ModMetadata, err error) {
+	var r *zip.ReadCloser
+	if r, err = zip.OpenReader(filename); err != nil {
+		return
+	}
+	defer r.Close()
+	return readZipModMetadata(&r.Reader, filename, 0)
+}
+
+type modMetadataParser func(r *zip.Reader, jar string, data []byte) ([]*ModMetadata, error)
+
+// modMetadataParsers are tried in order, all matched parsers' results are returned
+var modMetadataParsers = []struct {
+	name   string
+	parser modMetadataParser
+}{
+	{"quilt.mod.json", parseQuiltModJson},
+	{"fabric.mod.json", parseFabricModJson},
+	{"META-INF/neoforge.mods.toml", forgeModsTomlParser("neoforge")},
+	{"META-INF/mods.toml", forgeModsTomlParser("forge")},
+	{"mcmod.info", parseMcmodInfo},
+	{"paper-plugin.yml", parsePaperPluginYml},
+	{"plugin.yml", parsePluginYml},
+	{"bungee.yml", parseBungeeYml},
+	{"velocity-plugin.json", parseVelocityPluginJson},
+}
+
+const maxBundleDepth = 3
+
+func readZipModMetadata(r *zip.Reader, jar string, depth int) (metas []*ModMetadata, err error) {
+	seen := make(map[string]bool)
+	for _, p := range modMetadataParsers {
+		var data []byte
+		if data, err = readZipFile(r, p.name); err != nil {
+			if err == errZipFileNotFound {
+				err = nil
+				continue
+			}
+			return
+		}
+		var res []*ModMetadata
+		if res, err = p.parser(r, jar, data); err != nil {
+			return nil, fmt.Errorf("%s: %s: %w", jar, p.name, err)
+		}
+		for _, m := range res {
+			key := strings.Join(m.Loaders, ",") + ":" + m.Id
+			if seen[key] {
+				continue
+			}
+			seen[key] = true
+			m.Path = jar
+			m.Format = path.Base(p.name)
+			if m.Minecraft == "" {
+				for _, d := range m.Dependencies {
+					if d.Id == "minecraft" && d.Kind == ModDepRequired {
+						m.Minecraft = d.Range
+						break
+					}
+				}
+			}
+			metas = append(metas, m)
+		}
+	}
+	if len(metas) == 0 {
+		return nil, &NoModMetadataErr{jar}
+	}
+	if depth < maxBundleDepth {
+		for _, m := range metas {
+			readBundledMods(r, m, depth)
+		}
+	}
+	return
+}
+
+var errZipFileNotFound = errors.New("File not found in zip")
+
+func readZipFile(r *zip.Reader, name string) (data []byte, err error) {
+	for _, f := range r.File {
+		if f.Name == name {
+			var fd io.ReadCloser
+			if fd, err = f.Open(); err != nil {
+				return
+			}
+			defer fd.Close()
+			return io.ReadAll(fd)
+		}
+	}
+	return nil, errZipFileNotFound
+}
+
+// bundledJars returns the paths of jar-in-jar files declared by the metadata
+func bundledJars(r *zip.Reader, m *ModMetadata) (jars []string) {
+	switch m.Format {
+	case "fabric.mod.json":
+		var data struct {
+			Jars []struct {
+				File string `json:"file"`
+			} `json:"jars"`
+		}
+		if buf, err := readZipFile(r, "fabric.mod.json"); err == nil && json.Unmarshal(buf, &data) == nil {
+			for _, j := range data.Jars {
+				jars = append(jars, j.File)
+			}
+		}
+	case "quilt.mod.json":
+		var data struct {
+			QuiltLoader struct {
+				Jars []string `json:"jars"`
+			} `json:"quilt_loader"`
+		}
+		if buf, err := readZipFile(r, "quilt.mod.json"); err == nil && json.Unmarshal(buf, &data) == nil {
+			jars = data.QuiltLoader.Jars
+		}
+	case "mods.toml", "neoforge.mods.toml":
+		var data struct {
+			Jars []struct {
+				Path string `json:"path"`
+			} `json:"jars"`
+		}
+		if buf, err := readZipFile(r, "META-INF/jarjar/metadata.json"); err == nil && json.Unmarshal(buf, &data) == nil {
+			for _, j := range data.Jars {
+				jars = append(jars, j.Path)
+			}
+		}
+	}
+	return
+}
+
+func readBundledMods(r *zip.Reader, m *ModMetadata, depth int) {
+	for _, name := range bundledJars(r, m) {
+		buf, err := readZipFile(r, name)
+		if err != nil {
+			continue
+		}
+		inner, err := zip.NewReader(bytes.NewReader(buf), (int64)(len(buf)))
+		if err != nil {
+			continue
+		}
+		bundled, err := readZipModMetadata(inner, m.Path+"!/"+name, depth+1)
+		if err != nil {
+			continue
+		}
+		m.Bundled = append(m.Bundled, bundled...)
+	}
+}
+
+// readJarManifest reads the main attributes of META-INF/MANIFEST.MF
+func readJarManifest(r *zip.Reader) (attrs StringMap) {
+	attrs = make(StringMap)
+	data, err := readZipFile(r, "META-INF/MANIFEST.MF")
+	if err != nil {
+		return
+	}
+	var last string
+	sc := bufio.NewScanner(bytes.NewReader(data))
+	for sc.Scan() {
+		line := strings.TrimRight(sc.Text(), "\r")
+		if line == "" {
+			break // end of main section
+		}
+		if line[0] == ' ' && last != "" {
+			attrs[last] += line[1:]
+			continue
+		}
+		if k, v, ok := strings.Cut(line, ":"); ok {
+			last = strings.TrimSpace(k)
+			attrs[last] = strings.TrimSpace(v)
+		}
+	}
+	return
+}
+
+// decodeStringOrArray decodes a json value which can be a string or an array of strings
+func decodeStringOrArray(raw json.RawMessage) (values []string) {
+	var s string
+	if json.Unmarshal(raw, &s) == nil {
+		return []string{s}
+	}
+	json.Unmarshal(raw, &values)
+	return
+}
+
+func parseFabricModJson(r *zip.Reader, jar string, data []byte) (metas []*ModMetadata, err error) {
+	var mod struct {
+		Id          string                     `json:"id"`
+		Version     string                     `json:"version"`
+		Name        string                     `json:"name"`
+		Environment string                     `json:"environment"`
+		Entrypoints map[string]json.RawMessage `json:"entrypoints"`
+		Depends     map[string]json.RawMessage `json:"depends"`
+		Recommends  map[string]json.RawMessage `json:"recommends"`
+		Suggests    map[string]json.RawMessage `json:"suggests"`
+		Breaks      map[string]json.RawMessage `json:"breaks"`
+		Provides    []string                   `json:"provides"`
+	}
+	// some mods contain control characters in descriptions, which is accepted by fabric loader
+	data = bytes.Map(func(r rune) rune {
+		if r == '\n' || r == '\r' || r == '\t' || r >= ' ' {
+			return r
+		}
+		return -1
+	}, data)
+	if err = json.Unmarshal(data, &mod); err != nil {
+		return
+	}
+	m := &ModMetadata{
+		Id:          mod.Id,
+		Name:        mod.Name,
+		Version:     mod.Version,
+		Loaders:     []string{"fabric"},
+		Environment: mod.Environment,
+		Provides:    mod.Provides,
+	}
+	if m.Environment == "" {
+		m.Environment = ModEnvAny
+	}
+	for kind, _ := range mod.Entrypoints {
+		m.Entrypoints = append(m.Entrypoints, kind)
+	}
+	sort.Strings(m.Entrypoints)
+	addDeps := func(deps map[string]json.RawMessage, kind string) {
+		for id, raw := range deps {
+			m.Dependencies = append(m.Dependencies, ModDependency{
+				Id:    id,
+				Range: strings.Join(decodeStringOrArray(raw), " || "),
+				Kind:  kind,
+			})
+		}
+	}
+	addDeps(mod.Depends, ModDepRequired)
+	addDeps(mod.Recommends, ModDepOptional)
+	addDeps(mod.Suggests, ModDepOptional)
+	addDeps(mod.Breaks, ModDepIncompatible)
+	sortModDependencies(m.Dependencies)
+	return []*ModMetadata{m}, nil
+}
+
+type quiltDependency struct {
+	Id       string
+	Versions string
+	Optional bool
+}
+
+func (d *quiltDependency) UnmarshalJSON(buf []byte) (err error) {
+	var s string
+	if json.Unmarshal(buf, &s) == nil {
+		d.Id = s
+		return
+	}
+	var obj struct {
+		Id       string          `json:"id"`
+		Versions json.RawMessage `json:"versions"`
+		Optional bool            `json:"optional"`
+	}
+	if err = json.Unmarshal(buf, &obj); err != nil {
+		return
+	}
+	d.Id = obj.Id
+	d.Optional = obj.Optional
+	if len(obj.Versions) > 0 {
+		d.Versions = strings.Join(decodeStringOrArray(obj.Versions), " || ")
+	}
+	return
+}
+
+// trimQuiltGroup removes the maven group from quilt mod ids
+func trimQuiltGroup(id string) string {
+	if i := strings.IndexByte(id, ':'); i >= 0 {
+		return id[i+1:]
+	}
+	return id
+}
+
+func parseQuiltModJson(r *zip.Reader, jar string, data []byte) (metas []*ModMetadata, err error) {
+	var mod struct {
+		QuiltLoader struct {
+			Id       string `json:"id"`
+			Version  string `json:"version"`
+			Metadata struct {
+				Name string `json:"name"`
+			} `json:"metadata"`
+			Entrypoints map[string]json.RawMessage `json:"entrypoints"`
+			Depends     []quiltDependency          `json:"depends"`
+			Breaks      []quiltDependency          `json:"breaks"`
+			Provides    []quiltDependency          `json:"provides"`
+		} `json:"quilt_loader"`
+		Minecraft struct {
+			Environment string `json:"environment"`
+		} `json:"minecraft"`
+	}
+	if err = json.Unmarshal(data, &mod); err != nil {
+		return
+	}
+	ql := mod.QuiltLoader
+	m := &ModMetadata{
+		Id:          ql.Id,
+		Name:        ql.Metadata.Name,
+		Version:     ql.Version,
+		Loaders:     []string{"quilt"},
+		Environment: ModEnvAny,
+	}
+	switch mod.Minecraft.Environment {
+	case "client":
+		m.Environment = ModEnvClient
+	case "dedicated_server":
+		m.Environment = ModEnvServer
+	}
+	for kind, _ := range ql.Entrypoints {
+		m.Entrypoints = append(m.Entrypoints, kind)
+	}
+	sort.Strings(m.Entrypoints)
+	for _, p := range ql.Provides {
+		m.Provides = append(m.Provides, trimQuiltGroup(p.Id))
+	}
+	for _, d := range ql.Depends {
+		kind := ModDepRequired
+		if d.Optional {
+			kind = ModDepOptional
+		}
+		m.Dependencies = append(m.Dependencies, ModDependency{
+			Id:    trimQuiltGroup(d.Id),
+			Range: d.Versions,
+			Kind:  kind,
+		})
+	}
+	for _, d := range ql.Breaks {
+		m.Dependencies = append(m.Dependencies, ModDependency{
+			Id:    trimQuiltGroup(d.Id),
+			Range: d.Versions,
+			Kind:  ModDepIncompatible,
+		})
+	}
+	sortModDependencies(m.Dependencies)
+	return []*ModMetadata{m}, nil
+}
+
+type forgeModsToml struct {
+	ModLoader      string `toml:"modLoader"`
+	LoaderVersion  string `toml:"loaderVersion"`
+	ClientSideOnly bool   `toml:"clientSideOnly"`
+	Mods           []struct {
+		ModId       string `toml:"modId"`
+		Version     string `toml:"version"`
+		DisplayName string `toml:"displayName"`
+		DisplayTest string `toml:"displayTest"`
+	} `toml:"mods"`
+	Dependencies map[string][]struct {
+		ModId        string `toml:"modId"`
+		Mandatory    *bool  `toml:"mandatory"`
+		Type         string `toml:"type"`
+		VersionRange string `toml:"versionRange"`
+		Side         string `toml:"side"`
+	} `toml:"dependencies"`
+}
+
+// forgeModsTomlParser returns the parser of mods.toml for the loader.
+// The loader is decided by the file name, since neoforge reads META-INF/neoforge.mods.toml since 20.5
+func forgeModsTomlParser(loader string) modMetadataParser {
+	return func(r *zip.Reader, jar string, data []byte) ([]*ModMetadata, error) {
+		return parseForgeModsToml(r, jar, data, loader)
+	}
+}
+
+func parseForgeModsToml(r *zip.Reader, jar string, data []byte, loader string) (metas []*ModMetadata, err error) {
+	var mods forgeModsToml
+	if _, err = toml.Decode((string)(data), &mods); err != nil {
+		return
+	}
+	var jarVersion string
+	for _, mod := range mods.Mods {
+		version := mod.Version
+		if strings.Contains(version, "${file.jarVersion}") {
+			if jarVersion == "" {
+				jarVersion = readJarManifest(r)["Implementation-Version"]
+			}
+			version = strings.ReplaceAll(version, "${file.jarVersion}", jarVersion)
+		}
+		m := &ModMetadata{
+			Id:             mod.ModId,
+			Name:           mod.DisplayName,
+			Version:        version,
+			Loaders:        []string{loader},
+			Environment:    ModEnvAny,
+			DisplayTest:    mod.DisplayTest,
+			ClientSideOnly: mods.ClientSideOnly,
+		}
+		if mods.ModLoader == "javafml" && mods.LoaderVersion != "" {
+			m.Dependencies = append(m.Dependencies, ModDependency{
+				Id:    "javafml",
+				Range: mods.LoaderVersion,
+				Kind:  ModDepRequired,
+				Maven: true,
+			})
+		}
+		for _, d := range mods.Dependencies[mod.ModId] {
+			kind := ModDepRequired
+			switch strings.ToLower(d.Type) {
+			case "optional", "discouraged":
+				kind = ModDepOptional
+			case "incompatible":
+				kind = ModDepIncompatible
+			case "":
+				if d.Mandatory != nil && !*d.Mandatory {
+					kind = ModDepOptional
+				}
+			}
+			if d.ModId == "neoforge" && kind == ModDepRequired {
+				// neoforge before 20.5 still reads META-INF/mods.toml
+				m.Loaders = []string{"neoforge"}
+			}
+			side := ModEnvAny
+			switch strings.ToUpper(d.Side) {
+			case "CLIENT":
+				side = ModEnvClient
+			case "SERVER":
+				side = ModEnvServer
+			}
+			m.Dependencies = append(m.Dependencies, ModDependency{
+				Id:    d.ModId,
+				Range: d.VersionRange,
+				Kind:  kind,
+				Side:  side,
+				Maven: true,
+			})
+		}
+		metas = append(metas, m)
+	}
+	return
+}
+
+// mcmodInfo is the metadata of the forge mods before 1.13
+type mcmodInfo struct {
+	ModId        string   `json:"modid"`
+	Name         string   `json:"name"`
+	Version      string   `json:"version"`
+	McVersion    string   `json:"mcversion"`
+	RequiredMods []string `json:"requiredMods"`
+	Dependencies []string `json:"dependencies"`
+	UseDepInfo   bool     `json:"useDependencyInformation"`
+}
+
+func parseMcmodInfo(r *zip.Reader, jar string, data []byte) (metas []*ModMetadata, err error) {
+	var mods []mcmodInfo
+	data = bytes.TrimSpace(data)
+	if len(data) > 0 && data[0] == '{' {
+		var list struct {
+			ModList []mcmodInfo `json:"modList"`
+		}
+		if err = json.Unmarshal(data, &list); err != nil {
+			return
+		}
+		mods = list.ModList
+	} else if err = json.Unmarshal(data, &mods); err != nil {
+		return
+	}
+	for _, mod := range mods {
+		m := &ModMetadata{
+			Id:          mod.ModId,
+			Name:        mod.Name,
+			Version:     mod.Version,
+			Loaders:     []string{"forge"},
+			Environment: ModEnvAny,
+			Minecraft:   mod.McVersion,
+		}
+		if strings.Contains(m.Version, "${") {
+			// the placeholders are not replaced by some build scripts
+			m.Version = readJarManifest(r)["Implementation-Version"]
+		}
+		if mod.UseDepInfo {
+			required := make(map[string]bool)
+			for _, d := range mod.RequiredMods {
+				id, rg, _ := strings.Cut(d, "@")
+				required[id] = true
+				m.Dependencies = append(m.Dependencies, ModDependency{Id: id, Range: rg, Kind: ModDepRequired, Maven: true})
+			}
+			// dependencies are only the load order, the required ones are in requiredMods
+			for _, d := range mod.Dependencies {
+				id, rg, _ := strings.Cut(d, "@")
+				if !required[id] {
+					m.Dependencies = append(m.Dependencies, ModDependency{Id: id, Range: rg, Kind: ModDepOptional, Maven: true})
+				}
+			}
+			sortModDependencies(m.Dependencies)
+		}
+		metas = append(metas, m)
+	}
+	return
+}
+
+// pluginVersion returns the yaml version as it's written, so 1.0 is not parsed as the number 1
+func pluginVersion(v yaml.Node) string {
+	if v.Kind != yaml.ScalarNode {
+		return ""
+	}
+	return v.Value
+}
+
+// pluginApiRange returns the minecraft range of the api-version, the plugin is made for the version or later
+func pluginApiRange(v yaml.Node) string {
+	if api := pluginVersion(v); api != "" {
+		return ">=" + api
+	}
+	return ""
+}
+
+func parsePluginYml(r *zip.Reader, jar string, data []byte) (metas []*ModMetadata, err error) {
+	var plugin struct {
+		Name       string    `yaml:"name"`
+		Version    yaml.Node `yaml:"version"`
+		Main       string    `yaml:"main"`
+		ApiVersion yaml.Node `yaml:"api-version"`
+		Depend     []string  `yaml:"depend"`
+		SoftDepend []string  `yaml:"softdepend"`
+	}
+	if err = yaml.Unmarshal(data, &plugin); err != nil {
+		return
+	}
+	m := &ModMetadata{
+		Id:          plugin.Name,
+		Name:        plugin.Name,
+		Version:     pluginVersion(plugin.Version),
+		Loaders:     []string{"bukkit"},
+		Environment: ModEnvServer,
+		Minecraft:   pluginApiRange(plugin.ApiVersion),
+	}
+	for _, d := range plugin.Depend {
+		m.Dependencies = append(m.Dependencies, ModDependency{Id: d, Kind: ModDepRequired})
+	}
+	for _, d := range plugin.SoftDepend {
+		m.Dependencies = append(m.Dependencies, ModDependency{Id: d, Kind: ModDepOptional})
+	}
+	return []*ModMetadata{m}, nil
+}
+
+// paperDependency is a dependency of paper-plugin.yml
+type paperDependency struct {
+	Name     string `yaml:"name"`
+	Required *bool  `yaml:"required"`
+}
+
+func (d paperDependency) kind() string {
+	// paper plugin dependencies are required by default
+	if d.Required != nil && !*d.Required {
+		return ModDepOptional
+	}
+	return ModDepRequired
+}
+
+func parsePaperPluginYml(r *zip.Reader, jar string, data []byte) (metas []*ModMetadata, err error) {
+	var plugin struct {
+		Name         string    `yaml:"name"`
+		Version      yaml.Node `yaml:"version"`
+		ApiVersion   yaml.Node `yaml:"api-version"`
+		Dependencies yaml.Node `yaml:"dependencies"`
+	}
+	if err = yaml.Unmarshal(data, &plugin); err != nil {
+		return
+	}
+	m := &ModMetadata{
+		Id:          plugin.Name,
+		Name:        plugin.Name,
+		Version:     pluginVersion(plugin.Version),
+		Loaders:     []string{"paper"},
+		Environment: ModEnvServer,
+		Minecraft:   pluginApiRange(plugin.ApiVersion),
+	}
+	var deps []paperDependency
+	switch plugin.Dependencies.Kind {
+	case yaml.SequenceNode:
+		// the format before paper 1.20
+		if err = plugin.Dependencies.Decode(&deps); err != nil {
+			return
+		}
+	case yaml.MappingNode:
+		var phases struct {
+			Bootstrap map[string]paperDependency `yaml:"bootstrap"`
+			Server    map[string]paperDependency `yaml:"server"`
+		}
+		if err = plugin.Dependencies.Decode(&phases); err != nil {
+			return
+		}
+		for _, phase := range []map[string]paperDependency{phases.Bootstrap, phases.Server} {
+			for name, d := range phase {
+				d.Name = name
+				deps = append(deps, d)
+			}
+		}
+	}
+	seen := make(map[string]bool)
+	for _, d := range deps {
+		if seen[d.Name] {
+			continue
+		}
+		seen[d.Name] = true
+		m.Dependencies = append(m.Dependencies, ModDependency{Id: d.Name, Kind: d.kind()})
+	}
+	sortModDependencies(m.Dependencies)
+	return []*ModMetadata{m}, nil
+}
+
+func parseBungeeYml(r *zip.Reader, jar string, data []byte) (metas []*ModMetadata, err error) {
+	var plugin struct {
+		Name        string    `yaml:"name"`
+		Version     yaml.Node `yaml:"version"`
+		Depends     []string  `yaml:"depends"`
+		SoftDepends []string  `yaml:"softDepends"`
+	}
+	if err = yaml.Unmarshal(data, &plugin); err != nil {
+		return
+	}
+	m := &ModMetadata{
+		Id:          plugin.Name,
+		Name:        plugin.Name,
+		Version:     pluginVersion(plugin.Version),
+		Loaders:     []string{"bungeecord"},
+		Environment: ModEnvServer,
+	}
+	for _, d := range plugin.Depends {
+		m.Dependencies = append(m.Dependencies, ModDependency{Id: d, Kind: ModDepRequired})
+	}
+	for _, d := range plugin.SoftDepends {
+		m.Dependencies = append(m.Dependencies, ModDependency{Id: d, Kind: ModDepOptional})
+	}
+	return []*ModMetadata{m}, nil
+}
+
+func parseVelocityPluginJson(r *zip.Reader, jar string, data []byte) (metas []*ModMetadata, err error) {
+	var plugin struct {
+		Id           string `json:"id"`
+		Name         string `json:"name"`
+		Version      string `json:"version"`
+		Dependencies []struct {
+			Id       string `json:"id"`
+			Optional bool   `json:"optional"`
+		} `json:"dependencies"`
+	}
+	if err = json.Unmarshal(data, &plugin); err != nil {
+		return
+	}
+	m := &ModMetadata{
+		Id:          plugin.Id,
+		Name:        plugin.Name,
+		Version:     plugin.Version,
+		Loaders:     []string{"velocity"},
+		Environment: ModEnvServer,
+	}
+	for _, d := range plugin.Dependencies {
+		kind := ModDepRequired
+		if d.Optional {
+			kind = ModDepOptional
+		}
+		m.Dependencies = append(m.Dependencies, ModDependency{Id: d.Id, Kind: kind})
+	}
+	sortModDependencies(m.Dependencies)
+	return []*ModMetadata{m}, nil
+}
+
+func sortModDependencies(deps []ModDependency) {
+	// map iteration order is random, keep the output stable
+	sort.SliceStable(deps, func(i, j int) bool { return deps[i].Id < deps[j].Id })
+}
@@ -0,0 +1,417 @@
+package installer
+
+import (
+	"encoding/json"
+	"net/url"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+type (
+	ModrinthProject struct {
+		Id           string   `json:"id"`
+		Slug         string   `json:"slug"`
+		Title        string   `json:"title"`
+		Description  string   `json:"description"`
+		ProjectType  string   `json:"project_type"`
+		ClientSide   string   `json:"client_side"`
+		ServerSide   string   `json:"server_side"`
+		Loaders      []string `json:"loaders"`
+		GameVersions []string `json:"game_versions"`
+		Versions     []string `json:"versions"`
+	}
+	ModrinthVersionFile struct {
+		Hashes   StringMap `json:"hashes"`
+		Url      string    `json:"url"`
+		Filename string    `json:"filename"`
+		Primary  bool      `json:"primary"`
+		Size     int64     `json:"size"`
+	}
+	ModrinthDependency struct {
+		VersionId      string `json:"version_id,omitempty"`
+		ProjectId      string `json:"project_id,omitempty"`
+		FileName       string `json:"file_name,omitempty"`
+		DependencyType string `json:"dependency_type"`
+	}
+	ModrinthVersion struct {
+		Id            string                `json:"id"`
+		ProjectId     string                `json:"project_id"`
+		Name          string                `json:"name"`
+		VersionNumber string                `json:"version_number"`
+		VersionType   string                `json:"version_type"`
+		Loaders       []string              `json:"loaders"`
+		GameVersions  []string              `json:"game_versions"`
+		DatePublished time.Time             `json:"date_published"`
+		Files         []ModrinthVersionFile `json:"files"`
+		Dependencies  []ModrinthDependency  `json:"dependencies"`
+	}
+
+	// ModrinthInstalledFile records a file installed by ModrinthClient
+	ModrinthInstalledFile struct {
+		ProjectId     string    `json:"projectId"`
+		VersionId     string    `json:"versionId"`
+		VersionNumber string    `json:"versionNumber"`
+		Path          string    `json:"path"` // relative to the server directory
+		Hashes        StringMap `json:"hashes"`
+	}
+
+	ModrinthClient struct {
+		BaseUrl string // Default is "https://api.modrinth.com/v2"
+	}
+)
+
+const (
+	ModrinthDepRequired     = "required"
+	ModrinthDepOptional     = "optional"
+	ModrinthDepIncompatible = "incompatible"
+	ModrinthDepEmbedded     = "embedded"
+)
+
+var DefaultModrinthClient = &ModrinthClient{
+	BaseUrl: "https://api.modrinth.com/v2",
+}
+
+// modrinthCompatibleLoaders maps a server type to the modrinth loaders it can load, ordered by preference
+var modrinthCompatibleLoaders = map[string][]string{
+	"fabric":   {"fabric"},
+	"quilt":    {"quilt", "fabric"},
+	"forge":    {"forge"},
+	"neoforge": {"neoforge"},
+	"spigot":   {"spigot", "bukkit"},
+	"paper":    {"paper", "spigot", "bukkit"},
+	// waterfall runs the bungeecord plugins too
+	"waterfall": {"waterfall", "bungeecord"},
+}
+
+var modrinthPluginLoaders = map[string]bool{
+	"bukkit":     true,
+	"spigot":     true,
+	"paper":      true,
+	"purpur":     true,
+	"folia":      true,
+	"bungeecord": true,
+	"waterfall":  true,
+	"velocity":   true,
+}
+
+// ModrinthLoadersFor returns the modrinth loaders that can be used by the server type
+func ModrinthLoadersFor(serverType string) []string {
+	if loaders, ok := modrinthCompatibleLoaders[serverType]; ok {
+		return loaders
+	}
+	return []string{serverType}
+}
+
+// ModrinthInstallDir returns the directory that the files of the loader should be placed
+func ModrinthInstallDir(loader string) string {
+	if modrinthPluginLoaders[loader] {
+		return "plugins"
+	}
+	return "mods"
+}
+
+func (c *ModrinthClient) getJson(obj any, query url.Values, paths ...string) (err error) {
+	var link string
+	if link, err = url.JoinPath(c.BaseUrl, paths...); err != nil {
+		return
+	}
+	if len(query) > 0 {
+		link += "?" + query.Encode()
+	}
+	return DefaultHTTPClient.GetJson(link, obj)
+}
+
+func encodeJsonArray(values []string) string {
+	buf, _ := json.Marshal(values)
+	return (string)(buf)
+}
+
+func (c *ModrinthClient) GetProject(idOrSlug string) (project *ModrinthProject, err error) {
+	project = new(ModrinthProject)
+	if err = c.getJson(project, nil, "project", idOrSlug); err != nil {
+		return nil, err
+	}
+	return
+}
+
+// GetProjectVersions returns the versions of the project, newest first.
+// Empty loaders or gameVersions will not be used to filter the versions
+func (c *ModrinthClient) GetProjectVersions(idOrSlug string, loaders []string, gameVersions []string) (versions []*ModrinthVersion, err error) {
+	query := make(url.Values, 2)
+	if len(loaders) > 0 {
+		query.Set("loaders", encodeJsonArray(loaders))
+	}
+	if len(gameVersions) > 0 {
+		query.Set("game_versions", encodeJsonArray(gameVersions))
+	}
+	if err = c.getJson(&versions, query, "project", idOrSlug, "version"); err != nil {
+		return
+	}
+	return
+}
+
+func (c *ModrinthClient) GetVersion(id string) (version *ModrinthVersion, err error) {
+	version = new(ModrinthVersion)
+	if err = c.getJson(version, nil, "version", id); err != nil {
+		return nil, err
+	}
+	return
+}
+
+// GetProjectVersion returns the version of the project by the version's id or version number
+func (c *ModrinthClient) GetProjectVersion(idOrSlug string, idOrNumber string) (version *ModrinthVersion, err error) {
+	version = new(ModrinthVersion)
+	if err = c.getJson(version, nil, "project", idOrSlug, "version", idOrNumber); err != nil {
+		return nil, err
+	}
+	return
+}
+
+// PrimaryFile returns the primary file of the version, or the first file if none of them are marked as primary
+func (v *ModrinthVersion) PrimaryFile() *ModrinthVersionFile {
+	for i, f := range v.Files {
+		if f.Primary {
+			return &v.Files[i]
+		}
+	}
+	if len(v.Files) > 0 {
+		return &v.Files[0]
+	}
+	return nil
+}
+
+func (v *ModrinthVersion) matchLoader(loaders []string) string {
+	for _, l := range loaders {
+		for _, vl := range v.Loaders {
+			if l == vl {
+				return l
+			}
+		}
+	}
+	return ""
+}
+
+// ResolveVersion picks the version of the project that matches the server type and the game version.
+// If version is not empty, it will be used as the version id or version number.
+// Otherwise the newest release will be picked, or the newest beta/alpha if there is no release.
+func (c *ModrinthClient) ResolveVersion(idOrSlug string, version string, serverType string, gameVersion string) (res *ModrinthVersion, err error) {
+	loaders := ModrinthLoadersFor(serverType)
+	if version != "" {
+		if res, err = c.GetProjectVersion(idOrSlug, version); err != nil {
+			if _, ok := err.(*HttpStatusError); ok {
+				err = &VersionNotFoundErr{idOrSlug + "@" + version}
+			}
+			return
+		}
+		if res.matchLoader(loaders) == "" {
+			loger.Warnf("Version %s of %q does not declare support for %v (supports %v)", version, idOrSlug, loaders, res.Loaders)
+		}
+		return
+	}
+	var gameVersions []string
+	if gameVersion != "" {
+		gameVersions = []string{gameVersion}
+	}
+	var versions []*ModrinthVersion
+	if versions, err = c.GetProjectVersions(idOrSlug, loaders, gameVersions); err != nil {
+		return
+	}
+	for _, l := range loaders {
+		for _, v := range versions {
+			if v.matchLoader([]string{l}) == "" {
+				continue
+			}
+			if v.VersionType == "release" {
+				return v, nil
+			}
+			if res == nil {
+				res = v
+			}
+		}
+		if res != nil {
+			return
+		}
+	}
+	return nil, &VersionNotFoundErr{idOrSlug + " for " + serverType + " " + gameVersion}
+}
+
+// ResolveWithDependencies resolves the project and all its required dependencies transitively
+func (c *ModrinthClient) ResolveWithDependencies(idOrSlug string, version string, serverType string, gameVersion string) (versions []*ModrinthVersion, err error) {
+	var root *ModrinthVersion
+	if root, err = c.ResolveVersion(idOrSlug, version, serverType, gameVersion); err != nil {
+		return
+	}
+	visited := map[string]bool{root.ProjectId: true}
+	queue := []*ModrinthVersion{root}
+	for len(queue) > 0 {
+		v := queue[0]
+		queue = queue[1:]
+		versions = append(versions, v)
+		for _, d := range v.Dependencies {
+			if d.DependencyType != ModrinthDepRequired {
+				continue
+			}
+			if d.ProjectId != "" && visited[d.ProjectId] {
+				continue
+			}
+			var dep *ModrinthVersion
+			if d.VersionId != "" {
+				if dep, err = c.GetVersion(d.VersionId); err != nil {
+					return
+				}
+			} else if d.ProjectId != "" {
+				if dep, err = c.ResolveVersion(d.ProjectId, "", serverType, gameVersion); err != nil {
+					return
+				}
+			} else {
+				loger.Warnf("Skipped dependency %q of %q since it's not hosted on modrinth", d.FileName, v.ProjectId)
+				continue
+			}
+			if visited[dep.ProjectId] {
+				continue
+			}
+			visited[dep.ProjectId] = true
+			loger.Infof("Resolved dependency %s(%s) of %s", dep.ProjectId, dep.VersionNumber, v.ProjectId)
+			queue = append(queue, dep)
+		}
+	}
+	return
+}
+
+// InstallMod installs the mod or plugin and its required dependencies into the server at path
+func (c *ModrinthClient) InstallMod(path string, idOrSlug string, version string, serverType string, gameVersion string) (installed []ModrinthInstalledFile, err error) {
+	var versions []*ModrinthVersion
+	loger.Infof("Resolving %q for %s %s ...", idOrSlug, serverType, gameVersion)
+	if versions, err = c.ResolveWithDependencies(idOrSlug, version, serverType, gameVersion); err != nil {
+		return
+	}
+	loaders := ModrinthLoadersFor(serverType)
+	for _, v := range versions {
+		f := v.PrimaryFile()
+		if f == nil {
+			return installed, &AssetNotFoundErr{v.VersionNumber, v.ProjectId + " file"}
+		}
+		loader := v.matchLoader(loaders)
+		if loader == "" && len(v.Loaders) > 0 {
+			loader = v.Loaders[0]
+		}
+		if strings.ContainsAny(f.Filename, `/\`) {
+			return installed, &NotLocalPathErr{f.Filename}
+		}
+		rel := filepath.Join(ModrinthInstallDir(loader), f.Filename)
+		if err = downloadAnyAndCheckHashes([]string{f.Url}, filepath.Join(path, rel), f.Hashes, f.Size); err != nil {
+			return
+		}
+		installed = append(installed, ModrinthInstalledFile{
+			ProjectId:     v.ProjectId,
+			VersionId:     v.Id,
+			VersionNumber: v.VersionNumber,
+			Path:          filepath.ToSlash(rel),
+			Hashes:        f.Hashes,
+		})
+	}
+	return
+}
+
+type (
+	ModrinthSearchHit struct {
+		ProjectId   string `json:"project_id"`
+		Slug        string `json:"slug"`
+		Title       string `json:"title"`
+		Description string `json:"description"`
+		Author      string `json:"author"`
+		ProjectType string `json:"project_type"`
+	}
+	modrinthSearchResult struct {
+		Hits []ModrinthSearchHit `json:"hits"`
+	}
+)
+
+// Search searches projects, each facet group is ANDed and the facets inside a group are ORed
+func (c *ModrinthClient) Search(query string, facets [][]string) (hits []ModrinthSearchHit, err error) {
+	q := url.Values{
+		"query": {query},
+		"limit": {"25"},
+	}
+	if len(facets) > 0 {
+		buf, _ := json.Marshal(facets)
+		q.Set("facets", (string)(buf))
+	}
+	var res modrinthSearchResult
+	if err = c.getJson(&res, q, "search"); err != nil {
+		return
+	}
+	return res.Hits, nil
+}
+
+// ModrinthPluginSource uses modrinth as a PluginSource
+type ModrinthPluginSource struct {
+	Client *ModrinthClient
+}
+
+var _ PluginSource = (*ModrinthPluginSource)(nil)
+
+func init() {
+	PluginSources["modrinth"] = &ModrinthPluginSource{Client: DefaultModrinthClient}
+}
+
+func (*ModrinthPluginSource) Name() string {
+	return "modrinth"
+}
+
+func (s *ModrinthPluginSource) Search(query string, platform string, gameVersion string) (plugins []PluginInfo, err error) {
+	facets := [][]string{{"project_type:plugin"}}
+	if platform != "" {
+		loaders := ModrinthLoadersFor(platform)
+		group := make([]string, len(loaders))
+		for i, l := range loaders {
+			group[i] = "categories:" + l
+		}
+		facets = append(facets, group)
+	}
+	if gameVersion != "" {
+		facets = append(facets, []string{"versions:" + gameVersion})
+	}
+	var hits []ModrinthSearchHit
+	if hits, err = s.Client.Search(query, facets); err != nil {
+		return
+	}
+	plugins = make([]PluginInfo, len(hits))
+	for i, h := range hits {
+		plugins[i] = PluginInfo{
+			Source:      s.Name(),
+			Id:          h.Slug,
+			Name:        h.Title,
+			Author:      h.Author,
+			Description: h.Description,
+		}
+	}
+	return
+}
+
+func (s *ModrinthPluginSource) Resolve(id string, version string, platform string, gameVersion string) (release *PluginRelease, err error) {
+	var v *ModrinthVersion
+	if v, err = s.Client.ResolveVersion(id, version, platform, gameVersion); err != nil {
+		return
+	}
+	f := v.PrimaryFile()
+	if f == nil {
+		return nil, &AssetNotFoundErr{v.VersionNumber, id + " file"}
+	}
+	release = &PluginRelease{
+		Source:   s.Name(),
+		PluginId: id,
+		Version:  v.VersionNumber,
+		FileName: f.Filename,
+		Url:      f.Url,
+		Hashes:   f.Hashes,
+		Size:     f.Size,
+	}
+	for _, d := range v.Dependencies {
+		if d.DependencyType == ModrinthDepRequired && d.ProjectId != "" {
+			release.Dependencies = append(release.Dependencies, d.ProjectId)
+		}
+	}
+	return
+}
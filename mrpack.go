@@ -3,13 +3,12 @@ package installer
 import (
 	"archive/zip"
 	"encoding/json"
+	"errors"
 	"fmt"
-	"io"
 	"io/fs"
 	"os"
 	"path/filepath"
 	"strings"
-	"sync"
 )
 
 const currentMrpackVersion = 1
@@ -31,15 +30,73 @@ func (e *MrpackVerisonErr) Error() string {
 	return fmt.Sprintf("Unsupport mrpack format version %d, supports %v", e.Version, e.Supports)
 }
 
+type MrpackFileErr struct {
+	Path string
+	Err  error
+}
+
+func (e *MrpackFileErr) Error() string {
+	return fmt.Sprintf("%q: %v", e.Path, e.Err)
+}
+
+func (e *MrpackFileErr) Unwrap() error {
+	return e.Err
+}
+
+// MrpackNoDownloadsErr is returned when a file of the modpack doesn't have any download link
+type MrpackNoDownloadsErr struct {
+	Path string
+}
+
+func (e *MrpackNoDownloadsErr) Error() string {
+	return fmt.Sprintf("Unexpect empty downloads of %q", e.Path)
+}
+
+// MrpackInstallErr contains all required files that failed to install
+type MrpackInstallErr struct {
+	Errs []*MrpackFileErr
+}
+
+func (e *MrpackInstallErr) Error() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "%d required file(s) failed to install:", len(e.Errs))
+	for _, fe := range e.Errs {
+		b.WriteString("\n  - ")
+		b.WriteString(fe.Error())
+	}
+	return b.String()
+}
+
+func (e *MrpackInstallErr) Unwrap() []error {
+	errs := make([]error, len(e.Errs))
+	for i, fe := range e.Errs {
+		errs[i] = fe
+	}
+	return errs
+}
+
+const (
+	DefaultMrpackParallelism     = 8
+	DefaultMrpackMaxConnsPerHost = 4
+)
+
 type (
 	Mrpack struct {
-		r *zip.ReadCloser
+		r        *zip.ReadCloser
+		filename string
 
 		MrpackMeta
 
 		overrides       []*zip.File
 		clientOverrides []*zip.File
 		serverOverrides []*zip.File
+
+		// Parallelism is the maximum number of files that download at the same time.
+		// If it's zero, DefaultMrpackParallelism will be used
+		Parallelism int
+		// MaxConnsPerHost is the maximum number of connections to a single host.
+		// If it's zero, DefaultMrpackMaxConnsPerHost will be used, negative value means no limit
+		MaxConnsPerHost int
 	}
 	MrpackMeta struct {
 		FormatVersion int    `json:"formatVersion"`
@@ -62,7 +119,7 @@ type (
 )
 
 func OpenMrpack(filename string) (pack *Mrpack, err error) {
-	pack = new(Mrpack)
+	pack = &Mrpack{filename: filename}
 	pack.r, err = zip.OpenReader(filename)
 	if err != nil {
 		return
@@ -86,6 +143,11 @@ func (p *Mrpack) Close() (err error) {
 	return p.r.Close()
 }
 
+// Sha1 returns the hash of the modpack file, it's recorded by InstallManifest.SetModpack
+func (p *Mrpack) Sha1() (string, error) {
+	return fileSha1(p.filename)
+}
+
 func (p *Mrpack) decodeIndex() (err error) {
 	var indexFd fs.File
 	indexFd, err = p.r.Open("modrinth.index.json")
@@ -112,16 +174,64 @@ func (p *Mrpack) InstallClient(target string) (err error) {
 }
 
 func (p *Mrpack) installWithEnv(env string, target string, optionalChecker MrpackOptionalChecker) (err error) {
+	plan, err := p.planWithEnv(env, target, optionalChecker)
+	if err != nil {
+		return
+	}
 	loger.Infof("Installing [%s]modpack %s(%s) to %q ...", p.Game, p.Name, p.VersionId, target)
+	_, err = plan.Execute()
+	return
+}
+
+// planWithEnv plans the files and the overrides of the env
+func (p *Mrpack) planWithEnv(env string, target string, optionalChecker MrpackOptionalChecker) (plan *InstallPlan, err error) {
+	loger.Infof("Planning [%s]modpack %s(%s) for %q ...", p.Game, p.Name, p.VersionId, target)
 	if len(p.Summary) > 0 {
 		loger.Infof("  Summary: %s", p.Summary)
 	}
 	if p.Game != "minecraft" {
-		return &UnsupportGameErr{
+		return nil, &UnsupportGameErr{
 			Game: p.Game,
 		}
 	}
-	var wg sync.WaitGroup
+	parallelism := p.Parallelism
+	if parallelism <= 0 {
+		parallelism = DefaultMrpackParallelism
+	}
+	plan = newInstallPlan("", target)
+	plan.Modpack = p.Name + " " + p.VersionId
+	plan.Parallelism = parallelism
+	plan.MaxConnsPerHost = p.MaxConnsPerHost
+	files, optional, err := p.selectFiles(env, optionalChecker)
+	if err != nil {
+		return nil, err
+	}
+	for i, f := range files {
+		plan.add(&PlanStep{
+			Kind:     StepDownload,
+			Urls:     f.Downloads,
+			Path:     filepath.Join(target, f.Path),
+			Size:     f.Size,
+			Hashes:   f.Hashes,
+			Optional: optional[i],
+			Mode:     0644,
+		})
+	}
+	envOverrides := p.serverOverrides
+	if env == "client" {
+		envOverrides = p.clientOverrides
+	}
+	if err = p.planOverrides(plan, target, p.overrides); err != nil {
+		return
+	}
+	if err = p.planOverrides(plan, target, envOverrides); err != nil {
+		return
+	}
+	return
+}
+
+// selectFiles returns the files that will be downloaded in the env, and reports whether each of them is optional
+func (p *Mrpack) selectFiles(env string, optionalChecker MrpackOptionalChecker) (files []MrpackFileMeta, optional []bool, err error) {
 	for _, f := range p.Files {
 		required := true
 		if f.Env != nil {
@@ -136,35 +246,95 @@ func (p *Mrpack) installWithEnv(env string, target string, optionalChecker Mrpac
 				required = false
 			}
 		}
-		wg.Add(1)
-		go func(f MrpackFileMeta) {
-			defer wg.Done()
-			if !filepath.IsLocal(f.Path) {
-				err = &NotLocalPathErr{f.Path}
-				goto checkErr
-			}
-			if err = downloadAnyAndCheckHashes(f.Downloads, filepath.Join(target, f.Path), f.Hashes, f.Size); err != nil {
-				goto checkErr
+		if !filepath.IsLocal(f.Path) {
+			if !required {
+				loger.Warnf("Skipped to install optional mod %q due %v", f.Path, &NotLocalPathErr{f.Path})
+				continue
 			}
-		checkErr:
-			if err != nil {
-				if required {
-					return
-				}
-				loger.Warnf("Skipped to install optional mod %q due %v", f.Path, err)
-				err = nil
+			return nil, nil, &MrpackInstallErr{Errs: []*MrpackFileErr{{Path: f.Path, Err: &NotLocalPathErr{f.Path}}}}
+		}
+		if len(f.Downloads) == 0 {
+			if !required {
+				loger.Warnf("Skipped to install optional mod %q due %v", f.Path, &MrpackNoDownloadsErr{f.Path})
+				continue
 			}
-		}(f)
+			return nil, nil, &MrpackInstallErr{Errs: []*MrpackFileErr{{Path: f.Path, Err: &MrpackNoDownloadsErr{f.Path}}}}
+		}
+		files = append(files, f)
+		optional = append(optional, !required)
 	}
-	wg.Wait()
 	return
 }
 
-func (p *Mrpack) InstallClientWithOptional(target string, optionalChecker MrpackOptionalChecker) (err error) {
-	if err = p.installWithEnv("client", target, optionalChecker); err != nil {
+// ServerFiles returns the files that PlanServer downloads, they should be recorded by InstallManifest.SetModpack
+func (p *Mrpack) ServerFiles(optionalChecker MrpackOptionalChecker) (files []MrpackFileMeta, err error) {
+	files, _, err = p.selectFiles("server", optionalChecker)
+	return
+}
+
+// PlanServerUpdate plans the server like PlanServer,
+// and deletes the installed files of the old modpack that the new one doesn't have.
+// The disabled mods are not downloaded again if they are not changed, and are deleted if the new modpack doesn't have them
+func (p *Mrpack) PlanServerUpdate(target string, installed []MrpackFileMeta, disabled []DisabledMod, optionalChecker MrpackOptionalChecker) (plan *InstallPlan, err error) {
+	if plan, err = p.PlanServer(target, optionalChecker); err != nil {
 		return
 	}
-	return p.OverrideClient(target)
+	installedSha1 := make(map[string]string, len(installed))
+	for _, f := range installed {
+		installedSha1[filepath.Join(target, f.Path)] = f.Hashes["sha1"]
+	}
+	disabledFiles := make(map[string]bool, len(disabled))
+	for _, d := range disabled {
+		disabledFiles[filepath.Join(target, filepath.FromSlash(d.File))] = true
+	}
+	keep := make(map[string]bool, len(plan.Steps))
+	steps := plan.Steps[:0]
+	for _, s := range plan.Steps {
+		path := filepath.Clean(s.Path)
+		keep[path] = true
+		if s.Kind == StepDownload && disabledFiles[path] && s.Hashes["sha1"] != "" && s.Hashes["sha1"] == installedSha1[path] {
+			continue
+		}
+		steps = append(steps, s)
+	}
+	plan.Steps = steps
+	for path := range disabledFiles {
+		if keep[path] {
+			continue
+		}
+		moved := filepath.Join(target, DisabledModsDir, filepath.Base(path))
+		if _, e := os.Lstat(moved); e == nil {
+			plan.add(&PlanStep{Kind: StepDelete, Path: moved})
+		}
+	}
+	for _, f := range installed {
+		if !filepath.IsLocal(f.Path) {
+			continue
+		}
+		path := filepath.Join(target, f.Path)
+		if keep[path] {
+			continue
+		}
+		if _, e := os.Lstat(path); e != nil {
+			continue
+		}
+		plan.add(&PlanStep{Kind: StepDelete, Path: path})
+	}
+	return
+}
+
+// PlanServer returns the plan of InstallServerWithOptional
+func (p *Mrpack) PlanServer(target string, optionalChecker MrpackOptionalChecker) (*InstallPlan, error) {
+	return p.planWithEnv("server", target, optionalChecker)
+}
+
+// PlanClient returns the plan of InstallClientWithOptional
+func (p *Mrpack) PlanClient(target string, optionalChecker MrpackOptionalChecker) (*InstallPlan, error) {
+	return p.planWithEnv("client", target, optionalChecker)
+}
+
+func (p *Mrpack) InstallClientWithOptional(target string, optionalChecker MrpackOptionalChecker) (err error) {
+	return p.installWithEnv("client", target, optionalChecker)
 }
 
 func (p *Mrpack) InstallServer(target string) (err error) {
@@ -172,10 +342,7 @@ func (p *Mrpack) InstallServer(target string) (err error) {
 }
 
 func (p *Mrpack) InstallServerWithOptional(target string, optionalChecker MrpackOptionalChecker) (err error) {
-	if err = p.installWithEnv("server", target, optionalChecker); err != nil {
-		return
-	}
-	return p.OverrideServer(target)
+	return p.installWithEnv("server", target, optionalChecker)
 }
 
 func trimLeftDir(path string) string {
@@ -186,65 +353,96 @@ func trimLeftDir(path string) string {
 	return path[i+1:]
 }
 
-func (p *Mrpack) override(target string, f *zip.File) (err error) {
-	name := trimLeftDir(f.Name)
-	if len(name) == 0 {
-		return
-	}
-	path := filepath.Join(target, name)
-	if f.FileInfo().IsDir() {
-		return os.MkdirAll(path, f.Mode()|0111)
-	}
-	var (
-		r  io.ReadCloser
-		fd *os.File
-	)
-	if r, err = f.Open(); err != nil {
-		return
-	}
-	defer r.Close()
-	if err = os.MkdirAll(filepath.Dir(path), 0755); err != nil {
-		return
-	}
-	if fd, err = os.OpenFile(path,
-		os.O_RDWR|os.O_CREATE|os.O_TRUNC, f.Mode()); err != nil {
-		return
-	}
-	if _, err = io.Copy(fd, r); err != nil {
-		return
+func (p *Mrpack) planOverrides(plan *InstallPlan, target string, files []*zip.File) (err error) {
+	for _, f := range files {
+		name := strings.TrimSuffix(trimLeftDir(f.Name), "/")
+		if len(name) == 0 {
+			continue
+		}
+		if !filepath.IsLocal(name) {
+			return &NotLocalPathErr{f.Name}
+		}
+		path := filepath.Join(target, name)
+		if f.FileInfo().IsDir() {
+			plan.add(&PlanStep{Kind: StepMkdir, Path: path, Mode: f.Mode()})
+			continue
+		}
+		plan.add(&PlanStep{
+			Kind: StepExtract,
+			From: f.Name,
+			Path: path,
+			Mode: f.Mode(),
+			open: f.Open,
+		})
 	}
 	return
 }
 
-func (p *Mrpack) overrideGlobal(target string) (err error) {
-	for _, f := range p.overrides {
-		if err = p.override(target, f); err != nil {
+func (p *Mrpack) override(target string, files ...[]*zip.File) (err error) {
+	plan := newInstallPlan("", target)
+	for _, list := range files {
+		if err = p.planOverrides(plan, target, list); err != nil {
 			return
 		}
 	}
+	_, err = plan.Execute()
 	return
 }
 
 func (p *Mrpack) OverrideClient(target string) (err error) {
-	if err = p.overrideGlobal(target); err != nil {
+	return p.override(target, p.overrides, p.clientOverrides)
+}
+
+func (p *Mrpack) OverrideServer(target string) (err error) {
+	return p.override(target, p.overrides, p.serverOverrides)
+}
+
+// ModpackNoDependencyErr is returned when the modpack doesn't depend on minecraft or any loader
+var ModpackNoDependencyErr = errors.New("Modpack didn't contain any dependencies")
+
+// ServerTarget returns the server that the modpack depends on
+func (p *Mrpack) ServerTarget() (serverType string, gameVersion string, loader string, err error) {
+	gameVersion, mok := p.Deps["minecraft"]
+	if forge, ok := p.Deps["forge"]; ok {
+		serverType, loader = "forge", forge
+	} else if fabric, ok := p.Deps["fabric-loader"]; ok {
+		serverType, loader = "fabric", fabric
+	} else if quilt, ok := p.Deps["quilt-loader"]; ok {
+		serverType, loader = "quilt", quilt
+	} else if mok {
+		serverType = "vanilla"
+	} else {
+		err = ModpackNoDependencyErr
+	}
+	return
+}
+
+// PlanLoader plans the server that the modpack depends on
+func (p *Mrpack) PlanLoader(target string, name string) (plan *InstallPlan, err error) {
+	serverType, gameVersion, loader, err := p.ServerTarget()
+	if err != nil {
 		return
 	}
-	for _, f := range p.clientOverrides {
-		if err = p.override(target, f); err != nil {
-			return
-		}
+	switch serverType {
+	case "forge":
+		return DefaultForgeInstaller.PlanWithLoader(target, name, gameVersion, loader)
+	case "fabric":
+		return DefaultFabricInstaller.PlanWithLoader(target, name, gameVersion, loader)
+	case "quilt":
+		return DefaultQuiltInstaller.PlanWithLoader(target, name, gameVersion, loader)
 	}
-	return
+	return VanillaIns.Plan(target, name, gameVersion)
 }
 
-func (p *Mrpack) OverrideServer(target string) (err error) {
-	if err = p.overrideGlobal(target); err != nil {
+// InstallLoader installs the server that the modpack depends on into target
+func (p *Mrpack) InstallLoader(target string, name string) (serverType string, gameVersion string, loader string, installed string, err error) {
+	if serverType, gameVersion, loader, err = p.ServerTarget(); err != nil {
 		return
 	}
-	for _, f := range p.serverOverrides {
-		if err = p.override(target, f); err != nil {
-			return
-		}
+	var plan *InstallPlan
+	if plan, err = p.PlanLoader(target, name); err != nil {
+		return
 	}
+	installed, err = plan.Execute()
 	return
 }
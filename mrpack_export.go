@@ -0,0 +1,218 @@
+package installer
+
+import (
+	"archive/zip"
+	"crypto/sha1"
+	"crypto/sha512"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// DefaultMrpackOverrides are the paths that ExportMrpack copies into overrides/ if MrpackExportOptions.Overrides is nil
+var DefaultMrpackOverrides = []string{"config"}
+
+// mrpackLoaderDeps are the dependency keys of the loaders in modrinth.index.json
+var mrpackLoaderDeps = map[string]string{
+	"forge":    "forge",
+	"neoforge": "neoforge",
+	"fabric":   "fabric-loader",
+	"quilt":    "quilt-loader",
+}
+
+type MrpackExportOptions struct {
+	// Name is the modpack name, default is the name of the server directory
+	Name string
+	// VersionId is the modpack version, default is "1.0.0"
+	VersionId string
+	Summary   string
+	// Overrides are the files or directories copied into overrides/, relative to the server directory.
+	// The files in mods/ that cannot be downloaded from modrinth are always copied
+	Overrides []string
+}
+
+type UnsupportModpackServerErr struct {
+	ServerType string
+}
+
+func (e *UnsupportModpackServerErr) Error() string {
+	if e.ServerType == "" {
+		return "Server type is unknown, only the servers installed by this program can be exported"
+	}
+	return fmt.Sprintf("Server type %q cannot be exported as a modrinth modpack", e.ServerType)
+}
+
+// ExportMrpack writes the server directory as a modrinth modpack.
+// The modpack files and the modrinth mods recorded in the install manifest are exported as downloads if they are not changed,
+// the other files are copied into overrides/
+func ExportMrpack(dir string, w io.Writer, opts MrpackExportOptions) (meta *MrpackMeta, err error) {
+	manifest, err := ReadInstallManifest(dir)
+	if err != nil {
+		return
+	}
+	if manifest.GameVersion == "" {
+		return nil, &UnsupportModpackServerErr{manifest.ServerType}
+	}
+	meta = &MrpackMeta{
+		FormatVersion: currentMrpackVersion,
+		Game:          "minecraft",
+		VersionId:     opts.VersionId,
+		Name:          opts.Name,
+		Summary:       opts.Summary,
+		Deps:          StringMap{"minecraft": manifest.GameVersion},
+	}
+	switch manifest.ServerType {
+	case "vanilla":
+	default:
+		dep, ok := mrpackLoaderDeps[manifest.ServerType]
+		if !ok || manifest.LoaderVersion == "" {
+			return nil, &UnsupportModpackServerErr{manifest.ServerType}
+		}
+		meta.Deps[dep] = manifest.LoaderVersion
+	}
+	if meta.Name == "" {
+		var abs string
+		if abs, err = filepath.Abs(dir); err != nil {
+			return
+		}
+		meta.Name = filepath.Base(abs)
+	}
+	if meta.VersionId == "" {
+		meta.VersionId = "1.0.0"
+	}
+
+	exported := make(map[string]bool)
+	for _, f := range manifest.ModpackFiles {
+		if !filepath.IsLocal(f.Path) || exported[f.Path] || manifest.IsDisabled(f.Path) || !matchHashes(filepath.Join(dir, f.Path), f.Hashes) {
+			continue
+		}
+		exported[f.Path] = true
+		meta.Files = append(meta.Files, f)
+	}
+	for _, e := range manifest.Mods {
+		if e.Source != "modrinth" || e.VersionId == "" || !filepath.IsLocal(e.Path) || exported[e.Path] {
+			continue
+		}
+		local := filepath.Join(dir, filepath.FromSlash(e.Path))
+		if len(e.Hashes) > 0 && !matchHashes(local, e.Hashes) {
+			continue
+		}
+		var f MrpackFileMeta
+		if f, err = mrpackFileOf(local); err != nil {
+			if os.IsNotExist(err) {
+				err = nil
+				continue
+			}
+			return
+		}
+		f.Path = e.Path
+		f.Downloads = []string{modrinthCdnUrl(e.Id, e.VersionId, path.Base(e.Path))}
+		exported[e.Path] = true
+		meta.Files = append(meta.Files, f)
+	}
+
+	zw := zip.NewWriter(w)
+	var index io.Writer
+	if index, err = zw.Create("modrinth.index.json"); err != nil {
+		return
+	}
+	encoder := json.NewEncoder(index)
+	encoder.SetIndent("", "  ")
+	if err = encoder.Encode(meta); err != nil {
+		return
+	}
+	overrides := opts.Overrides
+	if overrides == nil {
+		overrides = DefaultMrpackOverrides
+	}
+	overrides = append([]string{"mods"}, overrides...)
+	for _, o := range overrides {
+		if !filepath.IsLocal(o) {
+			return nil, &NotLocalPathErr{o}
+		}
+		if err = addMrpackOverrides(zw, dir, o, exported); err != nil {
+			return
+		}
+	}
+	if err = zw.Close(); err != nil {
+		return
+	}
+	return
+}
+
+// addMrpackOverrides copies the file or the directory into overrides/, except the exported files
+func addMrpackOverrides(zw *zip.Writer, dir string, name string, exported map[string]bool) error {
+	root := filepath.Join(dir, name)
+	return filepath.WalkDir(root, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			if os.IsNotExist(err) && p == root {
+				return nil
+			}
+			return err
+		}
+		// the backups made by OverwriteBackup are not a part of the server
+		if d.IsDir() || !d.Type().IsRegular() || strings.HasSuffix(d.Name(), ".bak") {
+			return nil
+		}
+		rel, err := filepath.Rel(dir, p)
+		if err != nil {
+			return err
+		}
+		rel = filepath.ToSlash(rel)
+		if exported[rel] {
+			return nil
+		}
+		exported[rel] = true
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		header, err := zip.FileInfoHeader(info)
+		if err != nil {
+			return err
+		}
+		header.Name = "overrides/" + rel
+		header.Method = zip.Deflate
+		fw, err := zw.CreateHeader(header)
+		if err != nil {
+			return err
+		}
+		fd, err := os.Open(p)
+		if err != nil {
+			return err
+		}
+		defer fd.Close()
+		_, err = io.Copy(fw, fd)
+		return err
+	})
+}
+
+// mrpackFileOf returns the size and the hashes that modrinth.index.json requires
+func mrpackFileOf(path string) (f MrpackFileMeta, err error) {
+	fd, err := os.Open(path)
+	if err != nil {
+		return
+	}
+	defer fd.Close()
+	h1, h512 := sha1.New(), sha512.New()
+	if f.Size, err = io.Copy(io.MultiWriter(h1, h512), fd); err != nil {
+		return
+	}
+	f.Hashes = StringMap{
+		"sha1":   hex.EncodeToString(h1.Sum(nil)),
+		"sha512": hex.EncodeToString(h512.Sum(nil)),
+	}
+	return
+}
+
+func modrinthCdnUrl(projectId string, versionId string, filename string) string {
+	return "https://cdn.modrinth.com/data/" + url.PathEscape(projectId) + "/versions/" + url.PathEscape(versionId) + "/" +
+		strings.ReplaceAll(url.PathEscape(filename), "+", "%2B")
+}
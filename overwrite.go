@@ -0,0 +1,140 @@
+package installer
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// OverwritePolicy decides what to do when a file is placed onto an existing file
+type OverwritePolicy string
+
+const (
+	// OverwriteFail refuses to replace the existing file
+	OverwriteFail OverwritePolicy = "fail"
+	// OverwriteSkipIdentical keeps the existing file if it has the same content, otherwise it fails
+	OverwriteSkipIdentical OverwritePolicy = "skip-identical"
+	// OverwriteAlways replaces the existing file
+	OverwriteAlways OverwritePolicy = "overwrite"
+	// OverwriteBackup renames the existing file to "<name>.<time>.bak", then replaces it
+	OverwriteBackup OverwritePolicy = "backup"
+)
+
+var OverwritePolicies = []OverwritePolicy{OverwriteFail, OverwriteSkipIdentical, OverwriteAlways, OverwriteBackup}
+
+// DefaultOverwritePolicy is used by the plans and the downloads that don't set a policy
+var DefaultOverwritePolicy = OverwriteSkipIdentical
+
+type UnknownOverwritePolicyErr struct {
+	Policy string
+}
+
+func (e *UnknownOverwritePolicyErr) Error() string {
+	return fmt.Sprintf("Unknown overwrite policy %q, must be one of %v", e.Policy, OverwritePolicies)
+}
+
+func ParseOverwritePolicy(s string) (OverwritePolicy, error) {
+	for _, p := range OverwritePolicies {
+		if string(p) == s {
+			return p, nil
+		}
+	}
+	return "", &UnknownOverwritePolicyErr{s}
+}
+
+func (p OverwritePolicy) orDefault() OverwritePolicy {
+	if p == "" {
+		return DefaultOverwritePolicy
+	}
+	return p
+}
+
+// sameContent reports whether the two files have the same content
+func sameContent(a, b string) (same bool, err error) {
+	sum := func(path string) (size int64, sum []byte, err error) {
+		fd, err := os.Open(path)
+		if err != nil {
+			return
+		}
+		defer fd.Close()
+		h := sha256.New()
+		if size, err = io.Copy(h, fd); err != nil {
+			return
+		}
+		return size, h.Sum(nil), nil
+	}
+	sa, ha, err := sum(a)
+	if err != nil {
+		return
+	}
+	sb, hb, err := sum(b)
+	if err != nil {
+		return
+	}
+	return sa == sb && bytes.Equal(ha, hb), nil
+}
+
+// backupName returns an unused backup file name of the path
+func backupName(path string) string {
+	base := path + "." + time.Now().Format("20060102-150405")
+	name := base + ".bak"
+	for i := 1; ; i++ {
+		if _, err := os.Lstat(name); os.IsNotExist(err) {
+			return name
+		}
+		name = fmt.Sprintf("%s.%d.bak", base, i)
+	}
+}
+
+// resolveOverwrite handles the existing dst by the policy before src is placed onto it.
+// If keep is true, the existing file should be kept and src should be dropped.
+// The decision is written into the log
+func resolveOverwrite(src, dst string, policy OverwritePolicy) (keep bool, err error) {
+	switch policy.orDefault() {
+	case OverwriteFail:
+		return false, targetExistErr(src, dst)
+	case OverwriteSkipIdentical:
+		var same bool
+		if same, err = sameContent(src, dst); err != nil {
+			return
+		}
+		if !same {
+			return false, targetExistErr(src, dst)
+		}
+		loger.Infof("Kept %q since it's identical (overwrite policy %q)", dst, OverwriteSkipIdentical)
+		return true, nil
+	case OverwriteAlways:
+		if err = os.Remove(dst); err != nil {
+			return
+		}
+		loger.Infof("Overwriting %q (overwrite policy %q)", dst, OverwriteAlways)
+		return false, nil
+	case OverwriteBackup:
+		bak := backupName(dst)
+		if err = os.Rename(dst, bak); err != nil {
+			return
+		}
+		loger.Infof("Backed up %q to %q (overwrite policy %q)", dst, filepath.Base(bak), OverwriteBackup)
+		return false, nil
+	}
+	return false, &UnknownOverwritePolicyErr{string(policy)}
+}
+
+// placeFile moves src to dst, an existing dst is handled by the policy
+func placeFile(src, dst string, mode os.FileMode, policy OverwritePolicy) (err error) {
+	if _, e := os.Lstat(dst); e == nil {
+		var keep bool
+		if keep, err = resolveOverwrite(src, dst, policy); err != nil {
+			return
+		}
+		if keep {
+			os.Remove(src)
+			return
+		}
+	}
+	return renameIfNotExist(src, dst, mode)
+}
@@ -0,0 +1,142 @@
+package installer
+
+import (
+	"net/url"
+	"sort"
+	"strconv"
+	"time"
+)
+
+type (
+	PaperMCDownload struct {
+		Name      string `json:"name"`
+		Checksums struct {
+			Sha256 string `json:"sha256"`
+		} `json:"checksums"`
+		Size int64  `json:"size"`
+		Url  string `json:"url"`
+	}
+	PaperMCBuild struct {
+		Id int `json:"id"`
+		// Channel is one of [ALPHA BETA STABLE RECOMMENDED]
+		Channel   string                     `json:"channel"`
+		Time      time.Time                  `json:"time"`
+		Downloads map[string]PaperMCDownload `json:"downloads"`
+	}
+	PaperMCVersion struct {
+		Version struct {
+			Id string `json:"id"`
+		} `json:"version"`
+		Builds []int `json:"builds"`
+	}
+
+	// PaperMCProject gets the versions and the builds of a project from the PaperMC downloads API (or any compatible API)
+	PaperMCProject struct {
+		ApiUrl  string // Default is "https://fill.papermc.io/v3"
+		Project string
+	}
+)
+
+// paperMCServerDownload is the download key of the server jar
+const paperMCServerDownload = "server:default"
+
+func (p *PaperMCProject) getJson(obj any, paths ...string) (err error) {
+	link, err := url.JoinPath(p.ApiUrl, append([]string{"projects", p.Project}, paths...)...)
+	if err != nil {
+		return
+	}
+	return DefaultHTTPClient.GetJson(link, obj)
+}
+
+func (b PaperMCBuild) stable() bool {
+	return b.Channel == "STABLE" || b.Channel == "RECOMMENDED"
+}
+
+// GetVersions returns the versions of the project, newest first
+func (p *PaperMCProject) GetVersions() (versions []PaperMCVersion, err error) {
+	var res struct {
+		Versions []PaperMCVersion `json:"versions"`
+	}
+	if err = p.getJson(&res, "versions"); err != nil {
+		return
+	}
+	versions = res.Versions
+	sort.SliceStable(versions, func(i, j int) bool {
+		return compareVersionStrings(versions[i].Version.Id, versions[j].Version.Id) > 0
+	})
+	return
+}
+
+// GetBuilds returns the builds of the version, newest first
+func (p *PaperMCProject) GetBuilds(version string) (builds []PaperMCBuild, err error) {
+	if err = p.getJson(&builds, "versions", version, "builds"); err != nil {
+		return
+	}
+	sort.SliceStable(builds, func(i, j int) bool {
+		return builds[i].Id > builds[j].Id
+	})
+	return
+}
+
+// GetBuild returns the build of the version, empty build means the newest stable one, or the newest one if none is stable
+func (p *PaperMCProject) GetBuild(version string, build string) (b PaperMCBuild, err error) {
+	builds, err := p.GetBuilds(version)
+	if err != nil {
+		return
+	}
+	if build == "" {
+		for _, b = range builds {
+			if b.stable() {
+				return
+			}
+		}
+		if len(builds) > 0 {
+			return builds[0], nil
+		}
+	}
+	for _, b = range builds {
+		if strconv.Itoa(b.Id) == build {
+			return
+		}
+	}
+	return PaperMCBuild{}, &VersionNotFoundErr{p.Project + "-" + version + optionalSuffix("-", build)}
+}
+
+// ServerDownload returns the server jar of the build
+func (p *PaperMCProject) ServerDownload(version string, build PaperMCBuild) (dl PaperMCDownload, err error) {
+	dl, ok := build.Downloads[paperMCServerDownload]
+	if !ok {
+		return dl, &AssetNotFoundErr{p.Project + "-" + version + "-" + strconv.Itoa(build.Id), paperMCServerDownload}
+	}
+	return
+}
+
+// GameVersions returns the versions of the project, they are not minecraft versions but are listed as the game versions
+func (p *PaperMCProject) GameVersions() (versions []McVersion, err error) {
+	vs, err := p.GetVersions()
+	if err != nil {
+		return
+	}
+	versions = make([]McVersion, len(vs))
+	for i, v := range vs {
+		versions[i] = McVersion{Id: v.Version.Id, Type: McRelease}
+	}
+	return
+}
+
+// LoaderVersions returns the builds of the version, they are listed as the loader versions
+func (p *PaperMCProject) LoaderVersions(version string) (versions []LoaderVersion, err error) {
+	builds, err := p.GetBuilds(version)
+	if err != nil {
+		return
+	}
+	versions = make([]LoaderVersion, len(builds))
+	for i, b := range builds {
+		versions[i] = LoaderVersion{
+			Version:     strconv.Itoa(b.Id),
+			Stable:      b.stable(),
+			Recommended: b.Channel == "RECOMMENDED",
+		}
+	}
+	return
+}
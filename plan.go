@@ -0,0 +1,902 @@
+package installer
+
+import (
+	"archive/zip"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/http"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Planner is implemented by the installers that can tell what they will do before installing
+type Planner interface {
+	// Plan resolves the target and returns the steps that Install would do, without touching the install directory
+	Plan(path, name string, target string) (*InstallPlan, error)
+}
+
+// ResolvedPlanner is implemented by the planners that can plan a resolved version without resolving it again
+type ResolvedPlanner interface {
+	PlanResolved(path, name string, version ResolvedVersion) (*InstallPlan, error)
+}
+
+const (
+	StepDownload = "download"
+	StepRun      = "run"
+	StepMove     = "move"
+	StepWrite    = "write"
+	StepExtract  = "extract"
+	StepMkdir    = "mkdir"
+	StepDelete   = "delete"
+	StepRestore  = "restore"
+	// StepUnpack extracts a zip archive, which is usually downloaded by a previous step, into a directory
+	StepUnpack = "unpack"
+	// StepInstall runs an installer that cannot be planned
+	StepInstall = "install"
+)
+
+// CommandOutput receives the output of the commands run by the plans, such as the external installers
+var CommandOutput io.Writer = os.Stdout
+
+// PlanTempDir is the prefix of the paths in the temporary directory of a plan,
+// the directory is created when the plan executes and removed after that
+const PlanTempDir = "${tmp}"
+
+// InstallPlan is the list of steps that an install will do.
+// It's made before anything is written, so it can be reviewed, and the install executes it step by step
+type InstallPlan struct {
+	Server    string      `json:"server"`
+	Game      string      `json:"game,omitempty"`
+	Loader    string      `json:"loader,omitempty"`
+	Modpack   string      `json:"modpack,omitempty"`
+	Path      string      `json:"path"`
+	Installed string      `json:"installed,omitempty"`
+	Steps     []*PlanStep `json:"steps"`
+	// Overwrite is the policy of the existing files that the steps place onto, empty means DefaultOverwritePolicy.
+	// The files made by the external installers always replace the existing ones
+	Overwrite OverwritePolicy `json:"overwrite"`
+	// Then are the plans executed after this plan is committed, such as the configs of the backend servers of a proxy.
+	// Each of them is staged in its own directory
+	Then []*InstallPlan `json:"then,omitempty"`
+
+	// Parallelism is the maximum number of consecutive download steps that run at the same time, zero means one by one.
+	// If it's set, the failed required downloads are reported together by MrpackInstallErr
+	Parallelism int `json:"-"`
+	// MaxConnsPerHost is the maximum number of connections to a single host when the downloads run in parallel
+	MaxConnsPerHost int `json:"-"`
+	// ReplaceExecutables makes the Executable steps replace the existing files regardless of Overwrite,
+	// it's set when an installed server is upgraded
+	ReplaceExecutables bool `json:"-"`
+
+	tmpDir string
+}
+
+type PlanStep struct {
+	Kind string `json:"kind"`
+	// Urls are the download links, tried in order
+	Urls []string `json:"urls,omitempty"`
+	// From is the source path of move, the archive entry of extract, the archive of unpack, or the library set key of restore
+	From string `json:"from,omitempty"`
+	// Path is the file that the step writes, or the directory that run and restore work in
+	Path string `json:"path,omitempty"`
+	// Size is the expected size of the download, zero means unknown
+	Size   int64     `json:"size,omitempty"`
+	Hashes StringMap `json:"hashes,omitempty"`
+	// Command is run in Path, "java" is replaced with the found java executable
+	Command []string `json:"command,omitempty"`
+	Content string   `json:"content,omitempty"`
+	// Exists reports whether Path already existed when the plan was made
+	Exists bool `json:"exists,omitempty"`
+	// Optional steps only log a warning when they failed
+	Optional bool `json:"optional,omitempty"`
+	// LibrarySet is the store key that the files produced by the command are saved to while prefetching.
+	// LibraryFiles limits the saved files, empty means all the new or changed files
+	LibrarySet   string   `json:"librarySet,omitempty"`
+	LibraryFiles []string `json:"libraryFiles,omitempty"`
+	// Log is the log file written by the command, it's moved into the install directory when the command failed
+	Log string `json:"log,omitempty"`
+	// Keep are the slash separated paths in the archive that unpack skips if they exist, such as the configs and the worlds
+	Keep []string `json:"keep,omitempty"`
+	// Executable marks the step that places the server executable or its launch files
+	Executable bool `json:"executable,omitempty"`
+
+	Mode fs.FileMode `json:"-"`
+
+	open    func() (io.ReadCloser, error)
+	install func(dir string) (string, error)
+}
+
+func newInstallPlan(server string, path string) *InstallPlan {
+	return &InstallPlan{
+		Server:    server,
+		Path:      path,
+		Overwrite: DefaultOverwritePolicy,
+	}
+}
+
+// PlanInstall returns the plan of the installer for the version resolved by ResolveInstallTarget.
+// If the installer doesn't implement Planner, the plan contains a single install step
+func PlanInstall(ir Installer, server string, path, name string, version ResolvedVersion) (plan *InstallPlan, err error) {
+	if p, ok := ir.(ResolvedPlanner); ok {
+		return p.PlanResolved(path, name, version)
+	}
+	target := version.String()
+	if p, ok := ir.(Planner); ok {
+		return p.Plan(path, name, target)
+	}
+	plan = newInstallPlan(server, path)
+	plan.Game = target
+	plan.add(&PlanStep{
+		Kind: StepInstall,
+		Path: path,
+		install: func(dir string) (string, error) {
+			return ir.Install(dir, name, target)
+		},
+	})
+	return
+}
+
+func (p *InstallPlan) add(step *PlanStep) *PlanStep {
+	switch step.Kind {
+	case StepDownload, StepMove, StepWrite, StepExtract:
+		if !strings.HasPrefix(step.Path, PlanTempDir) {
+			_, err := os.Stat(step.Path)
+			step.Exists = err == nil
+		}
+	}
+	p.Steps = append(p.Steps, step)
+	return step
+}
+
+func (p *InstallPlan) download(link string, path string, size int64, hashes StringMap) *PlanStep {
+	return p.add(&PlanStep{
+		Kind:   StepDownload,
+		Urls:   []string{link},
+		Path:   path,
+		Size:   size,
+		Hashes: hashes,
+		Mode:   0644,
+	})
+}
+
+func (p *InstallPlan) run(dir string, command ...string) *PlanStep {
+	return p.add(&PlanStep{
+		Kind:    StepRun,
+		Path:    dir,
+		Command: command,
+	})
+}
+
+func (p *InstallPlan) move(from, to string, mode fs.FileMode) *PlanStep {
+	return p.add(&PlanStep{
+		Kind: StepMove,
+		From: from,
+		Path: to,
+		Mode: mode,
+	})
+}
+
+func (p *InstallPlan) write(path string, content string, mode fs.FileMode) *PlanStep {
+	return p.add(&PlanStep{
+		Kind:    StepWrite,
+		Path:    path,
+		Content: content,
+		Mode:    mode,
+	})
+}
+
+// runOrRestore adds the steps of an external installer which writes files into dir.
+// The last run step saves the files it produced as the library set of the key while prefetching,
+// and in offline mode all the steps are replaced by restoring the library set from the store
+func (p *InstallPlan) runOrRestore(key string, dir string, files []string, add func() error) (err error) {
+	if store := DefaultHTTPClient.Store; store != nil && store.Offline {
+		p.add(&PlanStep{
+			Kind: StepRestore,
+			From: key,
+			Path: dir,
+		})
+		return
+	}
+	n := len(p.Steps)
+	if err = add(); err != nil {
+		return
+	}
+	for i := len(p.Steps) - 1; i >= n; i-- {
+		if step := p.Steps[i]; step.Kind == StepRun {
+			step.LibrarySet = key
+			step.LibraryFiles = files
+			break
+		}
+	}
+	return
+}
+
+// Merge appends the steps of the other plan, the server of the other plan is used if it's set
+func (p *InstallPlan) Merge(other *InstallPlan) {
+	if other.Server != "" {
+		p.Server = other.Server
+		p.Game = other.Game
+		p.Loader = other.Loader
+	}
+	if other.Installed != "" {
+		p.Installed = other.Installed
+	}
+	p.Steps = append(p.Steps, other.Steps...)
+}
+
+// Downloads returns the download steps, and the total size of them with known size
+func (p *InstallPlan) Downloads() (steps []*PlanStep, size int64) {
+	for _, s := range p.Steps {
+		if s.Kind == StepDownload {
+			steps = append(steps, s)
+			if s.Size > 0 {
+				size += s.Size
+			}
+		}
+	}
+	return
+}
+
+// Files returns the files that the steps place into the install directory, relative to it and slash separated
+func (p *InstallPlan) Files() (files []string) {
+	for _, s := range p.Steps {
+		switch s.Kind {
+		case StepDownload, StepMove, StepWrite, StepExtract:
+		default:
+			continue
+		}
+		if rel, err := filepath.Rel(p.Path, s.Path); err == nil && filepath.IsLocal(rel) {
+			files = append(files, filepath.ToSlash(rel))
+		}
+	}
+	return
+}
+
+// LaunchCommand returns the command that starts the installed server
+func (p *InstallPlan) LaunchCommand() []string {
+	return LaunchCommand(p.Installed)
+}
+
+// LaunchCommand returns the command that starts the server executable,
+// jar files are run by java, and the scripts (such as the ones made by forge) are run directly
+func LaunchCommand(executable string) []string {
+	if executable == "" {
+		return nil
+	}
+	if strings.HasSuffix(executable, ".jar") {
+		return []string{"java", "-jar", executable, "nogui"}
+	}
+	return []string{executable, "nogui"}
+}
+
+// ProbeSizes sends HEAD requests to fill the sizes of the downloads which are unknown, errors are ignored.
+// If the HEAD response doesn't have the length (e.g. the files generated by fabric meta), a one byte range request is tried
+func (p *InstallPlan) ProbeSizes() {
+	for _, s := range p.Steps {
+		if s.Kind != StepDownload || s.Size > 0 || len(s.Urls) == 0 {
+			continue
+		}
+		size, err := probeSize(s.Urls[0])
+		if err != nil {
+			loger.Debugf("Couldn't probe the size of %q: %v", s.Urls[0], err)
+			continue
+		}
+		s.Size = size
+	}
+}
+
+// probeSize returns the size of the url, zero means unknown
+func probeSize(link string) (size int64, err error) {
+	var res *http.Response
+	if res, err = DefaultHTTPClient.Head(link); err != nil {
+		return
+	}
+	res.Body.Close()
+	if res.StatusCode == http.StatusOK && res.ContentLength > 0 {
+		return res.ContentLength, nil
+	}
+	var req *http.Request
+	if req, err = DefaultHTTPClient.NewRequest("GET", link, nil); err != nil {
+		return
+	}
+	req.Header.Set("Range", "bytes=0-0")
+	if res, err = DefaultHTTPClient.Do(req); err != nil {
+		return
+	}
+	// the body is not read, only the headers are needed
+	res.Body.Close()
+	switch res.StatusCode {
+	case http.StatusPartialContent:
+		cr := res.Header.Get("Content-Range")
+		if i := strings.LastIndexByte(cr, '/'); i >= 0 {
+			size, _ = strconv.ParseInt(cr[i+1:], 10, 64)
+		}
+	case http.StatusOK:
+		size = res.ContentLength
+	}
+	if size < 0 {
+		size = 0
+	}
+	return
+}
+
+func (s *PlanStep) String() string {
+	var b strings.Builder
+	switch s.Kind {
+	case StepDownload:
+		fmt.Fprintf(&b, "download %s -> %s", strings.Join(s.Urls, " | "), s.Path)
+		var info []string
+		if s.Size > 0 {
+			info = append(info, formatSize(s.Size, "%.2f"))
+		}
+		hashes := make([]string, 0, len(s.Hashes))
+		for h, sum := range s.Hashes {
+			hashes = append(hashes, h+":"+sum)
+		}
+		sort.Strings(hashes)
+		info = append(info, hashes...)
+		if len(info) > 0 {
+			fmt.Fprintf(&b, " (%s)", strings.Join(info, ", "))
+		}
+	case StepRun:
+		fmt.Fprintf(&b, "run %q in %s", strings.Join(s.Command, " "), s.Path)
+		if s.LibrarySet != "" {
+			if store := DefaultHTTPClient.Store; store != nil && store.Record {
+				fmt.Fprintf(&b, ", saved as library set %q", s.LibrarySet)
+			}
+		}
+	case StepMove:
+		fmt.Fprintf(&b, "move %s -> %s", s.From, s.Path)
+	case StepWrite:
+		fmt.Fprintf(&b, "write %s (%d bytes)", s.Path, len(s.Content))
+	case StepExtract:
+		fmt.Fprintf(&b, "extract %s -> %s", s.From, s.Path)
+	case StepMkdir:
+		fmt.Fprintf(&b, "mkdir %s", s.Path)
+	case StepDelete:
+		fmt.Fprintf(&b, "delete %s", s.Path)
+	case StepRestore:
+		fmt.Fprintf(&b, "restore library set %q into %s", s.From, s.Path)
+	case StepUnpack:
+		fmt.Fprintf(&b, "unpack %s -> %s", s.From, s.Path)
+		if len(s.Keep) > 0 {
+			fmt.Fprintf(&b, " (keep %s)", strings.Join(s.Keep, ", "))
+		}
+	case StepInstall:
+		fmt.Fprintf(&b, "install into %s (cannot be planned)", s.Path)
+	default:
+		b.WriteString(s.Kind)
+	}
+	if s.Exists {
+		b.WriteString(" [exists]")
+	}
+	if s.Optional {
+		b.WriteString(" [optional]")
+	}
+	return b.String()
+}
+
+// WriteText writes the human readable plan
+func (p *InstallPlan) WriteText(w io.Writer) (err error) {
+	var b strings.Builder
+	b.WriteString("Install")
+	if p.Modpack != "" {
+		fmt.Fprintf(&b, " modpack %s", p.Modpack)
+		if p.Server != "" {
+			b.WriteString(" with")
+		}
+	}
+	if p.Server != "" {
+		b.WriteString(" " + p.Server)
+		if p.Game != "" {
+			b.WriteString(" " + p.Game)
+		}
+		if p.Loader != "" {
+			fmt.Fprintf(&b, " (loader %s)", p.Loader)
+		}
+	}
+	fmt.Fprintf(&b, " into %q, %d step(s):\n", p.Path, len(p.Steps))
+	exists := false
+	for _, s := range p.Steps {
+		fmt.Fprintf(&b, "  %s\n", s)
+		exists = exists || s.Exists
+	}
+	for _, t := range p.Then {
+		fmt.Fprintf(&b, "Then change %q, %d step(s):\n", t.Path, len(t.Steps))
+		for _, s := range t.Steps {
+			fmt.Fprintf(&b, "  %s\n", s)
+			exists = exists || s.Exists
+		}
+	}
+	if exists {
+		fmt.Fprintf(&b, "Existing files are handled by overwrite policy %q\n", p.Overwrite.orDefault())
+	}
+	if downloads, size := p.Downloads(); len(downloads) > 0 {
+		unknown := 0
+		for _, s := range downloads {
+			if s.Size <= 0 {
+				unknown++
+			}
+		}
+		fmt.Fprintf(&b, "Download %d file(s)", len(downloads))
+		if unknown < len(downloads) {
+			fmt.Fprintf(&b, ", %s", formatSize(size, "%.2f"))
+		}
+		if unknown > 0 {
+			fmt.Fprintf(&b, ", %d of unknown size", unknown)
+		}
+		b.WriteString("\n")
+	}
+	if p.Installed != "" {
+		fmt.Fprintf(&b, "Server executable: %s\n", p.Installed)
+	}
+	_, err = io.WriteString(w, b.String())
+	return
+}
+
+// expand replaces PlanTempDir with the temporary directory of the plan
+func (p *InstallPlan) expand(path string) (string, error) {
+	rest, ok := strings.CutPrefix(path, PlanTempDir)
+	if !ok {
+		return path, nil
+	}
+	if p.tmpDir == "" {
+		dir, err := os.MkdirTemp("", "server-installer-*")
+		if err != nil {
+			return "", err
+		}
+		p.tmpDir = dir
+	}
+	return p.tmpDir + rest, nil
+}
+
+func (p *InstallPlan) cleanup() {
+	if p.tmpDir != "" {
+		os.RemoveAll(p.tmpDir)
+		p.tmpDir = ""
+	}
+}
+
+// Execute runs the steps of the plan and returns the installed executable
+func (p *InstallPlan) Execute() (installed string, err error) {
+	return p.ExecuteContext(context.Background())
+}
+
+// ExecuteContext runs the steps in a staging directory next to the install directory,
+// and moves the files into the install directory after all the steps succeeded.
+// If any step failed or ctx is canceled, the staging directory and the temporary files are removed,
+// and the install directory is not changed
+func (p *InstallPlan) ExecuteContext(ctx context.Context) (installed string, err error) {
+	defer p.cleanup()
+	// the plans after this one are checked first, so the install doesn't stop halfway due to their existing files
+	for _, t := range p.Then {
+		if err = t.checkWrites(); err != nil {
+			return
+		}
+	}
+	st, err := newStage(p.Path, p.Overwrite)
+	if err != nil {
+		return
+	}
+	defer st.close()
+	loger.Infof("Installing into %q with overwrite policy %q", p.Path, st.policy)
+
+	steps := p.Steps
+	for i := 0; i < len(steps); {
+		if p.Parallelism > 0 && steps[i].Kind == StepDownload {
+			j := i + 1
+			for j < len(steps) && steps[j].Kind == StepDownload {
+				j++
+			}
+			if err = p.downloadAll(ctx, st, steps[i:j]); err != nil {
+				return
+			}
+			i = j
+			continue
+		}
+		if err = p.executeStep(ctx, st, steps[i], nil); err != nil {
+			if !steps[i].Optional {
+				return
+			}
+			loger.Warnf("Skipped optional step %s: %v", steps[i], err)
+			err = nil
+		}
+		i++
+	}
+	if err = ctx.Err(); err != nil {
+		return
+	}
+	if err = st.commit(); err != nil {
+		return
+	}
+	for _, t := range p.Then {
+		if _, err = t.ExecuteContext(ctx); err != nil {
+			return "", fmt.Errorf("%q is installed, but %q is not changed: %w", p.Path, t.Path, err)
+		}
+		for _, f := range t.Files() {
+			loger.Infof("Changed %q", filepath.Join(t.Path, filepath.FromSlash(f)))
+		}
+	}
+	return p.Installed, nil
+}
+
+// replaces reports whether the step replaces the existing file regardless of the overwrite policy
+func (p *InstallPlan) replaces(s *PlanStep) bool {
+	return s.Executable && p.ReplaceExecutables
+}
+
+// checkWrites checks the existing files of the write steps against the overwrite policy
+func (p *InstallPlan) checkWrites() error {
+	policy := p.Overwrite.orDefault()
+	for _, s := range p.Steps {
+		if s.Kind != StepWrite || !s.Exists || p.replaces(s) {
+			continue
+		}
+		switch policy {
+		case OverwriteFail:
+			return targetExistErr(s.Path, s.Path)
+		case OverwriteSkipIdentical:
+			if data, err := os.ReadFile(s.Path); err != nil || string(data) != s.Content {
+				return targetExistErr(s.Path, s.Path)
+			}
+		}
+	}
+	return nil
+}
+
+// downloadAll downloads the files in parallel, and reports all the failed required files
+func (p *InstallPlan) downloadAll(ctx context.Context, st *stage, steps []*PlanStep) (err error) {
+	maxConns := p.MaxConnsPerHost
+	if maxConns == 0 {
+		maxConns = DefaultMrpackMaxConnsPerHost
+	}
+	hosts := newHostLimiter(maxConns)
+
+	ctx, cancel := context.WithCancel(withHostLimiter(ctx, hosts))
+	defer cancel()
+
+	var (
+		wg   sync.WaitGroup
+		mux  sync.Mutex
+		errs []*MrpackFileErr
+	)
+	stepCh := make(chan *PlanStep)
+	for i := 0; i < p.Parallelism; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for s := range stepCh {
+				err := p.executeStep(ctx, st, s, hosts)
+				if err == nil {
+					continue
+				}
+				if ctx.Err() != nil && errors.Is(err, context.Canceled) {
+					continue
+				}
+				rel := p.relPath(s.Path)
+				if s.Optional {
+					loger.Warnf("Skipped optional step %s: %v", s, err)
+					continue
+				}
+				loger.Errorf("Couldn't install required file %q: %v", rel, err)
+				mux.Lock()
+				errs = append(errs, &MrpackFileErr{Path: rel, Err: err})
+				mux.Unlock()
+				cancel()
+			}
+		}()
+	}
+feed:
+	for _, s := range steps {
+		select {
+		case stepCh <- s:
+		case <-ctx.Done():
+			break feed
+		}
+	}
+	close(stepCh)
+	wg.Wait()
+
+	if len(errs) > 0 {
+		sort.Slice(errs, func(i, j int) bool { return errs[i].Path < errs[j].Path })
+		return &MrpackInstallErr{Errs: errs}
+	}
+	return
+}
+
+func (p *InstallPlan) relPath(path string) string {
+	if rel, err := filepath.Rel(p.Path, path); err == nil && filepath.IsLocal(rel) {
+		return filepath.ToSlash(rel)
+	}
+	return path
+}
+
+// resolve returns the staged path and the real path of the step path
+func (p *InstallPlan) resolve(st *stage, path string) (staged string, real string, err error) {
+	if path, err = p.expand(path); err != nil {
+		return
+	}
+	return st.path(path)
+}
+
+// priority returns the download priority of the step, the server executable goes first and the optional files go last
+func (p *InstallPlan) priority(s *PlanStep) int {
+	switch {
+	case s.Optional:
+		return PriorityLow
+	case p.Installed != "" && s.Path == p.Installed:
+		return PriorityHigh
+	}
+	return PriorityNormal
+}
+
+func (p *InstallPlan) executeStep(ctx context.Context, st *stage, s *PlanStep, hosts *hostLimiter) (err error) {
+	var path, real string
+	if s.Path != "" {
+		if path, real, err = p.resolve(st, s.Path); err != nil {
+			return
+		}
+	}
+	switch s.Kind {
+	case StepDownload:
+		ctx = WithDownloadPriority(ctx, p.priority(s))
+		size := s.Size
+		if size <= 0 {
+			size = -1
+		}
+		if len(s.Hashes) > 0 && path != real && matchHashes(real, s.Hashes) {
+			// the file in the install directory is already the same
+			return
+		}
+		if len(s.Urls) == 0 {
+			return EmptyLinkArrayErr
+		}
+		if err = st.place(s.Urls[0], path, real, p.replaces(s)); err != nil {
+			return
+		}
+		if err = os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			return
+		}
+		if len(s.Hashes) > 0 {
+			return downloadAnyAndCheckHashesContext(ctx, s.Urls, path, s.Hashes, size, hosts, DefaultOverwritePolicy)
+		}
+		for _, l := range s.Urls {
+			var release func()
+			if release, err = hosts.acquire(ctx, l); err != nil {
+				return
+			}
+			var tmp string
+			tmp, err = DefaultHTTPClient.DownloadTmpContext(ctx, l, path+".*.downloading", s.Mode, nil, size, downloadingCallback(l))
+			release()
+			if err != nil {
+				if ctx.Err() != nil {
+					return
+				}
+				continue
+			}
+			if err = renameIfNotExist(tmp, path, s.Mode); err != nil {
+				os.Remove(tmp)
+			}
+			return
+		}
+		return
+	case StepRun:
+		return p.runCommand(ctx, st, s, path)
+	case StepMove:
+		var from, fromReal string
+		if from, fromReal, err = p.resolve(st, s.From); err != nil {
+			return
+		}
+		if err = st.place(fromReal, path, real, p.replaces(s)); err != nil {
+			return
+		}
+		if _, e := os.Lstat(from); os.IsNotExist(e) && from != fromReal {
+			// the source is an existing file in the install directory
+			if err = os.MkdirAll(filepath.Dir(from), 0755); err != nil {
+				return
+			}
+			if err = osCopy(fromReal, from, s.Mode); err != nil {
+				return
+			}
+			st.remove(fromReal)
+		}
+		return renameIfNotExist(from, path, s.Mode)
+	case StepWrite:
+		if err = st.place(s.Path, path, real, p.replaces(s)); err != nil {
+			return
+		}
+		if err = os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			return
+		}
+		return os.WriteFile(path, ([]byte)(s.Content), s.Mode)
+	case StepExtract:
+		if err = st.place(s.From, path, real, p.replaces(s)); err != nil {
+			return
+		}
+		var r io.ReadCloser
+		if r, err = s.open(); err != nil {
+			return
+		}
+		defer r.Close()
+		if err = os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			return
+		}
+		var fd *os.File
+		if fd, err = os.OpenFile(path, os.O_RDWR|os.O_CREATE|os.O_TRUNC, s.Mode); err != nil {
+			return
+		}
+		_, err = io.Copy(fd, r)
+		if er := fd.Close(); err == nil {
+			err = er
+		}
+		return
+	case StepMkdir:
+		return os.MkdirAll(path, s.Mode|0111)
+	case StepDelete:
+		if err = os.RemoveAll(path); err != nil {
+			return
+		}
+		if path != real {
+			st.remove(real)
+		}
+		return
+	case StepRestore:
+		store := DefaultHTTPClient.Store
+		if store == nil {
+			return &StoreMissingErr{Path: s.From}
+		}
+		loger.Infof("Restoring library set %q from the store...", s.From)
+		return store.RestoreLibrarySet(s.From, path)
+	case StepUnpack:
+		var from string
+		if from, _, err = p.resolve(st, s.From); err != nil {
+			return
+		}
+		return unpackZip(from, path, real, s.Keep)
+	case StepInstall:
+		if err = os.MkdirAll(path, 0755); err != nil {
+			return
+		}
+		var installed string
+		if installed, err = s.install(path); err != nil {
+			return
+		}
+		if installed, err = filepath.Abs(installed); err != nil {
+			return
+		}
+		p.Installed = st.unstage(installed)
+		return
+	}
+	return fmt.Errorf("Unknown plan step %q", s.Kind)
+}
+
+func (p *InstallPlan) runCommand(ctx context.Context, st *stage, s *PlanStep, dir string) (err error) {
+	if len(s.Command) == 0 {
+		return fmt.Errorf("Empty command")
+	}
+	args := make([]string, len(s.Command))
+	for i, a := range s.Command {
+		if args[i], err = p.expand(a); err != nil {
+			return
+		}
+		args[i] = st.arg(args[i])
+	}
+	if args[0] == "java" {
+		if args[0], err = lookJavaPath(); err != nil {
+			return
+		}
+	}
+	if err = os.MkdirAll(dir, 0755); err != nil {
+		return
+	}
+	store := DefaultHTTPClient.Store
+	record := s.LibrarySet != "" && store != nil && store.Record
+	var before map[string]fileStamp
+	if record {
+		before = snapshotDir(dir)
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	cmd := exec.CommandContext(ctx, args[0], args[1:]...)
+	cmd.Dir = dir
+	cmd.Stdout = CommandOutput
+	cmd.Stderr = CommandOutput
+	loger.Infof("Running %q...", cmd.String())
+	if err = cmd.Run(); err != nil {
+		if s.Log != "" {
+			if log, _, e := p.resolve(st, s.Log); e == nil {
+				st.keepLog(log)
+			}
+		}
+		return
+	}
+	if record {
+		var include func(rel string) bool
+		if len(s.LibraryFiles) > 0 {
+			include = func(rel string) bool {
+				for _, f := range s.LibraryFiles {
+					if f == rel {
+						return true
+					}
+				}
+				return false
+			}
+		}
+		loger.Infof("Saving library set %q into the store...", s.LibrarySet)
+		if err = store.SaveLibrarySet(s.LibrarySet, dir, before, include); err != nil {
+			return
+		}
+	}
+	return
+}
+
+// unpackZip extracts the archive into dir, the kept paths are skipped if they exist in the install directory real.
+// Like the files made by the external installers, the unpacked files always replace the existing ones
+func unpackZip(archive string, dir string, real string, keep []string) (err error) {
+	r, err := zip.OpenReader(archive)
+	if err != nil {
+		return
+	}
+	defer r.Close()
+	kept := func(name string) bool {
+		for _, k := range keep {
+			if name == k || strings.HasPrefix(name, k+"/") {
+				_, err := os.Lstat(filepath.Join(real, filepath.FromSlash(k)))
+				return err == nil
+			}
+		}
+		return false
+	}
+	for _, f := range r.File {
+		name := strings.TrimSuffix(f.Name, "/")
+		if len(name) == 0 {
+			continue
+		}
+		if !filepath.IsLocal(name) {
+			return &NotLocalPathErr{f.Name}
+		}
+		if kept(name) {
+			continue
+		}
+		path := filepath.Join(dir, filepath.FromSlash(name))
+		if f.FileInfo().IsDir() {
+			if err = os.MkdirAll(path, f.Mode().Perm()|0111); err != nil {
+				return
+			}
+			continue
+		}
+		if err = os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			return
+		}
+		if err = extractZipFile(f, path); err != nil {
+			return
+		}
+	}
+	return
+}
+
+func extractZipFile(f *zip.File, path string) (err error) {
+	r, err := f.Open()
+	if err != nil {
+		return
+	}
+	defer r.Close()
+	fd, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE|os.O_TRUNC, f.Mode().Perm())
+	if err != nil {
+		return
+	}
+	_, err = io.Copy(fd, r)
+	if er := fd.Close(); err == nil {
+		err = er
+	}
+	return
+}
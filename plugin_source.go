@@ -0,0 +1,195 @@
+package installer
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+type (
+	PluginInfo struct {
+		Source      string `json:"source"`
+		Id          string `json:"id"`
+		Name        string `json:"name"`
+		Author      string `json:"author,omitempty"`
+		Description string `json:"description,omitempty"`
+	}
+	PluginRelease struct {
+		Source   string
+		PluginId string
+		Version  string
+		FileName string
+		Url      string
+		Hashes   StringMap
+		Size     int64 // -1 means unknown
+		// Dependencies are the ids of the required plugins in the same source
+		Dependencies []string
+	}
+
+	// PluginSource is a repository that plugins can be searched and downloaded from
+	PluginSource interface {
+		Name() string
+		// Search returns plugins matching the query, empty platform or gameVersion means no filter
+		Search(query string, platform string, gameVersion string) ([]PluginInfo, error)
+		// Resolve returns the release of the plugin for the server, version == "" means latest
+		Resolve(id string, version string, platform string, gameVersion string) (*PluginRelease, error)
+	}
+)
+
+var PluginSources = make(map[string]PluginSource, 4)
+
+func GetPluginSource(name string) (source PluginSource, ok bool) {
+	source, ok = PluginSources[name]
+	return
+}
+
+func GetPluginSourceNames() (names []string) {
+	names = make([]string, 0, len(PluginSources))
+	for name, _ := range PluginSources {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return
+}
+
+// PluginPlatformFor returns the plugin platform that the server type can run,
+// UnsupportGameErr is returned for the servers that cannot run plugins, such as fabric and forge
+func PluginPlatformFor(serverType string) (string, error) {
+	switch serverType {
+	case "velocity":
+		return "velocity", nil
+	case "bungeecord", "waterfall":
+		return "waterfall", nil
+	case "paper", "spigot", "bukkit":
+		return "paper", nil
+	}
+	return "", &UnsupportGameErr{serverType}
+}
+
+// InstallPlugin installs the plugin and its required dependencies from the source into path/plugins,
+// and records them into the manifest
+func InstallPlugin(source PluginSource, manifest *InstallManifest, path string, id string, version string, serverType string, gameVersion string) (installed []ManifestEntry, err error) {
+	platform, err := PluginPlatformFor(serverType)
+	if err != nil {
+		return
+	}
+	visited := make(map[string]bool)
+	queue := []string{id}
+	for len(queue) > 0 {
+		pid := queue[0]
+		queue = queue[1:]
+		if visited[strings.ToLower(pid)] {
+			continue
+		}
+		visited[strings.ToLower(pid)] = true
+
+		ver := ""
+		if pid == id {
+			ver = version
+		} else if dep, ok := manifest.GetPlugin(pid); ok {
+			dep.RequiredBy = []string{id}
+			manifest.SetPlugin(dep)
+			continue
+		}
+		var release *PluginRelease
+		if release, err = source.Resolve(pid, ver, platform, gameVersion); err != nil {
+			return
+		}
+		old, _ := manifest.GetPlugin(release.PluginId)
+		var entry ManifestEntry
+		if entry, err = installPluginRelease(release, path, old.Path); err != nil {
+			return
+		}
+		entry.Pinned = ver != ""
+		if pid == id {
+			entry.Ref = id
+		} else {
+			entry.RequiredBy = []string{id}
+		}
+		if old.Path != "" && old.Path != entry.Path {
+			os.Remove(filepath.Join(path, filepath.FromSlash(old.Path)))
+		}
+		manifest.SetPlugin(entry)
+		installed = append(installed, entry)
+		queue = append(queue, release.Dependencies...)
+	}
+	return
+}
+
+// installPluginRelease downloads the release into path/plugins.
+// owned is the file of the installed version recorded in the manifest, it's replaced if the new file has the same name
+func installPluginRelease(release *PluginRelease, path string, owned string) (entry ManifestEntry, err error) {
+	if strings.ContainsAny(release.FileName, `/\`) {
+		err = &NotLocalPathErr{release.FileName}
+		return
+	}
+	rel := filepath.Join("plugins", release.FileName)
+	policy := DefaultOverwritePolicy
+	if owned == filepath.ToSlash(rel) {
+		policy = OverwriteAlways
+	}
+	loger.Infof("Installing plugin %s(%s) from %s", release.PluginId, release.Version, release.Source)
+	if err = downloadAnyAndCheckHashesContext(context.Background(), []string{release.Url}, filepath.Join(path, rel),
+		release.Hashes, release.Size, nil, policy); err != nil {
+		return
+	}
+	entry = ManifestEntry{
+		Source:  release.Source,
+		Id:      release.PluginId,
+		Version: release.Version,
+		Path:    filepath.ToSlash(rel),
+		Hashes:  release.Hashes,
+	}
+	return
+}
+
+// UpdatePlugin updates the plugin recorded in the manifest to the latest release, pinned plugins are skipped
+func UpdatePlugin(manifest *InstallManifest, path string, id string, serverType string, gameVersion string) (updated bool, err error) {
+	old, ok := manifest.GetPlugin(id)
+	if !ok {
+		return false, &PluginNotInstalledErr{id}
+	}
+	if old.Pinned {
+		loger.Infof("Plugin %s is pinned at %s, skipped", id, old.Version)
+		return
+	}
+	source, ok := GetPluginSource(old.Source)
+	if !ok {
+		return false, &UnknownPluginSourceErr{old.Source}
+	}
+	platform, err := PluginPlatformFor(serverType)
+	if err != nil {
+		return
+	}
+	var release *PluginRelease
+	if release, err = source.Resolve(old.Id, "", platform, gameVersion); err != nil {
+		return
+	}
+	if release.Version == old.Version {
+		return
+	}
+	var entry ManifestEntry
+	if entry, err = installPluginRelease(release, path, old.Path); err != nil {
+		return
+	}
+	if old.Path != entry.Path {
+		os.Remove(filepath.Join(path, filepath.FromSlash(old.Path)))
+	}
+	entry.Ref = old.Ref
+	manifest.SetPlugin(entry)
+	return true, nil
+}
+
+// RemovePlugin deletes the plugin file and removes it from the manifest
+func RemovePlugin(manifest *InstallManifest, path string, id string) (err error) {
+	entry, ok := manifest.RemovePlugin(id)
+	if !ok {
+		return &PluginNotInstalledErr{id}
+	}
+	if err = os.Remove(filepath.Join(path, filepath.FromSlash(entry.Path))); err != nil && !os.IsNotExist(err) {
+		return
+	}
+	return nil
+}
@@ -0,0 +1,73 @@
+package installer
+
+import (
+	"os"
+	"strings"
+)
+
+// ServerProperties is the content of server.properties, the comments and the order of the lines are kept when it's written back
+type ServerProperties struct {
+	lines []string
+	index map[string]int
+}
+
+func ReadServerProperties(filename string) (p *ServerProperties, err error) {
+	p = &ServerProperties{index: make(map[string]int)}
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		if os.IsNotExist(err) {
+			err = nil
+		}
+		return
+	}
+	for _, line := range strings.Split(strings.ReplaceAll(string(data), "\r\n", "\n"), "\n") {
+		p.lines = append(p.lines, line)
+		if key, _, ok := parsePropertyLine(line); ok {
+			p.index[key] = len(p.lines) - 1
+		}
+	}
+	for len(p.lines) > 0 && p.lines[len(p.lines)-1] == "" {
+		p.lines = p.lines[:len(p.lines)-1]
+	}
+	return
+}
+
+func parsePropertyLine(line string) (key string, value string, ok bool) {
+	line = strings.TrimLeft(line, " \t")
+	if line == "" || line[0] == '#' || line[0] == '!' {
+		return
+	}
+	i := strings.IndexAny(line, "=:")
+	if i < 0 {
+		return strings.TrimSpace(line), "", true
+	}
+	return strings.TrimSpace(line[:i]), strings.TrimLeft(line[i+1:], " \t"), true
+}
+
+func (p *ServerProperties) Get(key string) (value string, ok bool) {
+	i, ok := p.index[key]
+	if !ok {
+		return
+	}
+	_, value, _ = parsePropertyLine(p.lines[i])
+	return value, true
+}
+
+func (p *ServerProperties) Set(key string, value string) {
+	line := key + "=" + value
+	if i, ok := p.index[key]; ok {
+		p.lines[i] = line
+		return
+	}
+	p.lines = append(p.lines, line)
+	p.index[key] = len(p.lines) - 1
+}
+
+// String returns the content of the file
+func (p *ServerProperties) String() string {
+	return strings.Join(p.lines, "\n") + "\n"
+}
+
+func (p *ServerProperties) Save(filename string) error {
+	return os.WriteFile(filename, ([]byte)(p.String()), 0644)
+}
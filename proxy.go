@@ -0,0 +1,327 @@
+package installer
+
+import (
+	"bytes"
+	"crypto/rand"
+	"fmt"
+	"math/big"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// The modes that the proxies forward the player info to the backend servers
+const (
+	// ForwardingModern is the velocity forwarding, which is signed by the forwarding secret
+	ForwardingModern = "modern"
+	// ForwardingLegacy is the bungeecord forwarding, the backend servers should not be reachable from the internet
+	ForwardingLegacy = "legacy"
+	// ForwardingNone doesn't forward the player info, the players join the backend servers in offline mode
+	ForwardingNone = "none"
+)
+
+// DefaultProxyBind is the address that the proxies listen on by default
+const DefaultProxyBind = "0.0.0.0:25577"
+
+type (
+	// ProxyPlanner is implemented by the proxy installers.
+	// The plan also writes the proxy config, and sets the local backend servers up for the forwarding
+	ProxyPlanner interface {
+		PlanProxy(path, name string, version ResolvedVersion, network *ProxyNetwork) (*InstallPlan, error)
+	}
+
+	// ProxyNetwork is the backend servers behind a proxy, and how the player info is forwarded to them
+	ProxyNetwork struct {
+		// Backends are tried in order when a player joins, a "lobby" at 127.0.0.1:25566 is used if it's empty
+		Backends []*ProxyBackend `json:"backends"`
+		// Forwarding is one of [modern legacy none], empty means the default of the proxy
+		Forwarding string `json:"forwarding"`
+		// Secret is the modern forwarding secret, a random one is generated if it's empty
+		Secret string `json:"-"`
+		// Bind is the address that the proxy listens on, default is DefaultProxyBind
+		Bind string `json:"bind"`
+
+		// defaultBackends reports whether the backends are filled by prepare
+		defaultBackends bool
+	}
+
+	ProxyBackend struct {
+		Name    string `json:"name"`
+		Address string `json:"address"`
+		// Dir is the directory of the backend server on this machine, its configs are set for the forwarding.
+		// Empty means the backend should be set up by hand
+		Dir string `json:"dir,omitempty"`
+	}
+)
+
+type ProxyBackendFormatErr struct {
+	Value string
+}
+
+func (e *ProxyBackendFormatErr) Error() string {
+	return fmt.Sprintf("Unexpect backend %q, expect <name>=<host:port>[,<dir>]", e.Value)
+}
+
+type UnsupportForwardingErr struct {
+	Proxy      string
+	Forwarding string
+	Supported  []string
+}
+
+func (e *UnsupportForwardingErr) Error() string {
+	return fmt.Sprintf("Unsupport forwarding %q for %s, should be one of %v", e.Forwarding, e.Proxy, e.Supported)
+}
+
+// ParseProxyBackend parses "<name>=<host:port>[,<dir>]", the port is 25565 if it's omitted
+func ParseProxyBackend(s string) (b *ProxyBackend, err error) {
+	name, addr, ok := strings.Cut(s, "=")
+	if !ok || name == "" || addr == "" {
+		return nil, &ProxyBackendFormatErr{s}
+	}
+	b = &ProxyBackend{Name: name}
+	b.Address, b.Dir, _ = strings.Cut(addr, ",")
+	if _, _, err := net.SplitHostPort(b.Address); err != nil {
+		if strings.Contains(b.Address, ":") {
+			return nil, &ProxyBackendFormatErr{s}
+		}
+		b.Address = net.JoinHostPort(b.Address, "25565")
+	}
+	return
+}
+
+func (b *ProxyBackend) String() string {
+	if b.Dir == "" {
+		return b.Name + "=" + b.Address
+	}
+	return b.Name + "=" + b.Address + "," + b.Dir
+}
+
+const forwardingSecretChars = "ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz0123456789"
+
+func newForwardingSecret() (string, error) {
+	secret := make([]byte, 16)
+	max := big.NewInt((int64)(len(forwardingSecretChars)))
+	for i := range secret {
+		n, err := rand.Int(rand.Reader, max)
+		if err != nil {
+			return "", err
+		}
+		secret[i] = forwardingSecretChars[n.Int64()]
+	}
+	return string(secret), nil
+}
+
+// prepare fills the defaults of the network, the first of the supported forwarding modes is the default one
+func (n *ProxyNetwork) prepare(proxy string, supported []string) (err error) {
+	if n.Bind == "" {
+		n.Bind = DefaultProxyBind
+	}
+	if len(n.Backends) == 0 {
+		n.Backends = []*ProxyBackend{{Name: "lobby", Address: "127.0.0.1:25566"}}
+		n.defaultBackends = true
+	}
+	if n.Forwarding == "" {
+		n.Forwarding = supported[0]
+	}
+	ok := false
+	for _, f := range supported {
+		if f == n.Forwarding {
+			ok = true
+			break
+		}
+	}
+	if !ok {
+		return &UnsupportForwardingErr{proxy, n.Forwarding, supported}
+	}
+	if n.Forwarding == ForwardingModern && n.Secret == "" {
+		if n.Secret, err = newForwardingSecret(); err != nil {
+			return
+		}
+	}
+	return
+}
+
+// planConfig adds the step that writes the proxy config, and reports whether it's written.
+// The existing config is kept if no backend is given, since the starter config would replace the edited one
+func (n *ProxyNetwork) planConfig(plan *InstallPlan, file string, content string) bool {
+	if n.defaultBackends {
+		if _, err := os.Lstat(file); err == nil {
+			loger.Infof("Kept the existing config %q since no backend is given", file)
+			return false
+		}
+	}
+	plan.write(file, content, 0644)
+	return true
+}
+
+// backendType returns the server type that is recorded in the install manifest or detected in the directory
+func backendType(dir string) (serverType string, gameVersion string) {
+	if m, err := ReadInstallManifest(dir); err == nil && m.ServerType != "" {
+		return m.ServerType, m.GameVersion
+	}
+	if s, err := DetectServer(dir); err == nil {
+		return s.Type, s.GameVersion
+	}
+	return "", ""
+}
+
+// planBackends adds the plans that set the local backend servers up for the forwarding,
+// they are executed after the proxy is installed.
+// The backends are switched to offline mode since the proxy authenticates the players
+func (n *ProxyNetwork) planBackends(plan *InstallPlan) (err error) {
+	for _, b := range n.Backends {
+		if b.Dir == "" {
+			continue
+		}
+		var dir string
+		if dir, err = filepath.Abs(b.Dir); err != nil {
+			return
+		}
+		serverType, gameVersion := backendType(dir)
+		loger.Infof("Planning backend %q (%s) in %q for %s forwarding", b.Name, optionalOr(serverType, "unknown server"), dir, n.Forwarding)
+		backend := newInstallPlan(serverType, dir)
+		plan.Then = append(plan.Then, backend)
+
+		file := filepath.Join(dir, "server.properties")
+		var props *ServerProperties
+		if props, err = ReadServerProperties(file); err != nil {
+			return
+		}
+		props.Set("online-mode", "false")
+		if _, port, err := net.SplitHostPort(b.Address); err == nil {
+			props.Set("server-port", port)
+		}
+		backend.write(file, props.String(), 0644)
+
+		switch serverType {
+		case "paper", "":
+			if serverType == "" {
+				loger.Warnf("The server type of backend %q is unknown, it's set up as a paper server", b.Name)
+			}
+			if err = n.planSpigotYml(backend, dir); err != nil {
+				return
+			}
+			if err = n.planPaperYml(backend, dir, gameVersion); err != nil {
+				return
+			}
+		case "spigot":
+			if n.Forwarding == ForwardingModern {
+				loger.Warnf("Spigot backend %q does not support modern forwarding, use paper or legacy forwarding", b.Name)
+			}
+			if err = n.planSpigotYml(backend, dir); err != nil {
+				return
+			}
+		default:
+			if n.Forwarding != ForwardingNone {
+				loger.Warnf("%s backend %q needs a mod such as FabricProxy-Lite or proxy-compatible-forge for %s forwarding, please set it up by hand",
+					serverType, b.Name, n.Forwarding)
+			}
+		}
+	}
+	return
+}
+
+func (n *ProxyNetwork) planSpigotYml(plan *InstallPlan, dir string) error {
+	return planYamlValues(plan, filepath.Join(dir, "spigot.yml"), []yamlValue{
+		{"settings.bungeecord", n.Forwarding == ForwardingLegacy},
+	})
+}
+
+func (n *ProxyNetwork) planPaperYml(plan *InstallPlan, dir string, gameVersion string) error {
+	if n.Forwarding != ForwardingModern {
+		return nil
+	}
+	// paper.yml is replaced by config/paper-global.yml since 1.19
+	if gameVersion != "" && CompareMcVersionStrings(gameVersion, "1.19") < 0 {
+		return planYamlValues(plan, filepath.Join(dir, "paper.yml"), []yamlValue{
+			{"settings.velocity-support.enabled", true},
+			{"settings.velocity-support.online-mode", true},
+			{"settings.velocity-support.secret", n.Secret},
+		})
+	}
+	return planYamlValues(plan, filepath.Join(dir, "config", "paper-global.yml"), []yamlValue{
+		{"proxies.velocity.enabled", true},
+		{"proxies.velocity.online-mode", true},
+		{"proxies.velocity.secret", n.Secret},
+	})
+}
+
+func optionalOr(s string, def string) string {
+	if s == "" {
+		return def
+	}
+	return s
+}
+
+// yamlValue is the value of a dotted key such as "settings.bungeecord"
+type yamlValue struct {
+	Key   string
+	Value any
+}
+
+// planYamlValues adds the step that sets the values in the yaml file, the other content and the comments are kept.
+// The servers fill the missing settings with the defaults, so the file is created if it doesn't exist
+func planYamlValues(plan *InstallPlan, file string, values []yamlValue) (err error) {
+	data, err := os.ReadFile(file)
+	if err != nil && !os.IsNotExist(err) {
+		return
+	}
+	var doc yaml.Node
+	if len(bytes.TrimSpace(data)) > 0 {
+		if err = yaml.Unmarshal(data, &doc); err != nil {
+			return fmt.Errorf("%s: %w", file, err)
+		}
+	}
+	if doc.Kind == 0 {
+		doc.Kind = yaml.DocumentNode
+		doc.Content = []*yaml.Node{{Kind: yaml.MappingNode}}
+	}
+	if doc.Content[0].Kind != yaml.MappingNode {
+		return fmt.Errorf("%s: the document is not a mapping", file)
+	}
+	for _, v := range values {
+		if err = setYamlValue(doc.Content[0], strings.Split(v.Key, "."), v.Value); err != nil {
+			return
+		}
+	}
+	var buf bytes.Buffer
+	encoder := yaml.NewEncoder(&buf)
+	encoder.SetIndent(2)
+	if err = encoder.Encode(&doc); err != nil {
+		return
+	}
+	if err = encoder.Close(); err != nil {
+		return
+	}
+	plan.write(file, buf.String(), 0644)
+	return
+}
+
+func setYamlValue(node *yaml.Node, keys []string, value any) (err error) {
+	var child *yaml.Node
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		if node.Content[i].Value == keys[0] {
+			child = node.Content[i+1]
+			break
+		}
+	}
+	if child == nil {
+		child = new(yaml.Node)
+		node.Content = append(node.Content, &yaml.Node{Kind: yaml.ScalarNode, Value: keys[0]}, child)
+	}
+	if len(keys) == 1 {
+		var v yaml.Node
+		if err = v.Encode(value); err != nil {
+			return
+		}
+		child.Kind, child.Tag, child.Value, child.Style, child.Content = v.Kind, v.Tag, v.Value, v.Style, v.Content
+		return
+	}
+	if child.Kind != yaml.MappingNode {
+		*child = yaml.Node{Kind: yaml.MappingNode}
+	}
+	return setYamlValue(child, keys[1:], value)
+}
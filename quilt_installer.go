@@ -1,34 +1,82 @@
 package installer
 
 import (
-	"context"
 	"net/url"
-	"os"
-	"os/exec"
 	"path/filepath"
+	"strings"
 	"time"
 )
 
 type (
 	QuiltInstaller struct {
-		MavenUrl string
+		MavenUrl string // Default is "https://maven.quiltmc.org/repository/release"
+		MetaUrl  string // Default is "https://meta.quiltmc.org"
+	}
+
+	QuiltGameVersion struct {
+		Version string `json:"version"`
+		Stable  bool   `json:"stable"`
+	}
+	QuiltLoaderInfo struct {
+		Separator string `json:"separator"`
+		Build     int    `json:"build"`
+		Maven     string `json:"maven"`
+		Version   string `json:"version"`
+	}
+	QuiltLoaderVersion struct {
+		Loader QuiltLoaderInfo `json:"loader"`
 	}
 )
 
 var DefaultQuiltInstaller = &QuiltInstaller{
 	MavenUrl: "https://maven.quiltmc.org/repository/release",
+	MetaUrl:  "https://meta.quiltmc.org",
 }
 var _ Installer = DefaultQuiltInstaller
+var _ Planner = DefaultQuiltInstaller
+var _ ResolvedPlanner = DefaultQuiltInstaller
+var _ VersionSource = DefaultQuiltInstaller
 
 func init() {
-	Installers["quilt"] = DefaultQuiltInstaller
+	MustRegister(DefaultQuiltInstaller, InstallerInfo{
+		Name:         "quilt",
+		DisplayName:  "Quilt",
+		Description:  "Quilt server, installed by the official quilt installer",
+		RequiresJava: true,
+	})
 }
 
 func (r *QuiltInstaller) Install(path, name string, target string) (installed string, err error) {
-	return r.InstallWithLoader(path, name, target, "")
+	plan, err := r.Plan(path, name, target)
+	if err != nil {
+		return
+	}
+	return plan.Execute()
+}
+
+func (r *QuiltInstaller) Plan(path, name string, target string) (plan *InstallPlan, err error) {
+	var resolved ResolvedVersion
+	if resolved, err = resolveInstallTarget(r, target); err != nil {
+		return
+	}
+	return r.PlanResolved(path, name, resolved)
+}
+
+func (r *QuiltInstaller) PlanResolved(path, name string, version ResolvedVersion) (*InstallPlan, error) {
+	return r.PlanWithLoader(path, name, version.Game, version.Loader)
 }
 
+// InstallWithLoader installs the quilt server, loader is the quilt loader version, empty means latest
 func (r *QuiltInstaller) InstallWithLoader(path, name string, target string, loader string) (installed string, err error) {
+	plan, err := r.PlanWithLoader(path, name, target, loader)
+	if err != nil {
+		return
+	}
+	return plan.Execute()
+}
+
+// PlanWithLoader plans the quilt server, loader is the quilt loader version, empty means latest stable
+func (r *QuiltInstaller) PlanWithLoader(path, name string, target string, loader string) (plan *InstallPlan, err error) {
 	foundVersion := target
 	if target == "" || target == "latest" || target == "latest-snapshot" {
 		var versions VanillaVersions
@@ -45,70 +93,52 @@ func (r *QuiltInstaller) InstallWithLoader(path, name string, target string, loa
 		}
 	}
 
-	if len(loader) == 0 {
-		loader, err = r.GetLatestInstaller()
-		if err != nil {
+	if loader == "" {
+		if loader, err = resolveLoaderVersion(r, target, QueryLatestStableLoader); err != nil {
 			return
 		}
 	}
-	quiltInstallerUrl, err := url.JoinPath(r.MavenUrl, "org/quiltmc/quilt-installer", loader, "quilt-installer-"+loader+".jar")
-	if err != nil {
-		return
-	}
-	loger.Infof("Getting quilt server installer %s at %q...", foundVersion, quiltInstallerUrl)
-	var installerJar string
-	if installerJar, err = DefaultHTTPClient.DownloadTmp(quiltInstallerUrl, "quilt-installer-*.jar", 0644, nil, -1,
-		downloadingCallback(quiltInstallerUrl)); err != nil {
-		return
-	}
-
-	ctx, cancel := context.WithCancel(context.Background())
-	defer cancel()
-	javapath, err := lookJavaPath()
-	if err != nil {
+	plan = newInstallPlan("quilt", path)
+	plan.Game = target
+	plan.Loader = loader
+	if err = plan.runOrRestore("quilt/"+target+"-"+loader, path, nil, func() (err error) {
+		installerVersion, err := r.GetLatestInstaller()
+		if err != nil {
+			return
+		}
+		quiltInstallerUrl, err := url.JoinPath(r.MavenUrl, "org/quiltmc/quilt-installer", installerVersion, "quilt-installer-"+installerVersion+".jar")
+		if err != nil {
+			return
+		}
+		loger.Infof("Planning quilt server installer %s at %q...", foundVersion, quiltInstallerUrl)
+		installerJar := filepath.Join(PlanTempDir, "quilt-installer-"+installerVersion+".jar")
+		plan.download(quiltInstallerUrl, installerJar, 0, nil)
+		plan.run(path, "java", "-jar", installerJar, "install", "server", target, loader,
+			"--download-server", "--install-dir=.")
 		return
-	}
-	cmd := exec.CommandContext(ctx, javapath, "-jar", installerJar, "install", "server", target, "--download-server", "--install-dir="+path)
-	cmd.Dir = path
-	cmd.Stdout = os.Stdout
-	cmd.Stderr = os.Stdout
-	loger.Infof("Running %q...", cmd.String())
-	if err = cmd.Run(); err != nil {
+	}); err != nil {
 		return
 	}
 
 	// --download-server flag will install vanilla server to server.jar, we need rename it
 	if name == "server" { // name collision
-		if err = renameIfNotExist(filepath.Join(path, "server.jar"), filepath.Join(path, "vanilla_server.jar"), 0644); err != nil {
-			return
-		}
-		var fd *os.File
-		if fd, err = os.Create(filepath.Join(path, "quilt-server-launcher.properties")); err != nil {
-			return
-		}
-		if _, err = fd.Write(([]byte)(time.Now().Format("#" + time.UnixDate + "\n"))); err != nil {
-			return
-		}
-		if _, err = fd.Write(([]byte)(`serverJar=vanilla_server.jar`)); err != nil {
-			return
-		}
+		plan.move(filepath.Join(path, "server.jar"), filepath.Join(path, "vanilla_server.jar"), 0644).Executable = true
+		plan.write(filepath.Join(path, "quilt-server-launcher.properties"),
+			time.Now().Format("#"+time.UnixDate+"\n")+`serverJar=vanilla_server.jar`, 0644).Executable = true
 	}
 	// Quilt use quilt-server-launch.jar, for some reason, the --create-scripts flag won't work
-	installed = filepath.Join(path, name+".jar")
-	if err = renameIfNotExist(filepath.Join(path, "quilt-server-launch.jar"), installed, 0644); err != nil {
-		return
-	}
+	plan.Installed = filepath.Join(path, name+".jar")
+	plan.move(filepath.Join(path, "quilt-server-launch.jar"), plan.Installed, 0644).Executable = true
 	return
 }
 
+// ListVersions returns the versions of the quilt installer, use ListVersionEntries for the minecraft and the loader versions
 func (r *QuiltInstaller) ListVersions(snapshot bool) (versions []string, err error) {
 	data, err := r.GetInstallerVersions()
 	if err != nil {
 		return
 	}
-	for _, v := range data.Versioning.Versions {
-		versions = append(versions, v)
-	}
+	versions = append(versions, data.Versioning.Versions...)
 	return
 }
 
@@ -140,3 +170,39 @@ func (r *QuiltInstaller) GetLatestInstaller() (version string, err error) {
 	version = v0.String()
 	return
 }
+
+func (r *QuiltInstaller) GameVersions() (versions []McVersion, err error) {
+	tg, err := url.JoinPath(r.MetaUrl, "v3", "versions", "game")
+	if err != nil {
+		return
+	}
+	var vs []QuiltGameVersion
+	if err = DefaultHTTPClient.GetJson(tg, &vs); err != nil {
+		return
+	}
+	versions = make([]McVersion, len(vs))
+	for i, v := range vs {
+		versions[i] = mcVersionOf(v.Version, v.Stable)
+	}
+	return
+}
+
+func (r *QuiltInstaller) LoaderVersions(gameVersion string) (versions []LoaderVersion, err error) {
+	tg, err := url.JoinPath(r.MetaUrl, "v3", "versions", "loader", gameVersion)
+	if err != nil {
+		return
+	}
+	var vs []QuiltLoaderVersion
+	if err = DefaultHTTPClient.GetJson(tg, &vs); err != nil {
+		return
+	}
+	versions = make([]LoaderVersion, len(vs))
+	for i, v := range vs {
+		versions[i] = LoaderVersion{
+			Version: v.Loader.Version,
+			// quilt meta doesn't mark stable loaders, pre-releases contain a '-'
+			Stable: !strings.Contains(v.Loader.Version, "-"),
+		}
+	}
+	return
+}
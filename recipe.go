@@ -0,0 +1,450 @@
+package installer
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+	"text/template"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Recipes are declarative installers loaded from JSON or YAML files, such as
+//
+//	name: purpur
+//	displayName: Purpur
+//	requiresJava: false
+//	versions:
+//	  url: https://api.purpurmc.org/v2/purpur
+//	  path: versions
+//	  order: oldest-first
+//	download:
+//	  url: https://api.purpurmc.org/v2/purpur/{{.Version}}/latest/download
+//	  file: "{{.Name}}.jar"
+//	  hash:
+//	    type: md5
+//	    url: https://api.purpurmc.org/v2/purpur/{{.Version}}/latest
+//	    path: md5
+//
+// Strings in download, hash, steps and installed are go templates, with the fields of recipeData.
+type (
+	RecipeVersions struct {
+		// Url is the json endpoint that lists the versions
+		Url string `yaml:"url"`
+		// Path is the dot separated path to the version array in the response, empty means the response itself
+		Path string `yaml:"path"`
+		// Field is the field of the version id if the array contains objects
+		Field string `yaml:"field"`
+		// Order is either "newest-first" (default) or "oldest-first"
+		Order string `yaml:"order"`
+		// List is the static version list, used if Url is empty
+		List []string `yaml:"list"`
+	}
+
+	RecipeHash struct {
+		// Type is one of [md5 sha1 sha224 sha256 sha384 sha512]
+		Type string `yaml:"type"`
+		// Value is the hash itself
+		Value string `yaml:"value"`
+		// Url is the link that the hash is fetched from, if Path is empty, the response is the plain hash text
+		Url  string `yaml:"url"`
+		Path string `yaml:"path"`
+	}
+
+	RecipeDownload struct {
+		Url string `yaml:"url"`
+		// File is the path relative to the install directory
+		File string      `yaml:"file"`
+		Hash *RecipeHash `yaml:"hash"`
+	}
+
+	RecipeRename struct {
+		From string `yaml:"from"`
+		To   string `yaml:"to"`
+	}
+
+	// RecipeStep is a post step, only one of the fields should be set
+	RecipeStep struct {
+		Download *RecipeDownload `yaml:"download"`
+		// Run is the command run in the install directory, "java" is replaced with the found java executable
+		Run    []string      `yaml:"run"`
+		Rename *RecipeRename `yaml:"rename"`
+		Delete string        `yaml:"delete"`
+	}
+
+	Recipe struct {
+		Name         string         `yaml:"name"`
+		DisplayName  string         `yaml:"displayName"`
+		Description  string         `yaml:"description"`
+		Platforms    []string       `yaml:"platforms"`
+		RequiresJava bool           `yaml:"requiresJava"`
+		Versions     RecipeVersions `yaml:"versions"`
+		Download     RecipeDownload `yaml:"download"`
+		Steps        []RecipeStep   `yaml:"steps"`
+		// Installed is the executable path relative to the install directory, default is Download.File
+		Installed string `yaml:"installed"`
+	}
+
+	// RecipeInstaller installs the server by the recipe
+	RecipeInstaller struct {
+		Recipe
+		Source string
+	}
+
+	recipeData struct {
+		Name    string // the executable name without suffix
+		Version string
+		Path    string // the install directory
+		File    string // the path of the main download
+		OS      string
+		Arch    string
+	}
+)
+
+var _ Installer = (*RecipeInstaller)(nil)
+var _ Planner = (*RecipeInstaller)(nil)
+
+type RecipeErr struct {
+	File   string
+	Reason string
+}
+
+func (e *RecipeErr) Error() string {
+	return fmt.Sprintf("Invalid recipe %q: %s", e.File, e.Reason)
+}
+
+// LoadRecipe reads the recipe file, JSON files are parsed as YAML
+func LoadRecipe(file string) (r *RecipeInstaller, err error) {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return
+	}
+	r = &RecipeInstaller{Source: file}
+	if err = yaml.Unmarshal(data, &r.Recipe); err != nil {
+		return nil, &RecipeErr{file, err.Error()}
+	}
+	if r.Name == "" {
+		return nil, &RecipeErr{file, "name is required"}
+	}
+	if r.Download.Url == "" {
+		return nil, &RecipeErr{file, "download.url is required"}
+	}
+	if r.Download.File == "" {
+		r.Download.File = "{{.Name}}.jar"
+	}
+	if r.Installed == "" {
+		r.Installed = r.Download.File
+	}
+	switch r.Versions.Order {
+	case "", "newest-first", "oldest-first":
+	default:
+		return nil, &RecipeErr{file, fmt.Sprintf("unknown versions.order %q", r.Versions.Order)}
+	}
+	return
+}
+
+// LoadRecipes registers all the recipes (*.json, *.yaml and *.yml) in the directory.
+// The invalid or duplicate recipes are skipped, and their errors are joined after the valid ones are loaded.
+// It's not an error if the directory doesn't exist
+func LoadRecipes(dir string) (loaded []string, err error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			err = nil
+		}
+		return
+	}
+	var errs []error
+	for _, e := range entries {
+		switch strings.ToLower(filepath.Ext(e.Name())) {
+		case ".json", ".yaml", ".yml":
+		default:
+			continue
+		}
+		if e.IsDir() {
+			continue
+		}
+		file := filepath.Join(dir, e.Name())
+		r, er := LoadRecipe(file)
+		if er == nil {
+			if er = Register(r, r.Info()); er != nil {
+				er = &RecipeErr{file, er.Error()}
+			}
+		}
+		if er != nil {
+			errs = append(errs, er)
+			continue
+		}
+		loaded = append(loaded, r.Name)
+	}
+	return loaded, errors.Join(errs...)
+}
+
+func (r *RecipeInstaller) Info() InstallerInfo {
+	return InstallerInfo{
+		Name:         r.Name,
+		DisplayName:  r.DisplayName,
+		Description:  r.Description,
+		Platforms:    r.Platforms,
+		RequiresJava: r.RequiresJava,
+		Source:       r.Source,
+	}
+}
+
+func (r *RecipeInstaller) render(field string, text string, data *recipeData) (string, error) {
+	tmpl, err := template.New(field).Option("missingkey=error").Parse(text)
+	if err != nil {
+		return "", &RecipeErr{r.Source, err.Error()}
+	}
+	var buf bytes.Buffer
+	if err = tmpl.Execute(&buf, data); err != nil {
+		return "", &RecipeErr{r.Source, err.Error()}
+	}
+	return buf.String(), nil
+}
+
+// localPath renders the path template, and makes sure the path is in the install directory
+func (r *RecipeInstaller) localPath(field string, text string, data *recipeData) (string, error) {
+	p, err := r.render(field, text, data)
+	if err != nil {
+		return "", err
+	}
+	if !filepath.IsLocal(p) {
+		return "", &NotLocalPathErr{p}
+	}
+	return filepath.Join(data.Path, p), nil
+}
+
+// jsonPathGet walks the dot separated path, numbers are used as array indexes
+func jsonPathGet(v any, path string) (any, error) {
+	if path == "" {
+		return v, nil
+	}
+	for _, key := range strings.Split(path, ".") {
+		switch o := v.(type) {
+		case map[string]any:
+			var ok bool
+			if v, ok = o[key]; !ok {
+				return nil, fmt.Errorf("key %q not found", key)
+			}
+		case []any:
+			i, err := strconv.Atoi(key)
+			if err != nil || i < 0 || i >= len(o) {
+				return nil, fmt.Errorf("index %q out of range", key)
+			}
+			v = o[i]
+		default:
+			return nil, fmt.Errorf("cannot get %q from %T", key, v)
+		}
+	}
+	return v, nil
+}
+
+func (r *RecipeInstaller) ListVersions(snapshot bool) (versions []string, err error) {
+	vs := r.Versions
+	if vs.Url == "" {
+		versions = append(versions, vs.List...)
+	} else {
+		var res any
+		if err = DefaultHTTPClient.GetJson(vs.Url, &res); err != nil {
+			return
+		}
+		var list any
+		if list, err = jsonPathGet(res, vs.Path); err != nil {
+			return nil, &RecipeErr{r.Source, "versions: " + err.Error()}
+		}
+		arr, ok := list.([]any)
+		if !ok {
+			return nil, &RecipeErr{r.Source, fmt.Sprintf("versions: expect an array, got %T", list)}
+		}
+		for _, item := range arr {
+			if vs.Field != "" {
+				if item, err = jsonPathGet(item, vs.Field); err != nil {
+					return nil, &RecipeErr{r.Source, "versions: " + err.Error()}
+				}
+			}
+			versions = append(versions, fmt.Sprint(item))
+		}
+	}
+	if vs.Order == "oldest-first" {
+		for i, j := 0, len(versions)-1; i < j; i, j = i+1, j-1 {
+			versions[i], versions[j] = versions[j], versions[i]
+		}
+	}
+	return
+}
+
+func (r *RecipeInstaller) Install(path, name string, target string) (installed string, err error) {
+	plan, err := r.Plan(path, name, target)
+	if err != nil {
+		return
+	}
+	return plan.Execute()
+}
+
+func (r *RecipeInstaller) Plan(path, name string, target string) (plan *InstallPlan, err error) {
+	if len(r.Platforms) > 0 {
+		supported := false
+		for _, p := range r.Platforms {
+			if p == runtime.GOOS {
+				supported = true
+				break
+			}
+		}
+		if !supported {
+			return nil, &RecipeErr{r.Source, fmt.Sprintf("platform %s is not supported", runtime.GOOS)}
+		}
+	}
+	if path, err = filepath.Abs(path); err != nil {
+		return
+	}
+	var versions []string
+	if r.Versions.Url != "" || len(r.Versions.List) > 0 {
+		if versions, err = r.ListVersions(false); err != nil {
+			return
+		}
+	}
+	foundVersion := target
+	if target == "" || target == "latest" || target == "latest-snapshot" {
+		if len(versions) == 0 {
+			return nil, &VersionNotFoundErr{r.Name + "-" + foundVersion}
+		}
+		target = versions[0]
+		foundVersion += "(" + target + ")"
+	} else if versions != nil {
+		found := false
+		for _, v := range versions {
+			if v == target {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return nil, &VersionNotFoundErr{r.Name + "-" + target}
+		}
+	}
+	data := &recipeData{
+		Name:    name,
+		Version: target,
+		Path:    path,
+		OS:      runtime.GOOS,
+		Arch:    runtime.GOARCH,
+	}
+	loger.Infof("Planning %s %s by recipe %q", r.Name, foundVersion, r.Source)
+	plan = newInstallPlan(r.Name, path)
+	plan.Game = target
+	if data.File, err = r.download(plan, &r.Download, data); err != nil {
+		return
+	}
+	for i := range r.Steps {
+		if err = r.planStep(plan, &r.Steps[i], data); err != nil {
+			return
+		}
+	}
+	if plan.Installed, err = r.localPath("installed", r.Installed, data); err != nil {
+		return
+	}
+	return
+}
+
+func (r *RecipeInstaller) download(plan *InstallPlan, dl *RecipeDownload, data *recipeData) (file string, err error) {
+	var link string
+	if link, err = r.render("download.url", dl.Url, data); err != nil {
+		return
+	}
+	if file, err = r.localPath("download.file", dl.File, data); err != nil {
+		return
+	}
+	var hashes StringMap
+	if dl.Hash != nil {
+		var sum string
+		if sum, err = r.hash(dl.Hash, data); err != nil {
+			return
+		}
+		hashes = StringMap{dl.Hash.Type: strings.ToLower(sum)}
+	}
+	plan.download(link, file, 0, hashes)
+	return
+}
+
+func (r *RecipeInstaller) hash(h *RecipeHash, data *recipeData) (sum string, err error) {
+	if _, ok := hashesNewer[h.Type]; !ok {
+		return "", &RecipeErr{r.Source, fmt.Sprintf("unsupported hash type %q", h.Type)}
+	}
+	if h.Url == "" {
+		return r.render("hash.value", h.Value, data)
+	}
+	var link string
+	if link, err = r.render("hash.url", h.Url, data); err != nil {
+		return
+	}
+	if h.Path != "" {
+		var res any
+		if err = DefaultHTTPClient.GetJson(link, &res); err != nil {
+			return
+		}
+		var v any
+		if v, err = jsonPathGet(res, h.Path); err != nil {
+			return "", &RecipeErr{r.Source, "hash: " + err.Error()}
+		}
+		return fmt.Sprint(v), nil
+	}
+	var res *http.Response
+	if res, err = DefaultHTTPClient.Get(link); err != nil {
+		return
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		return "", &HttpStatusError{Code: res.StatusCode}
+	}
+	var body []byte
+	if body, err = io.ReadAll(io.LimitReader(res.Body, 4096)); err != nil {
+		return
+	}
+	// files such as "xxx.jar.sha1" may contain the file name after the hash
+	fields := strings.Fields(string(body))
+	if len(fields) == 0 {
+		return "", &RecipeErr{r.Source, fmt.Sprintf("hash: %q is empty", link)}
+	}
+	return fields[0], nil
+}
+
+func (r *RecipeInstaller) planStep(plan *InstallPlan, step *RecipeStep, data *recipeData) (err error) {
+	switch {
+	case step.Download != nil:
+		_, err = r.download(plan, step.Download, data)
+	case len(step.Run) > 0:
+		args := make([]string, len(step.Run))
+		for i, a := range step.Run {
+			if args[i], err = r.render("run", a, data); err != nil {
+				return
+			}
+		}
+		plan.run(data.Path, args...)
+	case step.Rename != nil:
+		var from, to string
+		if from, err = r.localPath("rename.from", step.Rename.From, data); err != nil {
+			return
+		}
+		if to, err = r.localPath("rename.to", step.Rename.To, data); err != nil {
+			return
+		}
+		plan.move(from, to, 0644)
+	case step.Delete != "":
+		var p string
+		if p, err = r.localPath("delete", step.Delete, data); err != nil {
+			return
+		}
+		plan.add(&PlanStep{Kind: StepDelete, Path: p})
+	default:
+		err = &RecipeErr{r.Source, "empty step"}
+	}
+	return
+}
@@ -0,0 +1,235 @@
+package installer
+
+import (
+	"container/heap"
+	"context"
+	"io"
+	"sync"
+	"time"
+)
+
+// The download priorities, the downloads with a higher priority take the free download slots first
+const (
+	PriorityLow    = -10
+	PriorityNormal = 0
+	PriorityHigh   = 10
+)
+
+// minBandwidthBurst is the minimum bytes that a read could take from the bandwidth bucket
+const minBandwidthBurst = 4096
+
+// DownloadScheduler limits the bandwidth and the number of the concurrent downloads of the clients that share it.
+// The zero value has no limit
+type DownloadScheduler struct {
+	mux sync.Mutex
+
+	rate   float64 // bytes per second, zero means no limit
+	burst  float64
+	tokens float64
+	last   time.Time
+
+	max     int // zero means no limit
+	running int
+	waiters waiterQueue
+	seq     uint64
+}
+
+// DefaultScheduler is used by the HTTPClients that don't have a Scheduler
+var DefaultScheduler = new(DownloadScheduler)
+
+type downloadWaiter struct {
+	priority int
+	seq      uint64
+	ready    chan struct{}
+	granted  bool
+	canceled bool
+}
+
+// waiterQueue is a heap of the waiters ordered by priority, the earlier ones go first if the priorities are the same
+type waiterQueue []*downloadWaiter
+
+func (q waiterQueue) Len() int { return len(q) }
+func (q waiterQueue) Less(i, j int) bool {
+	if q[i].priority != q[j].priority {
+		return q[i].priority > q[j].priority
+	}
+	return q[i].seq < q[j].seq
+}
+func (q waiterQueue) Swap(i, j int) { q[i], q[j] = q[j], q[i] }
+func (q *waiterQueue) Push(x any)   { *q = append(*q, x.(*downloadWaiter)) }
+func (q *waiterQueue) Pop() any {
+	old := *q
+	w := old[len(old)-1]
+	old[len(old)-1] = nil
+	*q = old[:len(old)-1]
+	return w
+}
+
+// SetBandwidth sets the maximum bytes per second of all the downloads, zero or negative value means no limit
+func (s *DownloadScheduler) SetBandwidth(bytesPerSec int64) {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+	if bytesPerSec <= 0 {
+		s.rate = 0
+		return
+	}
+	s.rate = (float64)(bytesPerSec)
+	s.burst = s.rate
+	if s.burst < minBandwidthBurst {
+		s.burst = minBandwidthBurst
+	}
+	s.tokens = s.burst
+	s.last = time.Now()
+}
+
+// SetMaxDownloads sets the maximum number of the concurrent downloads, zero or negative value means no limit
+func (s *DownloadScheduler) SetMaxDownloads(n int) {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+	if n < 0 {
+		n = 0
+	}
+	s.max = n
+	s.grantLocked()
+}
+
+// Acquire blocks until a download slot is free or ctx is done, the waiters with a higher priority get the slots first
+func (s *DownloadScheduler) Acquire(ctx context.Context, priority int) (release func(), err error) {
+	s.mux.Lock()
+	if s.max <= 0 {
+		s.running++
+		s.mux.Unlock()
+		return s.releaseFunc(), nil
+	}
+	s.seq++
+	w := &downloadWaiter{
+		priority: priority,
+		seq:      s.seq,
+		ready:    make(chan struct{}),
+	}
+	heap.Push(&s.waiters, w)
+	// the canceled waiters are dropped here too
+	s.grantLocked()
+	s.mux.Unlock()
+
+	select {
+	case <-w.ready:
+		return s.releaseFunc(), nil
+	case <-ctx.Done():
+	}
+	s.mux.Lock()
+	defer s.mux.Unlock()
+	if w.granted {
+		// the slot is granted while ctx is done, pass it to the next one
+		s.running--
+		s.grantLocked()
+	} else {
+		w.canceled = true
+	}
+	return nil, ctx.Err()
+}
+
+func (s *DownloadScheduler) releaseFunc() func() {
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			s.mux.Lock()
+			defer s.mux.Unlock()
+			s.running--
+			s.grantLocked()
+		})
+	}
+}
+
+func (s *DownloadScheduler) grantLocked() {
+	for s.waiters.Len() > 0 && (s.max <= 0 || s.running < s.max) {
+		w := heap.Pop(&s.waiters).(*downloadWaiter)
+		if w.canceled {
+			continue
+		}
+		s.running++
+		w.granted = true
+		close(w.ready)
+	}
+}
+
+// waitBandwidth takes n bytes from the bandwidth bucket, and sleeps until the bucket is not in debt
+func (s *DownloadScheduler) waitBandwidth(ctx context.Context, n int) error {
+	s.mux.Lock()
+	if s.rate <= 0 {
+		s.mux.Unlock()
+		return nil
+	}
+	now := time.Now()
+	s.tokens += now.Sub(s.last).Seconds() * s.rate
+	if s.tokens > s.burst {
+		s.tokens = s.burst
+	}
+	s.last = now
+	s.tokens -= (float64)(n)
+	var delay time.Duration
+	if s.tokens < 0 {
+		delay = (time.Duration)(-s.tokens / s.rate * (float64)(time.Second))
+	}
+	s.mux.Unlock()
+	if delay <= 0 {
+		return nil
+	}
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// maxRead returns the maximum bytes that a read could take, zero means no limit
+func (s *DownloadScheduler) maxRead() int {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+	if s.rate <= 0 {
+		return 0
+	}
+	return (int)(s.burst)
+}
+
+// Reader limits the reads from r by the bandwidth of the scheduler
+func (s *DownloadScheduler) Reader(ctx context.Context, r io.Reader) io.Reader {
+	return &bandwidthReader{ctx: ctx, r: r, s: s}
+}
+
+type bandwidthReader struct {
+	ctx context.Context
+	r   io.Reader
+	s   *DownloadScheduler
+}
+
+func (r *bandwidthReader) Read(buf []byte) (n int, err error) {
+	if max := r.s.maxRead(); max > 0 && len(buf) > max {
+		buf = buf[:max]
+	}
+	n, err = r.r.Read(buf)
+	if n > 0 {
+		if e := r.s.waitBandwidth(r.ctx, n); e != nil && err == nil {
+			err = e
+		}
+	}
+	return
+}
+
+type downloadPriorityKey struct{}
+
+// WithDownloadPriority returns a context that the downloads made with it have the priority
+func WithDownloadPriority(ctx context.Context, priority int) context.Context {
+	return context.WithValue(ctx, downloadPriorityKey{}, priority)
+}
+
+// DownloadPriority returns the priority set by WithDownloadPriority, default is PriorityNormal
+func DownloadPriority(ctx context.Context) int {
+	if p, ok := ctx.Value(downloadPriorityKey{}).(int); ok {
+		return p
+	}
+	return PriorityNormal
+}
@@ -0,0 +1,645 @@
+package installer
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// ServerDefinitionName is the default name of the server definition file
+const ServerDefinitionName = "server.yaml"
+
+type (
+	// ServerDefinition describes the whole server, such as
+	//
+	//	server:
+	//	  type: fabric
+	//	  version: 1.20.1
+	//	java:
+	//	  maxMemory: 4G
+	//	mods:
+	//	  - id: fabric-api
+	//	  - id: lithium
+	//	    version: mc1.20.1-0.11.2
+	//	properties:
+	//	  motd: Hello
+	//	eula: true
+	ServerDefinition struct {
+		Server ServerSpec `yaml:"server" json:"server"`
+		Java   JavaSpec   `yaml:"java" json:"java"`
+		// Modpack is the path or the url of a modrinth modpack, the server spec is ignored if it's set
+		Modpack    string         `yaml:"modpack" json:"modpack,omitempty"`
+		Mods       []PackageSpec  `yaml:"mods" json:"mods,omitempty"`
+		Plugins    []PackageSpec  `yaml:"plugins" json:"plugins,omitempty"`
+		Properties map[string]any `yaml:"properties" json:"properties,omitempty"`
+		Eula       bool           `yaml:"eula" json:"eula"`
+	}
+	ServerSpec struct {
+		Type string `yaml:"type" json:"type"`
+		// Version is a version query, see ResolveVersion
+		Version string `yaml:"version" json:"version,omitempty"`
+		Loader  string `yaml:"loader" json:"loader,omitempty"`
+		// Name is the executable name, default is "minecraft"
+		Name string `yaml:"name" json:"name,omitempty"`
+	}
+	JavaSpec struct {
+		Path      string   `yaml:"path" json:"path,omitempty"`
+		MinMemory string   `yaml:"minMemory" json:"minMemory,omitempty"`
+		MaxMemory string   `yaml:"maxMemory" json:"maxMemory,omitempty"`
+		Args      []string `yaml:"args" json:"args,omitempty"`
+	}
+	PackageSpec struct {
+		Id string `yaml:"id" json:"id"`
+		// Version pins the package, empty means the latest version when it's installed
+		Version string `yaml:"version" json:"version,omitempty"`
+		// Source is the repository, default is "modrinth" for mods and "hangar" for plugins
+		Source string `yaml:"source" json:"source,omitempty"`
+	}
+)
+
+func LoadServerDefinition(filename string) (def *ServerDefinition, err error) {
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		return
+	}
+	def = new(ServerDefinition)
+	if err = yaml.Unmarshal(data, def); err != nil {
+		return nil, fmt.Errorf("Couldn't parse %q: %w", filename, err)
+	}
+	if def.Modpack == "" && def.Server.Type == "" {
+		return nil, fmt.Errorf("Invalid server definition %q: server.type or modpack is required", filename)
+	}
+	if def.Server.Name == "" {
+		def.Server.Name = "minecraft"
+	}
+	// local modpack is relative to the definition file
+	if def.Modpack != "" && !filepath.IsAbs(def.Modpack) {
+		if u, er := url.ParseRequestURI(def.Modpack); er != nil || u.Scheme == "" || u.Host == "" {
+			def.Modpack = filepath.Join(filepath.Dir(filename), def.Modpack)
+		}
+	}
+	return
+}
+
+const (
+	PlanInstallServer    = "install-server"
+	PlanInstallModpack   = "install-modpack"
+	PlanInstallMod       = "install-mod"
+	PlanRemoveMod        = "remove-mod"
+	PlanInstallPlugin    = "install-plugin"
+	PlanRemovePlugin     = "remove-plugin"
+	PlanSetProperty      = "set-property"
+	PlanAcceptEula       = "accept-eula"
+	PlanWriteStartScript = "write-start-script"
+)
+
+type (
+	PlanAction struct {
+		Kind   string `json:"kind"`
+		Target string `json:"target"`
+		From   string `json:"from,omitempty"`
+		To     string `json:"to,omitempty"`
+
+		spec PackageSpec
+	}
+
+	// ServerPlan is the list of changes that converge the server directory to the definition
+	ServerPlan struct {
+		Dir        string            `json:"dir"`
+		Definition *ServerDefinition `json:"-"`
+		Actions    []PlanAction      `json:"actions"`
+
+		manifest    *InstallManifest
+		serverType  string
+		gameVersion string
+		target      ResolvedVersion
+		modpackPath string
+		modpackSha1 string
+		cleanups    []func()
+	}
+)
+
+func (a PlanAction) String() string {
+	switch {
+	case a.From != "" && a.To != "":
+		return fmt.Sprintf("%s %s: %s -> %s", a.Kind, a.Target, a.From, a.To)
+	case a.To != "":
+		return fmt.Sprintf("%s %s: %s", a.Kind, a.Target, a.To)
+	case a.From != "":
+		return fmt.Sprintf("%s %s (%s)", a.Kind, a.Target, a.From)
+	}
+	return a.Kind + " " + a.Target
+}
+
+// WriteText writes the human readable plan
+func (p *ServerPlan) WriteText(w io.Writer) (err error) {
+	var b strings.Builder
+	if len(p.Actions) == 0 {
+		fmt.Fprintf(&b, "Server %q is up to date\n", p.Dir)
+	} else {
+		fmt.Fprintf(&b, "Plan for %q, %d change(s):\n", p.Dir, len(p.Actions))
+		for _, a := range p.Actions {
+			fmt.Fprintf(&b, "  %s\n", a)
+		}
+	}
+	_, err = io.WriteString(w, b.String())
+	return
+}
+
+// Close removes the temporary files of the plan
+func (p *ServerPlan) Close() error {
+	for _, c := range p.cleanups {
+		c()
+	}
+	p.cleanups = nil
+	return nil
+}
+
+func (p *ServerPlan) add(a PlanAction) {
+	p.Actions = append(p.Actions, a)
+}
+
+func (p *ServerPlan) fileExists(rel string) bool {
+	if rel == "" {
+		return false
+	}
+	_, err := os.Stat(filepath.Join(p.Dir, filepath.FromSlash(rel)))
+	return err == nil
+}
+
+func fileSha1(filename string) (string, error) {
+	fd, err := os.Open(filename)
+	if err != nil {
+		return "", err
+	}
+	defer fd.Close()
+	h := sha1.New()
+	if _, err = io.Copy(h, fd); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// resolveFullTarget resolves the version query, include "latest", so the result can be compared with the manifest
+func resolveFullTarget(ir Installer, query string) (res ResolvedVersion, err error) {
+	if source, ok := ir.(VersionSource); ok {
+		return ResolveVersion(source, query)
+	}
+	if query == "" || query == QueryLatest || query == QueryLatestSnapshot {
+		var versions []string
+		if versions, err = ir.ListVersions(query == QueryLatestSnapshot); err != nil {
+			return
+		}
+		if len(versions) == 0 {
+			return res, &VersionNotFoundErr{query}
+		}
+		return ResolvedVersion{Game: versions[0]}, nil
+	}
+	return ResolvedVersion{Game: query}, nil
+}
+
+// PlanServer compares the server directory with the definition, and returns the changes need to be applied.
+// The plan should be closed after used
+func PlanServer(dir string, def *ServerDefinition) (p *ServerPlan, err error) {
+	p = &ServerPlan{
+		Dir:        dir,
+		Definition: def,
+		Actions:    []PlanAction{},
+	}
+	defer func() {
+		if err != nil {
+			p.Close()
+			p = nil
+		}
+	}()
+	if p.manifest, err = ReadInstallManifest(dir); err != nil {
+		return
+	}
+	m := p.manifest
+	serverChanged := false
+	if def.Modpack != "" {
+		if serverChanged, err = p.planModpack(); err != nil {
+			return
+		}
+	} else {
+		if serverChanged, err = p.planServer(); err != nil {
+			return
+		}
+	}
+	if err = p.planMods(serverChanged); err != nil {
+		return
+	}
+	if err = p.planPlugins(serverChanged); err != nil {
+		return
+	}
+
+	keys := make([]string, 0, len(def.Properties))
+	for k := range def.Properties {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	var props *ServerProperties
+	if props, err = ReadServerProperties(filepath.Join(dir, "server.properties")); err != nil {
+		return
+	}
+	for _, k := range keys {
+		value := ""
+		if v := def.Properties[k]; v != nil {
+			// an empty value in yaml is decoded as nil
+			value = fmt.Sprint(v)
+		}
+		if old, ok := props.Get(k); !ok || old != value {
+			p.add(PlanAction{Kind: PlanSetProperty, Target: k, From: old, To: value})
+		}
+	}
+
+	if def.Eula && !eulaAccepted(dir) {
+		p.add(PlanAction{Kind: PlanAcceptEula, Target: "eula.txt"})
+	}
+
+	if !def.Java.isEmpty() {
+		script := startScriptName()
+		if serverChanged {
+			p.add(PlanAction{Kind: PlanWriteStartScript, Target: script})
+		} else if content := def.Java.startScript(m.Executable); content != "" {
+			old, _ := os.ReadFile(filepath.Join(dir, script))
+			args, _ := os.ReadFile(filepath.Join(dir, userJvmArgsName))
+			if string(old) != content || (!strings.HasSuffix(m.Executable, ".jar") && string(args) != def.Java.userJvmArgs()) {
+				p.add(PlanAction{Kind: PlanWriteStartScript, Target: script})
+			}
+		}
+	}
+	return
+}
+
+func (p *ServerPlan) planModpack() (changed bool, err error) {
+	def, m := p.Definition, p.manifest
+	p.modpackPath = def.Modpack
+	if u, er := url.ParseRequestURI(def.Modpack); er == nil && u.Scheme != "" && u.Host != "" {
+		loger.Infof("Downloading modpack %q ...", def.Modpack)
+		if p.modpackPath, err = DefaultHTTPClient.DownloadTmp(def.Modpack, "server-*.mrpack", 0, nil, -1, nil); err != nil {
+			return
+		}
+		tmp := p.modpackPath
+		p.cleanups = append(p.cleanups, func() { os.Remove(tmp) })
+	}
+	if p.modpackSha1, err = fileSha1(p.modpackPath); err != nil {
+		return
+	}
+	var pack *Mrpack
+	if pack, err = OpenMrpack(p.modpackPath); err != nil {
+		return
+	}
+	defer pack.Close()
+	if p.serverType, p.gameVersion, p.target.Loader, err = pack.ServerTarget(); err != nil {
+		return
+	}
+	p.target.Game = p.gameVersion
+	if m.ModpackSha1 != p.modpackSha1 || !p.fileExists(m.Executable) {
+		p.add(PlanAction{Kind: PlanInstallModpack, Target: pack.Name, From: m.Modpack, To: def.Modpack})
+	}
+	changed = m.ServerType != p.serverType || m.GameVersion != p.gameVersion || m.LoaderVersion != p.target.Loader
+	return
+}
+
+func (p *ServerPlan) planServer() (changed bool, err error) {
+	def, m := p.Definition, p.manifest
+	p.serverType = def.Server.Type
+	ir, ok := Get(p.serverType)
+	if !ok {
+		return false, &UnsupportGameErr{p.serverType}
+	}
+	query := def.Server.Version
+	if def.Server.Loader != "" {
+		query = def.Server.Loader + "@" + query
+	}
+	if p.target, err = resolveFullTarget(ir, query); err != nil {
+		return
+	}
+	p.gameVersion = p.target.Game
+	changed = m.ServerType != p.serverType || m.GameVersion != p.gameVersion ||
+		(p.target.Loader != "" && m.LoaderVersion != p.target.Loader)
+	if changed || !p.fileExists(m.Executable) {
+		from := ""
+		if m.ServerType != "" {
+			from = m.ServerType + " " + ResolvedVersion{Game: m.GameVersion, Loader: m.LoaderVersion}.String()
+		}
+		p.add(PlanAction{Kind: PlanInstallServer, Target: p.serverType, From: from, To: p.target.String()})
+	}
+	return
+}
+
+// findPackage finds the package in both mods and plugins, since modrinth mods for bukkit servers are installed as plugins
+func (m *InstallManifest) findPackage(source string, ref string) (entry ManifestEntry, ok bool) {
+	if entry, ok = m.FindMod(source, ref); ok {
+		return
+	}
+	return m.FindPlugin(source, ref)
+}
+
+func (p *ServerPlan) planPackage(kind string, spec PackageSpec, serverChanged bool) {
+	entry, ok := p.manifest.findPackage(spec.Source, spec.Id)
+	action := PlanAction{Kind: kind, Target: spec.Id, To: spec.Version, spec: spec}
+	if ok {
+		action.From = entry.Version
+	}
+	switch {
+	case !ok || !p.fileExists(entry.Path):
+	case spec.Version != "" && spec.Version != entry.Version && spec.Version != entry.VersionId:
+	case serverChanged && spec.Version == "":
+	default:
+		return
+	}
+	if action.To == "" {
+		action.To = "latest"
+	}
+	p.add(action)
+}
+
+func (p *ServerPlan) planMods(serverChanged bool) (err error) {
+	desired := make(map[string]bool)
+	for _, spec := range p.Definition.Mods {
+		if spec.Source == "" {
+			spec.Source = "modrinth"
+		}
+		if spec.Source != "modrinth" {
+			return &UnknownPluginSourceErr{spec.Source}
+		}
+		desired[strings.ToLower(spec.Id)] = true
+		p.planPackage(PlanInstallMod, spec, serverChanged)
+	}
+	for _, e := range p.manifest.Mods {
+		if e.Ref != "" && !desired[strings.ToLower(e.Ref)] {
+			p.add(PlanAction{Kind: PlanRemoveMod, Target: e.Ref, From: e.Version, spec: PackageSpec{Id: e.Ref, Source: e.Source}})
+		} else if e.isOrphan(desired) {
+			p.add(PlanAction{Kind: PlanRemoveMod, Target: e.Id, From: e.Version, spec: PackageSpec{Id: e.Id, Source: e.Source}})
+		}
+	}
+	return
+}
+
+func (p *ServerPlan) planPlugins(serverChanged bool) (err error) {
+	desired := make(map[string]bool)
+	for _, spec := range p.Definition.Mods {
+		desired[strings.ToLower(spec.Id)] = true
+	}
+	for _, spec := range p.Definition.Plugins {
+		if spec.Source == "" {
+			spec.Source = "hangar"
+		}
+		if _, ok := GetPluginSource(spec.Source); !ok {
+			return &UnknownPluginSourceErr{spec.Source}
+		}
+		desired[strings.ToLower(spec.Id)] = true
+		p.planPackage(PlanInstallPlugin, spec, serverChanged)
+	}
+	for _, e := range p.manifest.Plugins {
+		if e.Ref != "" && !desired[strings.ToLower(e.Ref)] {
+			p.add(PlanAction{Kind: PlanRemovePlugin, Target: e.Ref, From: e.Version, spec: PackageSpec{Id: e.Ref, Source: e.Source}})
+		} else if e.isOrphan(desired) {
+			p.add(PlanAction{Kind: PlanRemovePlugin, Target: e.Id, From: e.Version, spec: PackageSpec{Id: e.Id, Source: e.Source}})
+		}
+	}
+	return
+}
+
+func eulaAccepted(dir string) bool {
+	props, err := ReadServerProperties(filepath.Join(dir, "eula.txt"))
+	if err != nil {
+		return false
+	}
+	v, _ := props.Get("eula")
+	return strings.EqualFold(v, "true")
+}
+
+func (j JavaSpec) isEmpty() bool {
+	return j.Path == "" && j.MinMemory == "" && j.MaxMemory == "" && len(j.Args) == 0
+}
+
+func (j JavaSpec) jvmArgs() (args []string) {
+	if j.MinMemory != "" {
+		args = append(args, "-Xms"+j.MinMemory)
+	}
+	if j.MaxMemory != "" {
+		args = append(args, "-Xmx"+j.MaxMemory)
+	}
+	return append(args, j.Args...)
+}
+
+// userJvmArgsName is the file that the run scripts made by forge read the jvm arguments from
+const userJvmArgsName = "user_jvm_args.txt"
+
+func (j JavaSpec) userJvmArgs() string {
+	return "# Generated by server-installer from the server definition\n" + strings.Join(j.jvmArgs(), "\n") + "\n"
+}
+
+func startScriptName() string {
+	if runtime.GOOS == "windows" {
+		return "start.bat"
+	}
+	return "start.sh"
+}
+
+// startScript returns the script that starts the executable with the java settings.
+// The scripts made by forge read the jvm arguments from user_jvm_args.txt, so they are run directly,
+// and the java path cannot be changed for them
+func (j JavaSpec) startScript(executable string) string {
+	if executable == "" {
+		return ""
+	}
+	java := j.Path
+	if java == "" {
+		java = "java"
+	}
+	args := strings.Join(j.jvmArgs(), " ")
+	var cmd string
+	if strings.HasSuffix(executable, ".jar") {
+		cmd = fmt.Sprintf("%q %s -jar %q nogui", java, args, executable)
+	} else {
+		cmd = fmt.Sprintf("%q nogui", "./"+executable)
+	}
+	if runtime.GOOS == "windows" {
+		return "@echo off\r\ncd /d \"%~dp0\"\r\n" + strings.ReplaceAll(cmd, "./", "") + " %*\r\n"
+	}
+	return "#!/bin/sh\ncd \"$(dirname \"$0\")\"\nexec " + cmd + " \"$@\"\n"
+}
+
+// Apply runs the actions of the plan, the install manifest is saved after each action
+func (p *ServerPlan) Apply() (err error) {
+	def, m := p.Definition, p.manifest
+	save := func() error { return m.Save(p.Dir) }
+	for _, a := range p.Actions {
+		loger.Infof("Applying %s", a)
+		switch a.Kind {
+		case PlanInstallModpack:
+			err = p.applyModpack()
+		case PlanInstallServer:
+			err = p.applyServer()
+		case PlanInstallMod:
+			err = p.applyMod(a.spec)
+		case PlanInstallPlugin:
+			err = p.applyPlugin(a.spec)
+		case PlanRemoveMod:
+			if entry, ok := m.FindMod(a.spec.Source, a.spec.Id); ok {
+				m.RemoveMod(entry.Id)
+				if er := os.Remove(filepath.Join(p.Dir, filepath.FromSlash(entry.Path))); er != nil && !os.IsNotExist(er) {
+					err = er
+				}
+			}
+		case PlanRemovePlugin:
+			if entry, ok := m.FindPlugin(a.spec.Source, a.spec.Id); ok {
+				err = RemovePlugin(m, p.Dir, entry.Id)
+			}
+		case PlanSetProperty:
+			err = p.applyProperty(a.Target, a.To)
+		case PlanAcceptEula:
+			err = os.WriteFile(filepath.Join(p.Dir, "eula.txt"), ([]byte)("eula=true\n"), 0644)
+		case PlanWriteStartScript:
+			content := def.Java.startScript(m.Executable)
+			if content == "" {
+				err = errors.New("Executable is unknown, cannot write the start script")
+				break
+			}
+			if err = os.WriteFile(filepath.Join(p.Dir, a.Target), ([]byte)(content), 0755); err != nil {
+				break
+			}
+			if !strings.HasSuffix(m.Executable, ".jar") {
+				err = os.WriteFile(filepath.Join(p.Dir, userJvmArgsName), ([]byte)(def.Java.userJvmArgs()), 0644)
+			}
+		}
+		if er := save(); err == nil {
+			err = er
+		}
+		if err != nil {
+			return fmt.Errorf("%s: %w", a, err)
+		}
+	}
+	return
+}
+
+// executeServer executes the plan of the server, the old executable and its launch files are replaced
+// when the plan is committed, so they are kept if the install failed. The other files follow the overwrite policy
+func (p *ServerPlan) executeServer(plan *InstallPlan) (installed string, err error) {
+	plan.ReplaceExecutables = true
+	if exe := p.manifest.Executable; exe != "" && plan.Installed != "" {
+		old := filepath.Join(p.Dir, filepath.FromSlash(exe))
+		if _, e := os.Lstat(old); e == nil && old != plan.Installed {
+			plan.add(&PlanStep{Kind: StepDelete, Path: old})
+		}
+	}
+	return plan.Execute()
+}
+
+func (p *ServerPlan) recordServer(installed string, loader string) {
+	m := p.manifest
+	m.ServerType, m.GameVersion, m.LoaderVersion = p.serverType, p.gameVersion, loader
+	if rel, err := filepath.Rel(p.Dir, installed); err == nil {
+		m.Executable = filepath.ToSlash(rel)
+	}
+}
+
+func (p *ServerPlan) applyModpack() (err error) {
+	var pack *Mrpack
+	if pack, err = OpenMrpack(p.modpackPath); err != nil {
+		return
+	}
+	defer pack.Close()
+	all := func(f MrpackFileMeta) bool { return true }
+	// the files of the old modpack that the new one doesn't have are removed
+	var plan *InstallPlan
+	if plan, err = pack.PlanServerUpdate(p.Dir, p.manifest.ModpackFiles, p.manifest.DisabledMods, all); err != nil {
+		return
+	}
+	if _, err = plan.Execute(); err != nil {
+		return
+	}
+	var files []MrpackFileMeta
+	if files, err = pack.ServerFiles(all); err != nil {
+		return
+	}
+	var loader, installed string
+	if _, _, loader, err = pack.ServerTarget(); err != nil {
+		return
+	}
+	if plan, err = pack.PlanLoader(p.Dir, p.Definition.Server.Name); err != nil {
+		return
+	}
+	if installed, err = p.executeServer(plan); err != nil {
+		return
+	}
+	p.recordServer(installed, loader)
+	p.manifest.SetModpack(p.Definition.Modpack, p.modpackSha1, files)
+	return
+}
+
+func (p *ServerPlan) applyServer() (err error) {
+	ir, _ := Get(p.serverType)
+	var plan *InstallPlan
+	if plan, err = PlanInstall(ir, p.serverType, p.Dir, p.Definition.Server.Name, p.target); err != nil {
+		return
+	}
+	var installed string
+	if installed, err = p.executeServer(plan); err != nil {
+		return
+	}
+	p.recordServer(installed, p.target.Loader)
+	return
+}
+
+func (p *ServerPlan) applyMod(spec PackageSpec) (err error) {
+	var installed []ModrinthInstalledFile
+	if installed, err = DefaultModrinthClient.InstallMod(p.Dir, spec.Id, spec.Version, p.serverType, p.gameVersion); err != nil {
+		return
+	}
+	for i, f := range installed {
+		entry := ManifestEntry{
+			Source:    "modrinth",
+			Id:        f.ProjectId,
+			Version:   f.VersionNumber,
+			VersionId: f.VersionId,
+			Path:      f.Path,
+			Hashes:    f.Hashes,
+		}
+		if i == 0 {
+			entry.Ref = spec.Id
+			entry.Pinned = spec.Version != ""
+			if old, ok := p.manifest.findPackage("modrinth", spec.Id); ok && old.Path != entry.Path {
+				os.Remove(filepath.Join(p.Dir, filepath.FromSlash(old.Path)))
+			}
+		} else {
+			entry.RequiredBy = []string{spec.Id}
+		}
+		if strings.HasPrefix(f.Path, "plugins/") {
+			p.manifest.SetPlugin(entry)
+		} else {
+			p.manifest.SetMod(entry)
+		}
+	}
+	return
+}
+
+func (p *ServerPlan) applyPlugin(spec PackageSpec) (err error) {
+	source, _ := GetPluginSource(spec.Source)
+	_, err = InstallPlugin(source, p.manifest, p.Dir, spec.Id, spec.Version, p.serverType, p.gameVersion)
+	return
+}
+
+func (p *ServerPlan) applyProperty(key string, value string) (err error) {
+	file := filepath.Join(p.Dir, "server.properties")
+	var props *ServerProperties
+	if props, err = ReadServerProperties(file); err != nil {
+		return
+	}
+	props.Set(key, value)
+	return props.Save(file)
+}
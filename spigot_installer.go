@@ -1,7 +1,6 @@
 package installer
 
 import (
-	"context"
 	"os"
 	"os/exec"
 	"path/filepath"
@@ -13,22 +12,39 @@ type (
 )
 
 var _ Installer = (*SpigotInstaller)(nil)
+var _ Planner = (*SpigotInstaller)(nil)
+var _ ResolvedPlanner = (*SpigotInstaller)(nil)
+var _ VersionSource = (*SpigotInstaller)(nil)
 
 func init() {
-	Installers["spigot"] = &SpigotInstaller{}
+	MustRegister(&SpigotInstaller{}, InstallerInfo{
+		Name:         "spigot",
+		DisplayName:  "Spigot",
+		Description:  "Spigot server, built by BuildTools, git is required",
+		RequiresJava: true,
+	})
 }
 
 const SpigotBuildToolsURI = "https://hub.spigotmc.org/jenkins/job/BuildTools/lastSuccessfulBuild/artifact/target/BuildTools.jar"
 
-func (*SpigotInstaller) Install(path, name string, target string) (installed string, err error) {
-	if _, err = exec.LookPath("git"); err != nil {
+func (r *SpigotInstaller) Install(path, name string, target string) (installed string, err error) {
+	plan, err := r.Plan(path, name, target)
+	if err != nil {
 		return
 	}
-	var javapath string
-	if javapath, err = lookJavaPath(); err != nil {
+	return plan.Execute()
+}
+
+func (r *SpigotInstaller) Plan(path, name string, target string) (plan *InstallPlan, err error) {
+	var resolved ResolvedVersion
+	if resolved, err = resolveInstallTarget(r, target); err != nil {
 		return
 	}
+	return r.PlanResolved(path, name, resolved)
+}
 
+func (r *SpigotInstaller) PlanResolved(path, name string, resolved ResolvedVersion) (plan *InstallPlan, err error) {
+	target := resolved.Game
 	if path, err = filepath.Abs(path); err != nil {
 		return
 	}
@@ -47,34 +63,47 @@ func (*SpigotInstaller) Install(path, name string, target string) (installed str
 		foundVersion += "(" + target + ")"
 	}
 
+	plan = newInstallPlan("spigot", path)
+	plan.Game = target
 	buildDir := filepath.Join(os.TempDir(), "server-installer-"+PkgVersion+".bukkit-build-tools.tmp")
-	loger.Infof("Getting %q...", SpigotBuildToolsURI)
-	buildToolJar := filepath.Join(buildDir, "BuildTools.jar")
-	// TODO: use cached BuildTools.Jar if possible
-	if err = DefaultHTTPClient.Download(SpigotBuildToolsURI, buildToolJar, 0644, nil, -1,
-		downloadingCallback(SpigotBuildToolsURI)); err != nil {
-		return
-	}
-	ctx, cancel := context.WithCancel(context.Background())
-	defer cancel()
-	cmd := exec.CommandContext(ctx, javapath, "-jar", "BuildTools.jar", "--compile", "spigot", "--rev", target)
-	cmd.Dir = buildDir
-	cmd.Stdout = os.Stdout
-	cmd.Stderr = os.Stdout
-	loger.Infof("Running %q...", cmd.String())
-	if err = cmd.Run(); err != nil {
-		loger.Infof("Build failed. Build log (if exists) moved to BuildTools.log")
-		os.Rename(filepath.Join(buildDir, "BuildTools.log.txt"), "BuildTools.log")
+	jarName := "spigot-" + target + ".jar"
+	// only the built jar is kept in the store, since BuildTools needs git and maven to build it
+	if err = plan.runOrRestore("spigot/"+target, buildDir, []string{jarName}, func() (err error) {
+		if _, err = exec.LookPath("git"); err != nil {
+			return
+		}
+		loger.Infof("Planning spigot %s built by %q...", foundVersion, SpigotBuildToolsURI)
+		buildToolJar := filepath.Join(PlanTempDir, "BuildTools.jar")
+		plan.download(SpigotBuildToolsURI, buildToolJar, 0, nil)
+		step := plan.run(buildDir, "java", "-jar", buildToolJar, "--compile", "spigot", "--rev", target)
+		step.Log = filepath.Join(buildDir, "BuildTools.log.txt")
 		return
-	}
-	installed = filepath.Join(path, name+".jar")
-	if err = renameIfNotExist(filepath.Join(buildDir, "spigot-"+target+".jar"), installed, 0644); err != nil {
+	}); err != nil {
 		return
 	}
+	plan.Installed = filepath.Join(path, name+".jar")
+	plan.move(filepath.Join(buildDir, jarName), plan.Installed, 0644).Executable = true
 	return
 }
 
 func (r *SpigotInstaller) ListVersions(snapshot bool) (versions []string, err error) {
-	versions = []string{"latest"}
+	return gameVersionIds(r, snapshot)
+}
+
+// GameVersions returns the minecraft releases, since BuildTools only supports releases
+func (r *SpigotInstaller) GameVersions() (versions []McVersion, err error) {
+	vs, err := VanillaIns.GameVersions()
+	if err != nil {
+		return
+	}
+	for _, v := range vs {
+		if v.IsStable() {
+			versions = append(versions, v)
+		}
+	}
 	return
 }
+
+func (r *SpigotInstaller) LoaderVersions(gameVersion string) ([]LoaderVersion, error) {
+	return nil, nil
+}
@@ -0,0 +1,294 @@
+package installer
+
+import (
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// stage is the staging directory of an install.
+// The steps write files into it, and the files are moved into the install directory when it's committed,
+// so a failed install leaves nothing behind
+type stage struct {
+	root string // the absolute install directory
+	dir  string
+	// deletes are the files in the install directory that will be removed when committed
+	deletes []string
+	// placed are the files placed by the steps, existing files of them are handled by the policy when committed.
+	// The other files (such as the files made by the external installers) always replace the existing ones
+	placed map[string]bool
+	policy OverwritePolicy
+}
+
+func newStage(path string, policy OverwritePolicy) (st *stage, err error) {
+	root, err := filepath.Abs(path)
+	if err != nil {
+		return
+	}
+	parent := filepath.Dir(root)
+	if err = os.MkdirAll(parent, 0755); err != nil {
+		return
+	}
+	// the staging directory is next to the install directory, so the files can be renamed into it
+	dir, err := os.MkdirTemp(parent, "."+filepath.Base(root)+".staging-*")
+	if err != nil {
+		return
+	}
+	return &stage{
+		root:   root,
+		dir:    dir,
+		placed: make(map[string]bool),
+		policy: policy.orDefault(),
+	}, nil
+}
+
+// path returns the staged path and the real path, paths outside of the install directory are not staged
+func (st *stage) path(path string) (staged string, real string, err error) {
+	if real, err = filepath.Abs(path); err != nil {
+		return
+	}
+	rel, e := filepath.Rel(st.root, real)
+	if e != nil || !filepath.IsLocal(rel) {
+		return real, real, nil
+	}
+	return filepath.Join(st.dir, rel), real, nil
+}
+
+// arg replaces the install directory in the command argument with the staging directory
+func (st *stage) arg(arg string) string {
+	var b strings.Builder
+	for {
+		i := strings.Index(arg, st.root)
+		if i < 0 {
+			break
+		}
+		end := i + len(st.root)
+		b.WriteString(arg[:i])
+		// make sure it's not a prefix of another file name
+		if end == len(arg) || !isFileNameChar(arg[end]) {
+			b.WriteString(st.dir)
+		} else {
+			b.WriteString(st.root)
+		}
+		arg = arg[end:]
+	}
+	b.WriteString(arg)
+	return b.String()
+}
+
+func isFileNameChar(c byte) bool {
+	return 'a' <= c && c <= 'z' || 'A' <= c && c <= 'Z' || '0' <= c && c <= '9' || c == '.' || c == '_' || c == '-'
+}
+
+// unstage returns the path in the install directory that the staged path will be moved to
+func (st *stage) unstage(path string) string {
+	if rel, err := filepath.Rel(st.dir, path); err == nil && filepath.IsLocal(rel) {
+		return filepath.Join(st.root, rel)
+	}
+	return path
+}
+
+// place records the file that a step places, and fails early if it exists and the policy is OverwriteFail.
+// The files that are not recorded replace the existing ones when committed, that's used if replace is true
+func (st *stage) place(src string, staged string, real string, replace bool) error {
+	if staged == real {
+		return nil
+	}
+	if replace {
+		if _, err := os.Lstat(real); err == nil {
+			loger.Infof("Replacing the server file %q", real)
+		}
+		return nil
+	}
+	if _, err := os.Lstat(real); err == nil && st.policy == OverwriteFail {
+		return targetExistErr(src, real)
+	}
+	st.placed[real] = true
+	return nil
+}
+
+// remove removes the file from the install directory when committed
+func (st *stage) remove(real string) {
+	if _, err := os.Lstat(real); err == nil {
+		st.deletes = append(st.deletes, real)
+	}
+}
+
+// keepLog moves the log file of a failed step into the install directory, since the staging directory will be removed
+func (st *stage) keepLog(log string) {
+	if _, err := os.Stat(log); err != nil {
+		return
+	}
+	if err := os.MkdirAll(st.root, 0755); err != nil {
+		return
+	}
+	target := filepath.Join(st.root, filepath.Base(log))
+	if err := os.Rename(log, target); err != nil && crossDevice(err) {
+		if err = osCopy(log, target, 0644); err == nil {
+			os.Remove(log)
+		}
+	}
+	loger.Infof("The log is kept at %q", target)
+}
+
+// commit moves the staged files into the install directory.
+// The replaced files are kept in a backup directory until all the files are moved,
+// and they are moved back if any error occurred
+func (st *stage) commit() (err error) {
+	if _, e := os.Lstat(st.root); os.IsNotExist(e) && len(st.deletes) == 0 {
+		// os.MkdirTemp creates the directory with 0700
+		os.Chmod(st.dir, 0755)
+		if err = os.Rename(st.dir, st.root); err == nil {
+			st.dir = ""
+			return
+		}
+	}
+	// check the placed files before changing anything
+	var backups []string // the replaced files that are kept by OverwriteBackup
+	for real := range st.placed {
+		rel, _ := filepath.Rel(st.root, real)
+		staged := filepath.Join(st.dir, rel)
+		if _, e := os.Lstat(staged); e != nil {
+			continue
+		}
+		if info, e := os.Lstat(real); e != nil || info.IsDir() {
+			continue
+		}
+		switch st.policy {
+		case OverwriteFail:
+			return targetExistErr(staged, real)
+		case OverwriteSkipIdentical:
+			var same bool
+			if same, err = sameContent(staged, real); err != nil {
+				return
+			}
+			if !same {
+				return targetExistErr(staged, real)
+			}
+			loger.Infof("Kept %q since it's identical (overwrite policy %q)", real, st.policy)
+			if err = os.Remove(staged); err != nil {
+				return
+			}
+		case OverwriteAlways:
+			loger.Infof("Overwriting %q (overwrite policy %q)", real, st.policy)
+		case OverwriteBackup:
+			backups = append(backups, rel)
+		default:
+			return &UnknownOverwritePolicyErr{string(st.policy)}
+		}
+	}
+
+	backup, err := os.MkdirTemp(filepath.Dir(st.root), "."+filepath.Base(st.root)+".backup-*")
+	if err != nil {
+		return
+	}
+	var (
+		moved    []string // the files moved into the install directory
+		replaced []string // the files moved into the backup directory
+		created  []string // the directories created in the install directory
+	)
+	backupFile := func(rel string) error {
+		target := filepath.Join(st.root, rel)
+		if _, err := os.Lstat(target); err != nil {
+			return nil
+		}
+		dst := filepath.Join(backup, rel)
+		if err := os.MkdirAll(filepath.Dir(dst), 0755); err != nil {
+			return err
+		}
+		if err := os.Rename(target, dst); err != nil {
+			return err
+		}
+		replaced = append(replaced, rel)
+		return nil
+	}
+	for _, d := range st.deletes {
+		rel, _ := filepath.Rel(st.root, d)
+		if err = backupFile(rel); err != nil {
+			break
+		}
+	}
+	if err == nil {
+		err = filepath.WalkDir(st.dir, func(path string, d fs.DirEntry, err error) error {
+			if err != nil {
+				return err
+			}
+			rel, _ := filepath.Rel(st.dir, path)
+			if rel == "." {
+				return nil
+			}
+			target := filepath.Join(st.root, rel)
+			if d.IsDir() {
+				if info, err := os.Stat(target); err == nil && info.IsDir() {
+					return nil
+				}
+				if err := backupFile(rel); err != nil {
+					return err
+				}
+				mode := fs.FileMode(0755)
+				if info, err := d.Info(); err == nil {
+					mode = info.Mode().Perm()
+				}
+				if err := os.Mkdir(target, mode); err != nil {
+					return err
+				}
+				created = append(created, rel)
+				return nil
+			}
+			if err := backupFile(rel); err != nil {
+				return err
+			}
+			if err := os.Rename(path, target); err != nil {
+				return err
+			}
+			moved = append(moved, rel)
+			return nil
+		})
+	}
+	if err == nil {
+		for _, rel := range backups {
+			target := filepath.Join(st.root, rel)
+			bak := backupName(target)
+			if e := os.Rename(filepath.Join(backup, rel), bak); e != nil {
+				loger.Errorf("Couldn't back up %q: %v", target, e)
+				continue
+			}
+			loger.Infof("Backed up %q to %q (overwrite policy %q)", target, filepath.Base(bak), st.policy)
+		}
+		os.RemoveAll(backup)
+		return
+	}
+
+	loger.Errorf("Couldn't commit the install: %v, rolling back...", err)
+	rollbackOk := true
+	for i := len(moved) - 1; i >= 0; i-- {
+		rel := moved[i]
+		if e := os.Rename(filepath.Join(st.root, rel), filepath.Join(st.dir, rel)); e != nil {
+			rollbackOk = false
+		}
+	}
+	for i := len(created) - 1; i >= 0; i-- {
+		os.Remove(filepath.Join(st.root, created[i]))
+	}
+	for i := len(replaced) - 1; i >= 0; i-- {
+		rel := replaced[i]
+		if e := os.Rename(filepath.Join(backup, rel), filepath.Join(st.root, rel)); e != nil {
+			rollbackOk = false
+		}
+	}
+	if rollbackOk {
+		os.RemoveAll(backup)
+	} else {
+		loger.Errorf("Couldn't roll back all the files, the replaced files are kept in %q", backup)
+	}
+	return
+}
+
+// close removes the staging directory
+func (st *stage) close() {
+	if st.dir != "" {
+		os.RemoveAll(st.dir)
+		st.dir = ""
+	}
+}
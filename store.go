@@ -0,0 +1,256 @@
+package installer
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ArtifactStore is a local directory that keeps the http responses and the library sets used by the installers.
+//
+// The responses are saved at "http/<host>/<path>", and the files produced by installer jars
+// (e.g. the libraries downloaded by forge installer) are saved at "libraries/<key>/".
+type ArtifactStore struct {
+	Dir string
+	// Offline makes the http client serve everything from the store, and refuse network access
+	Offline bool
+	// Record makes the http client save every successful response into the store
+	Record bool
+}
+
+type StoreMissingErr struct {
+	Url  string
+	Path string
+}
+
+func (e *StoreMissingErr) Error() string {
+	if e.Url == "" {
+		return fmt.Sprintf("Offline mode: %q is absent in the store, please prefetch it first", e.Path)
+	}
+	return fmt.Sprintf("Offline mode: %q is not in the store (missing file %q), please prefetch it first", e.Url, e.Path)
+}
+
+func NewArtifactStore(dir string) *ArtifactStore {
+	return &ArtifactStore{Dir: dir}
+}
+
+// PathOf returns the path in the store that the response of the url is saved to,
+// NotLocalPathErr is returned if the url path escapes its host directory
+func (s *ArtifactStore) PathOf(u *url.URL) (string, error) {
+	p := strings.TrimPrefix(u.EscapedPath(), "/")
+	if p == "" || strings.HasSuffix(p, "/") {
+		p += "index"
+	}
+	if u.RawQuery != "" {
+		p += "@" + url.PathEscape(u.RawQuery)
+	}
+	// ':' is not allowed in windows file names
+	host := strings.ReplaceAll(u.Host, ":", "_")
+	rel := filepath.Join(host, filepath.FromSlash(path.Clean(p)))
+	if !filepath.IsLocal(rel) || !strings.HasPrefix(rel, host+string(filepath.Separator)) {
+		return "", &NotLocalPathErr{u.Path}
+	}
+	return filepath.Join(s.Dir, "http", rel), nil
+}
+
+// LibraryPath returns the directory of the library set
+func (s *ArtifactStore) LibraryPath(key string) string {
+	return filepath.Join(s.Dir, "libraries", filepath.FromSlash(key))
+}
+
+func (s *ArtifactStore) do(c *HTTPClient, req *http.Request) (res *http.Response, err error) {
+	if req.Method != http.MethodGet && req.Method != http.MethodHead {
+		if s.Offline {
+			return nil, fmt.Errorf("Offline mode: cannot send %s request to %q", req.Method, req.URL)
+		}
+		return c.Client.Do(req)
+	}
+	path, err := s.PathOf(req.URL)
+	if err != nil {
+		return
+	}
+	if !s.Offline {
+		if !s.Record {
+			return c.Client.Do(req)
+		}
+		if err = s.fetch(c, req, path); err != nil {
+			return
+		}
+	}
+	return s.serve(req, path)
+}
+
+// fetch downloads the response into the store
+func (s *ArtifactStore) fetch(c *HTTPClient, req *http.Request, path string) (err error) {
+	get := req.Clone(req.Context())
+	get.Method = http.MethodGet
+	var res *http.Response
+	if res, err = c.Client.Do(get); err != nil {
+		return
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		return &HttpStatusError{
+			Code: res.StatusCode,
+		}
+	}
+	if err = os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return
+	}
+	var fd *os.File
+	if fd, err = os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*.downloading"); err != nil {
+		return
+	}
+	_, err = io.Copy(fd, c.scheduler().Reader(req.Context(), res.Body))
+	if er := fd.Close(); err == nil {
+		err = er
+	}
+	if err == nil {
+		err = os.Rename(fd.Name(), path)
+	}
+	if err != nil {
+		os.Remove(fd.Name())
+	}
+	return
+}
+
+func (s *ArtifactStore) serve(req *http.Request, path string) (res *http.Response, err error) {
+	var fd *os.File
+	if fd, err = os.Open(path); err != nil {
+		if os.IsNotExist(err) {
+			err = &StoreMissingErr{Url: req.URL.String(), Path: path}
+		}
+		return
+	}
+	var stat fs.FileInfo
+	if stat, err = fd.Stat(); err != nil {
+		fd.Close()
+		return
+	}
+	res = &http.Response{
+		Status:        "200 OK",
+		StatusCode:    http.StatusOK,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        make(http.Header),
+		ContentLength: stat.Size(),
+		Request:       req,
+	}
+	res.Header.Set("Content-Length", strconv.FormatInt(stat.Size(), 10))
+	res.Header.Set("Last-Modified", stat.ModTime().UTC().Format(http.TimeFormat))
+	if req.Method == http.MethodHead {
+		fd.Close()
+		res.Body = io.NopCloser(bytes.NewReader(nil))
+	} else {
+		res.Body = fd
+	}
+	return
+}
+
+type fileStamp struct {
+	size    int64
+	modTime time.Time
+}
+
+func snapshotDir(dir string) (files map[string]fileStamp) {
+	files = make(map[string]fileStamp)
+	filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return nil
+		}
+		if info, err := d.Info(); err == nil {
+			rel, _ := filepath.Rel(dir, path)
+			files[rel] = fileStamp{info.Size(), info.ModTime()}
+		}
+		return nil
+	})
+	return
+}
+
+// SaveLibrarySet copies the files in dir that are not in before, or are changed since then, into the library set.
+// If include is not nil, it decides which files are copied instead
+func (s *ArtifactStore) SaveLibrarySet(key string, dir string, before map[string]fileStamp, include func(rel string) bool) (err error) {
+	target := s.LibraryPath(key)
+	if err = os.RemoveAll(target); err != nil {
+		return
+	}
+	after := snapshotDir(dir)
+	for rel, stamp := range after {
+		if include != nil {
+			if !include(filepath.ToSlash(rel)) {
+				continue
+			}
+		} else if old, ok := before[rel]; ok && old == stamp {
+			continue
+		}
+		dst := filepath.Join(target, rel)
+		if err = os.MkdirAll(filepath.Dir(dst), 0755); err != nil {
+			return
+		}
+		if err = osCopy(filepath.Join(dir, rel), dst, 0644); err != nil {
+			return
+		}
+	}
+	return
+}
+
+// RestoreLibrarySet copies the files of the library set into dir
+func (s *ArtifactStore) RestoreLibrarySet(key string, dir string) (err error) {
+	src := s.LibraryPath(key)
+	if _, err = os.Stat(src); err != nil {
+		if os.IsNotExist(err) {
+			err = &StoreMissingErr{Path: src}
+		}
+		return
+	}
+	return filepath.WalkDir(src, func(path string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+		rel, _ := filepath.Rel(src, path)
+		dst := filepath.Join(dir, rel)
+		if err = os.MkdirAll(filepath.Dir(dst), 0755); err != nil {
+			return err
+		}
+		mode := fs.FileMode(0644)
+		if info, err := d.Info(); err == nil {
+			mode = info.Mode().Perm()
+		}
+		return osCopy(path, dst, mode)
+	})
+}
+
+// Prefetch installs the server into a temporary directory while recording everything it downloads into the store,
+// so it can be installed with the store in offline mode later
+func Prefetch(store *ArtifactStore, serverType string, version string) (err error) {
+	ir, ok := Get(serverType)
+	if !ok {
+		return &UnsupportGameErr{serverType}
+	}
+	recorder := *store
+	recorder.Offline = false
+	recorder.Record = true
+	old := DefaultHTTPClient.Store
+	DefaultHTTPClient.Store = &recorder
+	defer func() {
+		DefaultHTTPClient.Store = old
+	}()
+
+	var tmpDir string
+	if tmpDir, err = os.MkdirTemp("", "server-installer-prefetch-*"); err != nil {
+		return
+	}
+	defer os.RemoveAll(tmpDir)
+	_, err = ir.Install(tmpDir, "minecraft", version)
+	return
+}
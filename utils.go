@@ -1,6 +1,7 @@
 package installer
 
 import (
+	"context"
 	"crypto/md5"
 	"crypto/sha1"
 	"crypto/sha256"
@@ -16,7 +17,7 @@ import (
 	"time"
 )
 
-var TargetAlreadyExistErr = errors.New("Target file already exists, please clean the install directory and retry")
+var TargetAlreadyExistErr = errors.New("Target file already exists, please clean the install directory or use another overwrite policy")
 var EmptyLinkArrayErr = errors.New("Link array is empty")
 
 type StringMap = map[string]string
@@ -42,18 +43,22 @@ func osCopy(src, dst string, mode os.FileMode) (err error) {
 	return
 }
 
+func targetExistErr(src, dst string) error {
+	return &os.LinkError{
+		Op:  "rename",
+		Old: src,
+		New: dst,
+		Err: TargetAlreadyExistErr,
+	}
+}
+
 func renameIfNotExist(src, dst string, mode os.FileMode) (err error) {
 	if _, e := os.Stat(dst); os.IsNotExist(e) {
 		if err = os.MkdirAll(filepath.Dir(dst), 0755); err != nil {
 			return
 		}
 	} else {
-		return &os.LinkError{
-			Op:  "rename",
-			Old: src,
-			New: dst,
-			Err: TargetAlreadyExistErr,
-		}
+		return targetExistErr(src, dst)
 	}
 	if err = os.Rename(src, dst); err != nil {
 		if crossDevice(err) {
@@ -87,6 +92,9 @@ func safeDownload(reader io.Reader, path string) (err error) {
 }
 
 func lookJavaPath() (string, error) {
+	if JavaPath != "" {
+		return exec.LookPath(JavaPath)
+	}
 	javahome := os.Getenv("JAVA_HOME")
 	if len(javahome) > 0 {
 		if path, err := exec.LookPath(filepath.Join(javahome, "bin", "java")); err == nil {
@@ -123,7 +131,6 @@ func checkHashStream(r io.Reader, hashes StringMap, w io.Writer) (n int64, err e
 		writers = append(writers, w)
 	}
 	if n, err = io.Copy(io.MultiWriter(writers...), r); err != nil {
-		fmt.Printf("err is not nil: %T", err)
 		return
 	}
 	for i, h := range hashers {
@@ -151,6 +158,11 @@ func matchHashes(path string, hashes StringMap) (ok bool) {
 }
 
 func downloadAnyAndCheckHashes(links []string, path string, hashes StringMap, size int64) (err error) {
+	return downloadAnyAndCheckHashesContext(context.Background(), links, path, hashes, size, nil, DefaultOverwritePolicy)
+}
+
+// downloadAnyAndCheckHashesContext downloads the file into a temporary file, and places it by the overwrite policy after the hashes are checked
+func downloadAnyAndCheckHashesContext(ctx context.Context, links []string, path string, hashes StringMap, size int64, hosts *hostLimiter, policy OverwritePolicy) (err error) {
 	if matchHashes(path, hashes) {
 		return
 	}
@@ -159,13 +171,22 @@ func downloadAnyAndCheckHashes(links []string, path string, hashes StringMap, si
 		return
 	}
 	for _, l := range links {
+		var release func()
+		if release, err = hosts.acquire(ctx, l); err != nil {
+			return
+		}
 		var tmp string
-		if tmp, err = DefaultHTTPClient.DownloadTmp(l, "*.downloading", 0644, hashes, size,
-			downloadingCallback(l)); err != nil {
+		tmp, err = DefaultHTTPClient.DownloadTmpContext(ctx, l, path+".*.downloading", 0644, hashes, size,
+			downloadingCallback(l))
+		release()
+		if err != nil {
+			if ctx.Err() != nil {
+				return
+			}
 			continue
 		}
 		defer os.Remove(tmp)
-		if err = renameIfNotExist(tmp, path, 0644); err != nil {
+		if err = placeFile(tmp, path, 0644, policy); err != nil {
 			return
 		}
 		break
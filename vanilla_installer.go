@@ -77,16 +77,41 @@ type (
 )
 
 var _ Installer = (*VanillaInstaller)(nil)
+var _ Planner = (*VanillaInstaller)(nil)
+var _ ResolvedPlanner = (*VanillaInstaller)(nil)
+var _ VersionSource = (*VanillaInstaller)(nil)
 
 var VanillaIns = &VanillaInstaller{
 	ManifestUrl: "https://launchermeta.mojang.com/mc/game/version_manifest.json",
 }
 
 func init() {
-	Installers["vanilla"] = VanillaIns
+	MustRegister(VanillaIns, InstallerInfo{
+		Name:         "vanilla",
+		DisplayName:  "Vanilla",
+		Description:  "Official minecraft server from mojang",
+		RequiresJava: false,
+	})
 }
 
 func (r *VanillaInstaller) Install(path, name string, target string) (installed string, err error) {
+	plan, err := r.Plan(path, name, target)
+	if err != nil {
+		return
+	}
+	return plan.Execute()
+}
+
+func (r *VanillaInstaller) Plan(path, name string, target string) (plan *InstallPlan, err error) {
+	var resolved ResolvedVersion
+	if resolved, err = resolveInstallTarget(r, target); err != nil {
+		return
+	}
+	return r.PlanResolved(path, name, resolved)
+}
+
+func (r *VanillaInstaller) PlanResolved(path, name string, resolved ResolvedVersion) (plan *InstallPlan, err error) {
+	target := resolved.Game
 	var res VanillaVersions
 	loger.Info("Getting minecraft version manifest...")
 	if res, err = r.GetVersions(); err != nil {
@@ -109,17 +134,20 @@ func (r *VanillaInstaller) Install(path, name string, target string) (installed
 			}
 			info, ok := version.Downloads["server"]
 			if !ok {
-				return "", &AssetNotFoundErr{foundVersion, "server.jar"}
+				return nil, &AssetNotFoundErr{foundVersion, "server.jar"}
 			}
-			installed = filepath.Join(path, name+".jar")
-			if err = DefaultHTTPClient.Download(info.Url, installed, 0644, nil, -1,
-				downloadingCallback(info.Url)); err != nil {
-				return
+			plan = newInstallPlan("vanilla", path)
+			plan.Game = target
+			plan.Installed = filepath.Join(path, name+".jar")
+			var hashes StringMap
+			if info.Sha1 != "" {
+				hashes = StringMap{"sha1": info.Sha1}
 			}
-			return installed, nil
+			plan.download(info.Url, plan.Installed, info.Size, hashes).Executable = true
+			return plan, nil
 		}
 	}
-	return "", &VersionNotFoundErr{foundVersion}
+	return nil, &VersionNotFoundErr{foundVersion}
 }
 
 func (r *VanillaInstaller) ListVersions(snapshot bool) (versions []string, err error) {
@@ -135,6 +163,23 @@ func (r *VanillaInstaller) ListVersions(snapshot bool) (versions []string, err e
 	return
 }
 
+func (r *VanillaInstaller) GameVersions() (versions []McVersion, err error) {
+	vs, err := r.GetVersions()
+	if err != nil {
+		return
+	}
+	versions = make([]McVersion, len(vs.Versions))
+	for i, v := range vs.Versions {
+		versions[i] = mcVersionOf(v.Id, v.Type == "release")
+		versions[i].ReleaseTime = v.ReleaseTime
+	}
+	return
+}
+
+func (r *VanillaInstaller) LoaderVersions(gameVersion string) ([]LoaderVersion, error) {
+	return nil, nil
+}
+
 func (r *VanillaInstaller) GetVersions() (res VanillaVersions, err error) {
 	if err = DefaultHTTPClient.GetJson(r.ManifestUrl, &res); err != nil {
 		return
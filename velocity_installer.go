@@ -0,0 +1,150 @@
+package installer
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+type (
+	// VelocityInstaller installs the velocity proxy, the versions are the velocity versions and the loaders are the builds
+	VelocityInstaller struct {
+		PaperMCProject
+	}
+)
+
+var DefaultVelocityInstaller = &VelocityInstaller{
+	PaperMCProject{
+		ApiUrl:  "https://fill.papermc.io/v3",
+		Project: "velocity",
+	},
+}
+var _ Installer = DefaultVelocityInstaller
+var _ Planner = DefaultVelocityInstaller
+var _ ResolvedPlanner = DefaultVelocityInstaller
+var _ ProxyPlanner = DefaultVelocityInstaller
+var _ VersionSource = DefaultVelocityInstaller
+
+func init() {
+	MustRegister(DefaultVelocityInstaller, InstallerInfo{
+		Name:         "velocity",
+		DisplayName:  "Velocity",
+		Description:  "Velocity proxy, the version is the velocity version such as 3.4.0-SNAPSHOT, and the loader is the build",
+		RequiresJava: false,
+	})
+}
+
+// VelocitySecretFile is the file of the modern forwarding secret, it's referred by velocity.toml
+const VelocitySecretFile = "forwarding.secret"
+
+var velocityForwardings = []string{ForwardingModern, ForwardingLegacy, ForwardingNone}
+
+func (r *VelocityInstaller) Install(path, name string, target string) (installed string, err error) {
+	plan, err := r.Plan(path, name, target)
+	if err != nil {
+		return
+	}
+	return plan.Execute()
+}
+
+// Plan plans the proxy with a starter config, see PlanProxy
+func (r *VelocityInstaller) Plan(path, name string, target string) (plan *InstallPlan, err error) {
+	var resolved ResolvedVersion
+	if resolved, err = resolveInstallTarget(r, target); err != nil {
+		return
+	}
+	return r.PlanResolved(path, name, resolved)
+}
+
+func (r *VelocityInstaller) PlanResolved(path, name string, version ResolvedVersion) (*InstallPlan, error) {
+	return r.PlanProxy(path, name, version, new(ProxyNetwork))
+}
+
+// PlanProxy plans velocity with velocity.toml listing the backends, and sets up the local backends for the forwarding.
+// The forwarding secret in the install directory is kept if the network doesn't have one,
+// and the existing velocity.toml is kept if the network doesn't have any backend
+func (r *VelocityInstaller) PlanProxy(path, name string, version ResolvedVersion, network *ProxyNetwork) (plan *InstallPlan, err error) {
+	if path, err = filepath.Abs(path); err != nil {
+		return
+	}
+	secretFile := filepath.Join(path, VelocitySecretFile)
+	if network.Secret == "" {
+		if data, err := os.ReadFile(secretFile); err == nil {
+			network.Secret = strings.TrimSpace(string(data))
+		}
+	}
+	if err = network.prepare("velocity", velocityForwardings); err != nil {
+		return
+	}
+	if plan, err = planPaperMCProject(&r.PaperMCProject, path, name, version); err != nil {
+		return
+	}
+	if network.planConfig(plan, filepath.Join(path, "velocity.toml"), network.velocityToml()) && network.Secret != "" {
+		plan.write(secretFile, network.Secret, 0600)
+	}
+	if err = network.planBackends(plan); err != nil {
+		return
+	}
+	return
+}
+
+func (r *VelocityInstaller) ListVersions(snapshot bool) (versions []string, err error) {
+	return gameVersionIds(r, snapshot)
+}
+
+// planPaperMCProject plans the download of the server jar, the loader of the resolved version is the build
+func planPaperMCProject(p *PaperMCProject, path, name string, resolved ResolvedVersion) (plan *InstallPlan, err error) {
+	version := resolved.Game
+	if version == "" || version == QueryLatest || version == QueryLatestSnapshot {
+		var versions []PaperMCVersion
+		loger.Infof("Getting %s versions...", p.Project)
+		if versions, err = p.GetVersions(); err != nil {
+			return
+		}
+		if len(versions) == 0 {
+			return nil, &VersionNotFoundErr{p.Project + "-" + resolved.String()}
+		}
+		version = versions[0].Version.Id
+	}
+	var build PaperMCBuild
+	if build, err = p.GetBuild(version, resolved.Loader); err != nil {
+		return
+	}
+	var dl PaperMCDownload
+	if dl, err = p.ServerDownload(version, build); err != nil {
+		return
+	}
+	loger.Infof("Planning %s %s build %d at %q...", p.Project, version, build.Id, dl.Url)
+	plan = newInstallPlan(p.Project, path)
+	plan.Game = version
+	plan.Loader = strconv.Itoa(build.Id)
+	plan.Installed = filepath.Join(path, name+".jar")
+	plan.download(dl.Url, plan.Installed, dl.Size, StringMap{"sha256": dl.Checksums.Sha256}).Executable = true
+	return
+}
+
+// velocityToml returns a starter velocity.toml, velocity fills the missing settings with the defaults
+func (n *ProxyNetwork) velocityToml() string {
+	var b strings.Builder
+	b.WriteString("# Generated by server-installer, see https://docs.papermc.io/velocity/configuration\n")
+	b.WriteString("config-version = \"2.7\"\n")
+	fmt.Fprintf(&b, "bind = %q\n", n.Bind)
+	b.WriteString("motd = \"<#09add3>A Velocity Server\"\n")
+	b.WriteString("show-max-players = 500\n")
+	b.WriteString("online-mode = true\n")
+	b.WriteString("force-key-authentication = true\n")
+	fmt.Fprintf(&b, "player-info-forwarding-mode = %q\n", n.Forwarding)
+	fmt.Fprintf(&b, "forwarding-secret-file = %q\n", VelocitySecretFile)
+	b.WriteString("\n[servers]\n")
+	try := make([]string, len(n.Backends))
+	for i, s := range n.Backends {
+		fmt.Fprintf(&b, "%q = %q\n", s.Name, s.Address)
+		try[i] = strconv.Quote(s.Name)
+	}
+	b.WriteString("# the servers that players are sent to when they join, or the server they are in is down\n")
+	fmt.Fprintf(&b, "try = [%s]\n", strings.Join(try, ", "))
+	b.WriteString("\n[forced-hosts]\n")
+	return b.String()
+}
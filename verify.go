@@ -0,0 +1,130 @@
+package installer
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+const (
+	VerifyOk        = "ok"
+	VerifyMissing   = "missing"
+	VerifyMismatch  = "mismatch"
+	VerifyUnchecked = "unchecked" // the file exists, but no hash is recorded
+)
+
+type (
+	VerifiedFile struct {
+		// Path is relative to the server directory, slash separated
+		Path string `json:"path"`
+		// Kind is one of [executable mod plugin modpack]
+		Kind    string `json:"kind"`
+		Status  string `json:"status"`
+		Message string `json:"message,omitempty"`
+	}
+
+	VerifyReport struct {
+		Dir   string         `json:"dir"`
+		Files []VerifiedFile `json:"files"`
+	}
+)
+
+// VerifyServer checks the files recorded in the install manifest of the server directory
+// are still there and have the recorded hashes
+func VerifyServer(dir string) (report *VerifyReport, err error) {
+	manifest, err := ReadInstallManifest(dir)
+	if err != nil {
+		return
+	}
+	report = &VerifyReport{Dir: dir}
+	seen := make(map[string]bool)
+	check := func(kind string, path string, hashes StringMap) {
+		path = filepath.ToSlash(filepath.Clean(filepath.FromSlash(path)))
+		if seen[path] {
+			return
+		}
+		seen[path] = true
+		report.Files = append(report.Files, verifyFile(dir, kind, path, hashes))
+	}
+	if manifest.Executable != "" {
+		check("executable", manifest.Executable, nil)
+	}
+	for _, f := range manifest.ModpackFiles {
+		if manifest.IsDisabled(f.Path) {
+			continue
+		}
+		check("modpack", f.Path, f.Hashes)
+	}
+	for _, e := range manifest.Mods {
+		check("mod", e.Path, e.Hashes)
+	}
+	for _, e := range manifest.Plugins {
+		check("plugin", e.Path, e.Hashes)
+	}
+	return
+}
+
+func verifyFile(dir string, kind string, path string, hashes StringMap) (f VerifiedFile) {
+	f = VerifiedFile{Path: path, Kind: kind}
+	fd, err := os.Open(filepath.Join(dir, filepath.FromSlash(path)))
+	if err != nil {
+		if os.IsNotExist(err) {
+			f.Status = VerifyMissing
+		} else {
+			f.Status, f.Message = VerifyMismatch, err.Error()
+		}
+		return
+	}
+	defer fd.Close()
+	if len(hashes) == 0 {
+		f.Status = VerifyUnchecked
+		return
+	}
+	if _, err = checkHashStream(fd, hashes, nil); err != nil {
+		f.Status, f.Message = VerifyMismatch, err.Error()
+		return
+	}
+	f.Status = VerifyOk
+	return
+}
+
+// FailedCount returns the count of the missing and mismatched files
+func (r *VerifyReport) FailedCount() (n int) {
+	for _, f := range r.Files {
+		if f.Status == VerifyMissing || f.Status == VerifyMismatch {
+			n++
+		}
+	}
+	return
+}
+
+// WriteText writes the human readable report
+func (r *VerifyReport) WriteText(w io.Writer) (err error) {
+	var b strings.Builder
+	fmt.Fprintf(&b, "Verified %d file(s) in %q\n", len(r.Files), r.Dir)
+	unchecked := 0
+	for _, f := range r.Files {
+		switch f.Status {
+		case VerifyMissing, VerifyMismatch:
+			fmt.Fprintf(&b, "  [%s] %s %s", strings.ToUpper(f.Status), f.Kind, f.Path)
+			if f.Message != "" {
+				fmt.Fprintf(&b, ": %s", f.Message)
+			}
+			b.WriteByte('\n')
+		case VerifyUnchecked:
+			unchecked++
+		}
+	}
+	if failed := r.FailedCount(); failed == 0 {
+		b.WriteString("No problems found\n")
+	} else {
+		fmt.Fprintf(&b, "Found %d missing or changed file(s)\n", failed)
+	}
+	if unchecked > 0 {
+		fmt.Fprintf(&b, "%d file(s) exist but have no recorded hash\n", unchecked)
+	}
+	_, err = io.WriteString(w, b.String())
+	return
+}
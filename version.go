@@ -1,47 +1,416 @@
 package installer
 
 import (
+	"fmt"
+	"regexp"
 	"strconv"
 	"strings"
+	"time"
 )
 
+// Version is a semver-like version, such as forge's "47.1.0" or quilt installer's "0.9.2-beta.1".
+// Numeric components after the patch (e.g. "14.23.5.2860") are kept in Build
 type Version struct {
 	Major int
 	Minor int
 	Patch int
+	Pre   string
+	Build string
+}
+
+type VersionFormatErr struct {
+	Version string
+}
+
+func (e *VersionFormatErr) Error() string {
+	return fmt.Sprintf("Unexpected version format %q", e.Version)
 }
 
 func VersionFromString(data string) (v Version, err error) {
-	v.Major = 0
-	v.Minor = 0
-	v.Patch = 0
-	var i int
-	if i = strings.IndexByte(data, '.'); i < 0 {
-		v.Major, err = strconv.Atoi(data)
-		return
+	raw := data
+	if i := strings.IndexByte(data, '+'); i >= 0 {
+		v.Build = data[i+1:]
+		data = data[:i]
 	}
-	if v.Major, err = strconv.Atoi(data[:i]); err != nil {
-		return
+	if i := strings.IndexByte(data, '-'); i >= 0 {
+		v.Pre = data[i+1:]
+		data = data[:i]
 	}
-	data = data[i+1:]
-	if i = strings.IndexByte(data, '.'); i < 0 {
-		v.Minor, err = strconv.Atoi(data)
-		return
+	parts := strings.Split(data, ".")
+	nums := make([]int, len(parts))
+	for i, p := range parts {
+		if nums[i], err = strconv.Atoi(p); err != nil {
+			return Version{}, &VersionFormatErr{raw}
+		}
 	}
-	if v.Minor, err = strconv.Atoi(data[:i]); err != nil {
-		return
+	v.Major = nums[0]
+	if len(nums) > 1 {
+		v.Minor = nums[1]
 	}
-	if v.Patch, err = strconv.Atoi(data[i+1:]); err != nil {
-		return
+	if len(nums) > 2 {
+		v.Patch = nums[2]
+	}
+	if len(nums) > 3 {
+		extra := strings.Join(parts[3:], ".")
+		if v.Build != "" {
+			extra += "+" + v.Build
+		}
+		v.Build = extra
 	}
 	return
 }
 
 func (v Version) String() (s string) {
 	s = strconv.Itoa(v.Major) + "." + strconv.Itoa(v.Minor) + "." + strconv.Itoa(v.Patch)
+	if v.Pre != "" {
+		s += "-" + v.Pre
+	}
+	if v.Build != "" {
+		s += "+" + v.Build
+	}
 	return
 }
 
+func compareInt(a, b int) int {
+	if a < b {
+		return -1
+	}
+	if a > b {
+		return 1
+	}
+	return 0
+}
+
+// Compare returns -1 if v < o, 1 if v > o, and 0 if they are equal.
+// A version with pre-release is less than the same version without it,
+// and Build is only used to break the tie.
+func (v Version) Compare(o Version) int {
+	if c := compareInt(v.Major, o.Major); c != 0 {
+		return c
+	}
+	if c := compareInt(v.Minor, o.Minor); c != 0 {
+		return c
+	}
+	if c := compareInt(v.Patch, o.Patch); c != 0 {
+		return c
+	}
+	if c := comparePreRelease(v.Pre, o.Pre); c != 0 {
+		return c
+	}
+	return compareVersionStrings(v.Build, o.Build)
+}
+
 func (v Version) Less(o Version) bool {
-	return v.Major < o.Major || v.Minor < o.Minor || v.Patch < o.Patch
+	return v.Compare(o) < 0
+}
+
+const (
+	McRelease          = "release"
+	McPreRelease       = "pre-release"
+	McReleaseCandidate = "release-candidate"
+	McSnapshot         = "snapshot"
+	McAprilFools       = "april-fools"
+	McOldBeta          = "old_beta"
+	McOldAlpha         = "old_alpha"
+)
+
+// McVersion is a minecraft version id, such as "1.20.1", "1.20-pre1", "1.20.2-rc1", "23w14a" or "b1.7.3"
+type McVersion struct {
+	Id   string
+	Type string
+
+	// Major, Minor and Patch are the numbers of the release, pre-release or release candidate.
+	// For snapshots, they are the target release if known (e.g. fabric's "1.20-alpha.23.14.a")
+	Major int
+	Minor int
+	Patch int
+	// Pre is the number of the pre-release or the release candidate
+	Pre int
+
+	// Year, Week and Letter are the parts of snapshot ids such as "23w14a"
+	Year   int
+	Week   int
+	Letter string
+
+	// ReleaseTime is the time in the version manifest, it's zero if unknown
+	ReleaseTime time.Time
+}
+
+// mcAprilFools are the ids of april fools versions, some of them look like normal snapshots
+var mcAprilFools = map[string]bool{
+	"15w14a":               true,
+	"1.RV-Pre1":            true,
+	"3D Shareware v1.34":   true,
+	"20w14infinite":        true,
+	"22w13oneblockatatime": true,
+	"23w13a_or_b":          true,
+	"24w14potato":          true,
+	"25w14craftmine":       true,
+	"2.0":                  true,
+}
+
+// mcSnapshotTargets are the weekly snapshot series and the releases they lead to,
+// from and to are the first and the last snapshot as year*100+week
+var mcSnapshotTargets = []struct {
+	from, to            int
+	major, minor, patch int
+}{
+	{1402, 1434, 1, 8, 0},
+	{1531, 1607, 1, 9, 0},
+	{1620, 1621, 1, 10, 0},
+	{1632, 1644, 1, 11, 0},
+	{1706, 1718, 1, 12, 0},
+	{1731, 1822, 1, 13, 0},
+	{1830, 1833, 1, 13, 1},
+	{1843, 1914, 1, 14, 0},
+	{1934, 1946, 1, 15, 0},
+	{2006, 2022, 1, 16, 0},
+	{2027, 2030, 1, 16, 2},
+	{2045, 2120, 1, 17, 0},
+	{2137, 2144, 1, 18, 0},
+	{2203, 2203, 1, 18, 2},
+	{2211, 2219, 1, 19, 0},
+	{2224, 2224, 1, 19, 1},
+	{2242, 2246, 1, 19, 3},
+	{2303, 2307, 1, 19, 4},
+	{2312, 2318, 1, 20, 0},
+	{2331, 2335, 1, 20, 2},
+	{2340, 2346, 1, 20, 3},
+	{2351, 2414, 1, 20, 5},
+	{2418, 2421, 1, 21, 0},
+	{2433, 2440, 1, 21, 2},
+	{2444, 2446, 1, 21, 4},
+	{2502, 2510, 1, 21, 5},
+	{2515, 2521, 1, 21, 6},
+	{2531, 2537, 1, 21, 9},
+}
+
+// setSnapshotTarget fills the target release of the weekly snapshot if it's known
+func (v *McVersion) setSnapshotTarget() {
+	week := v.Year*100 + v.Week
+	for _, t := range mcSnapshotTargets {
+		if t.from <= week && week <= t.to {
+			v.Major, v.Minor, v.Patch = t.major, t.minor, t.patch
+			return
+		}
+	}
+}
+
+var (
+	mcReleaseRe     = regexp.MustCompile(`^(\d+)\.(\d+)(?:\.(\d+))?$`)
+	mcPreReleaseRe  = regexp.MustCompile(`^(\d+)\.(\d+)(?:\.(\d+))?(?:-pre| Pre-Release |-beta\.)(\d+)$`)
+	mcRcRe          = regexp.MustCompile(`^(\d+)\.(\d+)(?:\.(\d+))?-rc\.?(\d+)$`)
+	mcSnapshotRe    = regexp.MustCompile(`^(\d\d)w(\d\d)([a-z~])$`)
+	mcNewSnapshotRe = regexp.MustCompile(`^(\d+)\.(\d+)(?:\.(\d+))?-snapshot-(\d+)$`)
+	// fabric normalizes snapshots to semver, e.g. "1.20-alpha.23.14.a"
+	mcFabricSnapshotRe = regexp.MustCompile(`^(\d+)\.(\d+)(?:\.(\d+))?-alpha\.(\d\d)\.(\d\d)\.([a-z~])$`)
+	mcOldRe            = regexp.MustCompile(`^([ab])(\d+)\.(\d+)(?:\.(\d+))?`)
+)
+
+func atoiOr0(s string) int {
+	n, _ := strconv.Atoi(s)
+	return n
+}
+
+// ParseMcVersion parses minecraft version ids
+func ParseMcVersion(id string) (v McVersion, err error) {
+	v.Id = id
+	if mcAprilFools[id] {
+		v.Type = McAprilFools
+		return
+	}
+	if m := mcReleaseRe.FindStringSubmatch(id); m != nil {
+		v.Type = McRelease
+		v.Major, v.Minor, v.Patch = atoiOr0(m[1]), atoiOr0(m[2]), atoiOr0(m[3])
+		return
+	}
+	if m := mcPreReleaseRe.FindStringSubmatch(id); m != nil {
+		v.Type = McPreRelease
+		v.Major, v.Minor, v.Patch, v.Pre = atoiOr0(m[1]), atoiOr0(m[2]), atoiOr0(m[3]), atoiOr0(m[4])
+		return
+	}
+	if m := mcRcRe.FindStringSubmatch(id); m != nil {
+		v.Type = McReleaseCandidate
+		v.Major, v.Minor, v.Patch, v.Pre = atoiOr0(m[1]), atoiOr0(m[2]), atoiOr0(m[3]), atoiOr0(m[4])
+		return
+	}
+	if m := mcSnapshotRe.FindStringSubmatch(id); m != nil {
+		v.Type = McSnapshot
+		v.Year, v.Week, v.Letter = atoiOr0(m[1]), atoiOr0(m[2]), m[3]
+		v.setSnapshotTarget()
+		return
+	}
+	if m := mcNewSnapshotRe.FindStringSubmatch(id); m != nil {
+		v.Type = McSnapshot
+		v.Major, v.Minor, v.Patch, v.Pre = atoiOr0(m[1]), atoiOr0(m[2]), atoiOr0(m[3]), atoiOr0(m[4])
+		return
+	}
+	if m := mcFabricSnapshotRe.FindStringSubmatch(id); m != nil {
+		v.Type = McSnapshot
+		v.Major, v.Minor, v.Patch = atoiOr0(m[1]), atoiOr0(m[2]), atoiOr0(m[3])
+		v.Year, v.Week, v.Letter = atoiOr0(m[4]), atoiOr0(m[5]), m[6]
+		return
+	}
+	if m := mcOldRe.FindStringSubmatch(id); m != nil {
+		v.Type = McOldBeta
+		if m[1] == "a" {
+			v.Type = McOldAlpha
+		}
+		v.Major, v.Minor, v.Patch = atoiOr0(m[2]), atoiOr0(m[3]), atoiOr0(m[4])
+		return
+	}
+	return McVersion{}, &VersionFormatErr{id}
+}
+
+func (v McVersion) String() string {
+	return v.Id
+}
+
+// IsStable reports whether the version is a release
+func (v McVersion) IsStable() bool {
+	return v.Type == McRelease
+}
+
+// hasTarget reports whether the release numbers of the version are known
+func (v McVersion) hasTarget() bool {
+	switch v.Type {
+	case McRelease, McPreRelease, McReleaseCandidate:
+		return true
+	case McSnapshot:
+		return v.Major != 0 || v.Minor != 0
+	}
+	return false
+}
+
+func mcTypeRank(t string) int {
+	switch t {
+	case McOldAlpha:
+		return -2
+	case McOldBeta:
+		return -1
+	case McSnapshot:
+		return 0
+	case McPreRelease:
+		return 1
+	case McReleaseCandidate:
+		return 2
+	}
+	return 3
+}
+
+func (v McVersion) isOld() bool {
+	return v.Type == McOldAlpha || v.Type == McOldBeta
+}
+
+// Comparable reports whether the order of the two versions is known.
+// Snapshots whose target release is unknown and april fools versions can only be ordered with
+// the other versions by their release times
+func (v McVersion) Comparable(o McVersion) bool {
+	if v.Id == o.Id || !v.ReleaseTime.IsZero() && !o.ReleaseTime.IsZero() {
+		return true
+	}
+	if v.isOld() || o.isOld() {
+		return true
+	}
+	if v.hasTarget() && o.hasTarget() {
+		return true
+	}
+	return v.Type == McSnapshot && o.Type == McSnapshot && v.Year != 0 && o.Year != 0
+}
+
+// Compare orders two minecraft versions.
+// If both release times are known, they are used. Otherwise versions are ordered by their numbers,
+// snapshots come before pre-releases, which come before release candidates of the same release.
+// 0 is returned if the versions are not Comparable
+func (v McVersion) Compare(o McVersion) int {
+	if !v.ReleaseTime.IsZero() && !o.ReleaseTime.IsZero() {
+		if v.ReleaseTime.Before(o.ReleaseTime) {
+			return -1
+		}
+		if v.ReleaseTime.After(o.ReleaseTime) {
+			return 1
+		}
+		return 0
+	}
+	if vOld, oOld := v.isOld(), o.isOld(); vOld || oOld {
+		if vOld != oOld {
+			if vOld {
+				return -1
+			}
+			return 1
+		}
+		if c := compareInt(mcTypeRank(v.Type), mcTypeRank(o.Type)); c != 0 {
+			return c
+		}
+		return v.compareNumbers(o)
+	}
+	if v.hasTarget() && o.hasTarget() {
+		if c := v.compareNumbers(o); c != 0 {
+			return c
+		}
+		if c := compareInt(mcTypeRank(v.Type), mcTypeRank(o.Type)); c != 0 {
+			return c
+		}
+		if v.Type != McSnapshot || v.Year == 0 || o.Year == 0 {
+			return compareInt(v.Pre, o.Pre)
+		}
+	}
+	if v.Type == McSnapshot && o.Type == McSnapshot && v.Year != 0 && o.Year != 0 {
+		if c := compareInt(v.Year, o.Year); c != 0 {
+			return c
+		}
+		if c := compareInt(v.Week, o.Week); c != 0 {
+			return c
+		}
+		return strings.Compare(v.Letter, o.Letter)
+	}
+	return 0
+}
+
+func (v McVersion) compareNumbers(o McVersion) int {
+	if c := compareInt(v.Major, o.Major); c != 0 {
+		return c
+	}
+	if c := compareInt(v.Minor, o.Minor); c != 0 {
+		return c
+	}
+	return compareInt(v.Patch, o.Patch)
+}
+
+func (v McVersion) Less(o McVersion) bool {
+	return v.Compare(o) < 0
+}
+
+// CompareMcVersionStrings compares two minecraft version ids,
+// the ids that cannot be parsed are compared as loose semver
+func CompareMcVersionStrings(a, b string) int {
+	va, ea := ParseMcVersion(a)
+	vb, eb := ParseMcVersion(b)
+	if ea != nil || eb != nil {
+		return compareVersionStrings(a, b)
+	}
+	return va.Compare(vb)
+}
+
+// McVersion parses the id and fills the type and release time from the manifest if the id is listed
+func (vs VanillaVersions) McVersion(id string) (v McVersion, err error) {
+	if v, err = ParseMcVersion(id); err != nil {
+		for _, info := range vs.Versions {
+			if info.Id == id {
+				// unknown formats are accepted if the manifest contains them
+				v, err = McVersion{Id: id, Type: McAprilFools}, nil
+				break
+			}
+		}
+		if err != nil {
+			return
+		}
+	}
+	for _, info := range vs.Versions {
+		if info.Id == id {
+			v.ReleaseTime = info.ReleaseTime
+			break
+		}
+	}
+	return
 }
@@ -0,0 +1,187 @@
+package installer
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"text/tabwriter"
+	"time"
+)
+
+type (
+	// VersionEntry is a row of the version listing.
+	// If the loader versions are listed, there is a row for each loader version of the minecraft version
+	VersionEntry struct {
+		Game        string     `json:"game"`
+		Loader      string     `json:"loader,omitempty"`
+		Type        string     `json:"type"`
+		Stable      bool       `json:"stable"`
+		Recommended bool       `json:"recommended,omitempty"`
+		ReleaseTime *time.Time `json:"releaseTime,omitempty"`
+	}
+
+	VersionFilter struct {
+		// Game filters the minecraft versions, it could be a range such as "1.20.x" or ">=1.19 <1.21",
+		// other values are used as prefix, e.g. "1.20" matches "1.20", "1.20.1" ...
+		// Empty means all versions
+		Game string
+		// Snapshot includes snapshots, pre-releases and other unstable minecraft versions
+		Snapshot bool
+		// Loaders lists the loader versions of each minecraft version.
+		// If Game is empty, only the loaders of the newest minecraft version are listed
+		Loaders bool
+		// StableLoaders excludes the unstable loader versions
+		StableLoaders bool
+		// Recommended only keeps the recommended loader versions
+		Recommended bool
+		// Limit is the max count of the entries, zero means no limit
+		Limit int
+	}
+)
+
+// ListVersionEntries lists the versions that the installer can install.
+// Installers that don't implement VersionSource fall back to ListVersions
+func ListVersionEntries(ir Installer, filter VersionFilter) (entries []VersionEntry, err error) {
+	entries = []VersionEntry{}
+	source, ok := ir.(VersionSource)
+	if !ok {
+		var versions []string
+		if versions, err = ir.ListVersions(filter.Snapshot); err != nil {
+			return
+		}
+		for _, v := range versions {
+			mv := mcVersionOf(v, true)
+			entries = append(entries, VersionEntry{Game: v, Type: mv.Type, Stable: mv.IsStable()})
+			if filter.Limit > 0 && len(entries) >= filter.Limit {
+				break
+			}
+		}
+		return
+	}
+	var games []McVersion
+	if games, err = filterGameVersions(source, filter); err != nil {
+		return
+	}
+	if filter.Loaders && filter.Game == "" && len(games) > 1 {
+		games = games[:1]
+	}
+	full := func() bool {
+		return filter.Limit > 0 && len(entries) >= filter.Limit
+	}
+	for _, g := range games {
+		if full() {
+			break
+		}
+		entry := VersionEntry{
+			Game:   g.Id,
+			Type:   g.Type,
+			Stable: g.IsStable(),
+		}
+		if !g.ReleaseTime.IsZero() {
+			t := g.ReleaseTime
+			entry.ReleaseTime = &t
+		}
+		if !filter.Loaders {
+			entries = append(entries, entry)
+			continue
+		}
+		var loaders []LoaderVersion
+		if loaders, err = source.LoaderVersions(g.Id); err != nil {
+			return
+		}
+		if loaders == nil { // the installer doesn't have a loader
+			entries = append(entries, entry)
+			continue
+		}
+		for _, l := range loaders {
+			if (filter.StableLoaders && !l.Stable) || (filter.Recommended && !l.Recommended) {
+				continue
+			}
+			e := entry
+			e.Loader = l.Version
+			e.Stable = entry.Stable && l.Stable
+			e.Recommended = l.Recommended
+			entries = append(entries, e)
+			if full() {
+				break
+			}
+		}
+	}
+	return
+}
+
+func filterGameVersions(source VersionSource, filter VersionFilter) (games []McVersion, err error) {
+	var versions []McVersion
+	if versions, err = source.GameVersions(); err != nil {
+		return
+	}
+	rg := AnyVersion
+	if filter.Game != "" {
+		query := filter.Game
+		if !isRangeQuery(query) {
+			query += ".x"
+		}
+		if rg, err = ParseSemverRange(query); err != nil {
+			return
+		}
+	}
+	for _, v := range versions {
+		if (filter.Snapshot || v.IsStable()) && rg.MatchMc(v.Id) {
+			games = append(games, v)
+		}
+	}
+	return
+}
+
+// gameVersionIds returns the ids of the minecraft versions that the source provides
+func gameVersionIds(source VersionSource, snapshot bool) (versions []string, err error) {
+	games, err := filterGameVersions(source, VersionFilter{Snapshot: snapshot})
+	if err != nil {
+		return
+	}
+	versions = make([]string, len(games))
+	for i, g := range games {
+		versions[i] = g.Id
+	}
+	return
+}
+
+// WriteVersionTable writes the entries as a human readable table
+func WriteVersionTable(w io.Writer, entries []VersionEntry) (err error) {
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	hasLoader := false
+	for _, e := range entries {
+		if e.Loader != "" {
+			hasLoader = true
+			break
+		}
+	}
+	if hasLoader {
+		fmt.Fprintln(tw, "GAME\tLOADER\tTYPE\tFLAGS\tRELEASED")
+	} else {
+		fmt.Fprintln(tw, "GAME\tTYPE\tFLAGS\tRELEASED")
+	}
+	for _, e := range entries {
+		var flags []string
+		if e.Stable {
+			flags = append(flags, "stable")
+		}
+		if e.Recommended {
+			flags = append(flags, "recommended")
+		}
+		released := "-"
+		if e.ReleaseTime != nil {
+			released = e.ReleaseTime.Format("2006-01-02")
+		}
+		flag := strings.Join(flags, ",")
+		if flag == "" {
+			flag = "-"
+		}
+		if hasLoader {
+			fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\n", e.Game, e.Loader, e.Type, flag, released)
+		} else {
+			fmt.Fprintf(tw, "%s\t%s\t%s\t%s\n", e.Game, e.Type, flag, released)
+		}
+	}
+	return tw.Flush()
+}
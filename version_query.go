@@ -0,0 +1,224 @@
+package installer
+
+import (
+	"strings"
+)
+
+type (
+	LoaderVersion struct {
+		Version     string `json:"version"`
+		Stable      bool   `json:"stable"`
+		Recommended bool   `json:"recommended"`
+	}
+
+	// VersionSource provides the versions that an installer can install
+	VersionSource interface {
+		// GameVersions returns the minecraft versions that can be installed, newest first
+		GameVersions() ([]McVersion, error)
+		// LoaderVersions returns the loader versions for the minecraft version, newest first.
+		// Installers without loaders return nil
+		LoaderVersions(gameVersion string) ([]LoaderVersion, error)
+	}
+
+	// ResolvedVersion is the result of a version query
+	ResolvedVersion struct {
+		Game string `json:"game"`
+		// Loader is empty if the installer doesn't have a loader or the installer's default loader should be used
+		Loader string `json:"loader,omitempty"`
+	}
+)
+
+const (
+	QueryLatest             = "latest"
+	QueryLatestSnapshot     = "latest-snapshot"
+	QuerySnapshot           = "snapshot"
+	QueryLatestStableLoader = "latest-stable-loader"
+	QueryRecommended        = "recommended"
+)
+
+// mcVersionOf parses the id, ids in unknown formats are treated as unstable versions
+func mcVersionOf(id string, stable bool) McVersion {
+	v, err := ParseMcVersion(id)
+	if err != nil {
+		v = McVersion{Id: id, Type: McAprilFools}
+		if stable {
+			v.Type = McRelease
+		}
+	}
+	return v
+}
+
+func isLoaderSelector(s string) bool {
+	return s == QueryLatestStableLoader || s == QueryRecommended
+}
+
+func isRangeQuery(s string) bool {
+	return strings.ContainsAny(s, "<>=~^*| ") || strings.HasSuffix(s, ".x") || strings.HasSuffix(s, ".X")
+}
+
+// ResolveVersion resolves the version query with the version source.
+//
+// The query is in the format of "[<loader>@]<game>" or "<loader>", where <game> could be
+//   - "" or "latest": the newest release
+//   - "latest-snapshot" or "snapshot": the newest version, including snapshots
+//   - an exact minecraft version, such as "1.20.1"
+//   - a range, such as "1.20.x", ">=1.19 <1.21" or "~1.20", the newest release in the range will be picked
+//
+// When <loader> is a selector, an exact <game> is used as a prefix, e.g. "latest@1.20" means the newest loader
+// for the newest 1.20.x release. With an exact loader, such as "0.15.0@1.20", the exact <game> is used.
+//
+// and <loader> could be
+//   - "latest": the newest loader
+//   - "latest-stable-loader": the newest stable loader
+//   - "recommended": the recommended loader, or the newest stable loader if there is no recommendation
+//   - an exact loader version
+func ResolveVersion(source VersionSource, query string) (res ResolvedVersion, err error) {
+	loaderSel, gameSel := "", query
+	if i := strings.LastIndexByte(query, '@'); i >= 0 {
+		loaderSel, gameSel = query[:i], query[i+1:]
+		switch gameSel {
+		case "", QueryLatest, QueryLatestSnapshot, QuerySnapshot:
+		default:
+			if (loaderSel == QueryLatest || isLoaderSelector(loaderSel)) && !isRangeQuery(gameSel) {
+				gameSel += ".x"
+			}
+		}
+	} else if isLoaderSelector(query) {
+		loaderSel, gameSel = query, QueryLatest
+	}
+	if res.Game, err = resolveGameVersion(source, gameSel); err != nil {
+		return
+	}
+	if loaderSel == "" {
+		return
+	}
+	if res.Loader, err = resolveLoaderVersion(source, res.Game, loaderSel); err != nil {
+		return
+	}
+	return
+}
+
+func resolveGameVersion(source VersionSource, sel string) (version string, err error) {
+	var versions []McVersion
+	if versions, err = source.GameVersions(); err != nil {
+		return
+	}
+	switch sel {
+	case "", QueryLatest:
+		for _, v := range versions {
+			if v.IsStable() {
+				return v.Id, nil
+			}
+		}
+	case QueryLatestSnapshot, QuerySnapshot:
+		if len(versions) > 0 {
+			return versions[0].Id, nil
+		}
+	default:
+		if !isRangeQuery(sel) {
+			for _, v := range versions {
+				if v.Id == sel {
+					return v.Id, nil
+				}
+			}
+			break
+		}
+		var rg VersionRange
+		if rg, err = ParseSemverRange(sel); err != nil {
+			return
+		}
+		var unstable string
+		for _, v := range versions {
+			if !rg.MatchMc(v.Id) {
+				continue
+			}
+			if v.IsStable() {
+				return v.Id, nil
+			}
+			if unstable == "" {
+				unstable = v.Id
+			}
+		}
+		if unstable != "" {
+			return unstable, nil
+		}
+	}
+	return "", &VersionNotFoundErr{sel}
+}
+
+func resolveLoaderVersion(source VersionSource, game string, sel string) (version string, err error) {
+	var loaders []LoaderVersion
+	if loaders, err = source.LoaderVersions(game); err != nil {
+		return
+	}
+	if loaders == nil {
+		return "", nil
+	}
+	switch sel {
+	case QueryLatest:
+		if len(loaders) > 0 {
+			return loaders[0].Version, nil
+		}
+	case QueryLatestStableLoader, QueryRecommended:
+		if sel == QueryRecommended {
+			for _, l := range loaders {
+				if l.Recommended {
+					return l.Version, nil
+				}
+			}
+		}
+		for _, l := range loaders {
+			if l.Stable {
+				return l.Version, nil
+			}
+		}
+	default:
+		for _, l := range loaders {
+			if l.Version == sel {
+				return sel, nil
+			}
+		}
+	}
+	return "", &VersionNotFoundErr{sel + "@" + game}
+}
+
+// ResolveInstallTarget resolves the version query for the installer.
+// Installers that don't implement VersionSource only accept exact versions and the legacy targets
+func ResolveInstallTarget(ir Installer, target string) (res ResolvedVersion, err error) {
+	source, ok := ir.(VersionSource)
+	if !ok {
+		return ResolvedVersion{Game: target}, nil
+	}
+	return resolveInstallTarget(source, target)
+}
+
+// String returns the version in the query format, which resolves to itself
+func (v ResolvedVersion) String() string {
+	if v.Loader == "" {
+		return v.Game
+	}
+	return v.Loader + "@" + v.Game
+}
+
+// resolveInstallTarget resolves the target passed to Installer.Install.
+// Exact versions and the legacy targets "" "latest" and "latest-snapshot" are kept as is,
+// so installers can handle them as before
+func resolveInstallTarget(source VersionSource, target string) (res ResolvedVersion, err error) {
+	if target == "" || target == QueryLatest || target == QueryLatestSnapshot ||
+		(!strings.Contains(target, "@") && !isLoaderSelector(target) && !isRangeQuery(target) && target != QuerySnapshot) {
+		return ResolvedVersion{Game: target}, nil
+	}
+	loger.Infof("Resolving version %q...", target)
+	if res, err = ResolveVersion(source, target); err != nil {
+		return
+	}
+	loger.Infof("Resolved version %q to %s", target, res.Game+optionalSuffix("@", res.Loader))
+	return
+}
+
+func optionalSuffix(sep string, s string) string {
+	if s == "" {
+		return ""
+	}
+	return sep + s
+}
@@ -0,0 +1,310 @@
+package installer
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// compareVersionStrings compares two loosely semver-like versions, such as "1.20.1", "0.14.21+build.3" or "47.1.0-beta.2".
+// Missing numeric components are treated as zero, and a version with pre-release is less than the same version without it.
+func compareVersionStrings(a, b string) int {
+	an, apre := splitLooseVersion(a)
+	bn, bpre := splitLooseVersion(b)
+	if an == nil || bn == nil {
+		return strings.Compare(a, b)
+	}
+	for i := 0; i < len(an) || i < len(bn); i++ {
+		var x, y int
+		if i < len(an) {
+			x = an[i]
+		}
+		if i < len(bn) {
+			y = bn[i]
+		}
+		if x != y {
+			if x < y {
+				return -1
+			}
+			return 1
+		}
+	}
+	return comparePreRelease(apre, bpre)
+}
+
+func splitLooseVersion(v string) (nums []int, pre string) {
+	if i := strings.IndexByte(v, '+'); i >= 0 {
+		v = v[:i]
+	}
+	end := 0
+	for end < len(v) && (v[end] == '.' || ('0' <= v[end] && v[end] <= '9')) {
+		end++
+	}
+	core := strings.TrimRight(v[:end], ".")
+	if core == "" {
+		return nil, ""
+	}
+	for _, s := range strings.Split(core, ".") {
+		n, err := strconv.Atoi(s)
+		if err != nil {
+			return nil, ""
+		}
+		nums = append(nums, n)
+	}
+	pre = strings.TrimLeft(v[end:], "-.")
+	return
+}
+
+func comparePreRelease(a, b string) int {
+	if a == b {
+		return 0
+	}
+	if a == "" {
+		return 1
+	}
+	if b == "" {
+		return -1
+	}
+	as, bs := strings.Split(a, "."), strings.Split(b, ".")
+	for i := 0; i < len(as) && i < len(bs); i++ {
+		x, xe := strconv.Atoi(as[i])
+		y, ye := strconv.Atoi(bs[i])
+		switch {
+		case xe == nil && ye == nil:
+			if x != y {
+				if x < y {
+					return -1
+				}
+				return 1
+			}
+		case xe == nil:
+			return -1
+		case ye == nil:
+			return 1
+		default:
+			if c := strings.Compare(as[i], bs[i]); c != 0 {
+				return c
+			}
+		}
+	}
+	return len(as) - len(bs)
+}
+
+type versionPredicate struct {
+	op      string // one of "=", ">", ">=", "<", "<=", "prefix"
+	version string
+}
+
+func (p versionPredicate) match(v string, compare func(a, b string) int) bool {
+	if p.op == "prefix" {
+		pn, _ := splitLooseVersion(p.version)
+		vn, _ := splitLooseVersion(v)
+		if pn == nil || vn == nil {
+			return strings.HasPrefix(v, p.version)
+		}
+		for i, n := range pn {
+			if i >= len(vn) {
+				if n != 0 {
+					return false
+				}
+			} else if vn[i] != n {
+				return false
+			}
+		}
+		return true
+	}
+	c := compare(v, p.version)
+	switch p.op {
+	case "=":
+		return c == 0
+	case ">":
+		return c > 0
+	case ">=":
+		return c >= 0
+	case "<":
+		return c < 0
+	case "<=":
+		return c <= 0
+	}
+	return false
+}
+
+// VersionRange is a union of version intervals, parsed from fabric/semver style predicates or maven ranges
+type VersionRange struct {
+	raw string
+	// anyOf is a list of predicate groups, a version matches the range if it matches all predicates of any group.
+	// nil means any version
+	anyOf [][]versionPredicate
+}
+
+// AnyVersion matches all versions
+var AnyVersion = VersionRange{raw: "*"}
+
+type VersionRangeErr struct {
+	Range  string
+	Reason string
+}
+
+func (e *VersionRangeErr) Error() string {
+	return fmt.Sprintf("Invalid version range %q: %s", e.Range, e.Reason)
+}
+
+func (r VersionRange) String() string {
+	return r.raw
+}
+
+// IsAny reports whether the range matches all versions
+func (r VersionRange) IsAny() bool {
+	return r.anyOf == nil
+}
+
+// Match reports whether the version is in the range
+func (r VersionRange) Match(version string) bool {
+	return r.MatchWith(version, compareVersionStrings)
+}
+
+// MatchMc is same as Match, but orders versions as minecraft versions, so snapshots and pre-releases are handled
+func (r VersionRange) MatchMc(version string) bool {
+	return r.MatchWith(version, CompareMcVersionStrings)
+}
+
+// MatchWith is same as Match, but use the compare function to order versions
+func (r VersionRange) MatchWith(version string, compare func(a, b string) int) bool {
+	if r.anyOf == nil {
+		return true
+	}
+	for _, group := range r.anyOf {
+		ok := true
+		for _, p := range group {
+			if !p.match(version, compare) {
+				ok = false
+				break
+			}
+		}
+		if ok {
+			return true
+		}
+	}
+	return false
+}
+
+// ParseSemverRange parses fabric/npm style ranges, such as "*", ">=1.19 <1.21", "~1.20", "^0.14", "1.20.x" or "1.19.4 || 1.20.1"
+func ParseSemverRange(s string) (r VersionRange, err error) {
+	r.raw = s
+	for _, part := range strings.Split(s, "||") {
+		var group []versionPredicate
+		for _, f := range strings.Fields(part) {
+			var preds []versionPredicate
+			if preds, err = parseSemverPredicate(f); err != nil {
+				return VersionRange{}, &VersionRangeErr{s, err.Error()}
+			}
+			group = append(group, preds...)
+		}
+		if len(group) == 0 { // "*" or empty matches any version
+			r.anyOf = nil
+			return
+		}
+		r.anyOf = append(r.anyOf, group)
+	}
+	return
+}
+
+// ParseSemverRanges parses a list of semver ranges that any of them can be matched
+func ParseSemverRanges(ranges []string) (r VersionRange, err error) {
+	if len(ranges) == 0 {
+		return AnyVersion, nil
+	}
+	return ParseSemverRange(strings.Join(ranges, " || "))
+}
+
+func isWildcard(s string) bool {
+	return s == "*" || s == "x" || s == "X"
+}
+
+func parseSemverPredicate(f string) (preds []versionPredicate, err error) {
+	if isWildcard(f) {
+		return nil, nil
+	}
+	for _, op := range []string{">=", "<=", ">", "<", "="} {
+		if strings.HasPrefix(f, op) {
+			v := strings.TrimSpace(f[len(op):])
+			if v == "" {
+				return nil, fmt.Errorf("missing version after %q", op)
+			}
+			return []versionPredicate{{op, v}}, nil
+		}
+	}
+	switch f[0] {
+	case '~', '^':
+		v := f[1:]
+		nums, _ := splitLooseVersion(v)
+		if nums == nil {
+			return nil, fmt.Errorf("unexpected version %q", v)
+		}
+		var upper string
+		if f[0] == '~' && len(nums) >= 2 {
+			upper = strconv.Itoa(nums[0]) + "." + strconv.Itoa(nums[1]+1)
+		} else {
+			upper = strconv.Itoa(nums[0] + 1)
+		}
+		return []versionPredicate{{">=", v}, {"<", upper}}, nil
+	}
+	// 1.20.x 1.20.* or 1.20.X
+	if i := strings.LastIndexByte(f, '.'); i > 0 && isWildcard(f[i+1:]) {
+		return []versionPredicate{{"prefix", strings.TrimRight(f[:i], ".xX*")}}, nil
+	}
+	return []versionPredicate{{"=", f}}, nil
+}
+
+// ParseMavenRange parses maven version ranges, such as "[1.20,1.21)", "[47,)", "(,1.0]", "[1.0]" or "[1,2),[3,4)".
+// A bare version is a soft requirement in maven, so it matches any version.
+func ParseMavenRange(s string) (r VersionRange, err error) {
+	r.raw = s
+	rest := strings.TrimSpace(s)
+	if rest == "" || rest == "*" || (rest[0] != '[' && rest[0] != '(') {
+		r.anyOf = nil
+		return
+	}
+	for len(rest) > 0 {
+		if rest[0] != '[' && rest[0] != '(' {
+			return VersionRange{}, &VersionRangeErr{s, "range must start with '[' or '('"}
+		}
+		end := strings.IndexAny(rest, "])")
+		if end < 0 {
+			return VersionRange{}, &VersionRangeErr{s, "unclosed range"}
+		}
+		lowerInc, upperInc := rest[0] == '[', rest[end] == ']'
+		body := rest[1:end]
+		rest = strings.TrimLeft(rest[end+1:], ", ")
+		lower, upper, hasComma := strings.Cut(body, ",")
+		lower, upper = strings.TrimSpace(lower), strings.TrimSpace(upper)
+		if !hasComma {
+			if !lowerInc || !upperInc || lower == "" {
+				return VersionRange{}, &VersionRangeErr{s, "exact version must be surrounded by '[' and ']'"}
+			}
+			r.anyOf = append(r.anyOf, []versionPredicate{{"=", lower}})
+			continue
+		}
+		group := make([]versionPredicate, 0, 2)
+		if lower != "" {
+			op := ">"
+			if lowerInc {
+				op = ">="
+			}
+			group = append(group, versionPredicate{op, lower})
+		}
+		if upper != "" {
+			op := "<"
+			if upperInc {
+				op = "<="
+			}
+			group = append(group, versionPredicate{op, upper})
+		}
+		if len(group) == 0 {
+			r.anyOf = nil
+			return
+		}
+		r.anyOf = append(r.anyOf, group)
+	}
+	return
+}
@@ -0,0 +1,76 @@
+package installer
+
+import (
+	"testing"
+)
+
+func TestMcVersionCompare(t *testing.T) {
+	tests := []struct {
+		a, b       string
+		want       int
+		comparable bool
+	}{
+		{"1.20.1", "1.19.4", 1, true},
+		{"1.19.4", "1.20.1", -1, true},
+		{"1.20.1", "1.20.1", 0, true},
+		{"23w14a", "1.19.4", 1, true},
+		{"23w14a", "1.20", -1, true},
+		{"23w14a", "1.20-pre1", -1, true},
+		{"23w14a", "23w13a", 1, true},
+		{"23w14a", "23w31a", -1, true},
+		{"1.20-pre1", "1.20", -1, true},
+		{"1.20-pre1", "1.20-pre2", -1, true},
+		{"1.20-pre1", "1.19.4", 1, true},
+		{"1.20.2-rc1", "1.20.2-pre4", 1, true},
+		{"1.20.2-rc1", "1.20.2", -1, true},
+		{"1.20.2-rc1", "1.20.1", 1, true},
+		{"b1.7.3", "a1.2.6", 1, true},
+		{"b1.7.3", "1.0", -1, true},
+		{"10w14a", "1.0", 0, false},
+		{"10w14a", "09w50a", 1, true},
+		{"20w14infinite", "1.16", 0, false},
+	}
+	for _, tt := range tests {
+		a, err := ParseMcVersion(tt.a)
+		if err != nil {
+			t.Fatalf("ParseMcVersion(%q): %v", tt.a, err)
+		}
+		b, err := ParseMcVersion(tt.b)
+		if err != nil {
+			t.Fatalf("ParseMcVersion(%q): %v", tt.b, err)
+		}
+		if got := a.Comparable(b); got != tt.comparable {
+			t.Errorf("%q.Comparable(%q) = %v, want %v", tt.a, tt.b, got, tt.comparable)
+		}
+		if got := a.Compare(b); got != tt.want {
+			t.Errorf("%q.Compare(%q) = %d, want %d", tt.a, tt.b, got, tt.want)
+		}
+	}
+}
+
+func TestVersionCompare(t *testing.T) {
+	tests := []struct {
+		a, b string
+		want int
+	}{
+		{"47.1.0", "47.0.35", 1},
+		{"47.1.0", "47.1.0", 0},
+		{"47.1.0", "47.1.3", -1},
+		{"47.1.0-beta.1", "47.1.0", -1},
+		{"47.1.0", "47.1.0+build.2", -1},
+		{"14.23.5.2860", "14.23.5.2859", 1},
+	}
+	for _, tt := range tests {
+		a, err := VersionFromString(tt.a)
+		if err != nil {
+			t.Fatalf("VersionFromString(%q): %v", tt.a, err)
+		}
+		b, err := VersionFromString(tt.b)
+		if err != nil {
+			t.Fatalf("VersionFromString(%q): %v", tt.b, err)
+		}
+		if got := a.Compare(b); got != tt.want {
+			t.Errorf("%q.Compare(%q) = %d, want %d", tt.a, tt.b, got, tt.want)
+		}
+	}
+}